@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// printCategoryTotals prints the number and size of images in each category
+func printCategoryTotals(w io.Writer, categories []CategoryTotal) {
+	fmt.Fprintln(w, "\nImage files by category:")
+	for _, ct := range categories {
+		fmt.Fprintf(w, "Category: %s | Image Files: %s | Size: %s\n", ct.Category, formatInt(int64(ct.Count)), sizeText(ct.Size))
+	}
+}
+
+// printExtensionTotals prints the count and size of the images with each extension
+func printExtensionTotals(w io.Writer, extensions []ExtTotal) {
+	fmt.Fprintln(w, "\nImage files by extension:")
+	for _, et := range extensions {
+		fmt.Fprintf(w, "Extension: %s | Image Files: %s | Size: %s\n", strings.TrimPrefix(et.Ext, "."), formatInt(int64(et.Count)), sizeText(et.Size))
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/kwdowicz/image_sorter/scanner"
+)
+
+// How often, at most, -checkpoint rewrites its file while a scan is running
+const checkpointEvery = 10 * time.Second
+
+// Version of the checkpoint file layout; files with another version are rejected
+const checkpointVersion = 2
+
+// Name under which a checkpoint records the files directly inside a root, which no
+// directory entry can be called
+const rootFilesPart = "."
+
+// checkpoint is the progress of a -checkpoint scan: for each root, the top-level parts it
+// has fully scanned and their merged result. A part is a subdirectory directly below the
+// root, or rootFilesPart.
+type checkpoint struct {
+	Version int              `json:"version"`
+	Roots   []checkpointRoot `json:"roots"`
+
+	path  string
+	saved time.Time
+}
+
+type checkpointRoot struct {
+	Root   string          `json:"root"`
+	Done   []string        `json:"done"`
+	Result *scanner.Result `json:"result"`
+}
+
+// loadCheckpoint reads the checkpoint at path left by an interrupted scan of roots. A
+// missing file yields an empty checkpoint. A checkpoint of other roots is an error, since
+// resuming from it would mix up two scans.
+func loadCheckpoint(path string, roots []string) (*checkpoint, error) {
+	cp := &checkpoint{Version: checkpointVersion, path: path, saved: time.Now()}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cp, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cp); err != nil {
+		return nil, err
+	}
+	if cp.Version != checkpointVersion {
+		return nil, fmt.Errorf("%s was written by another version", path)
+	}
+	for _, r := range cp.Roots {
+		if !slices.Contains(roots, r.Root) {
+			return nil, fmt.Errorf("%s was written for a scan of %s", path, r.Root)
+		}
+	}
+	return cp, nil
+}
+
+// done returns how many parts of all roots were fully scanned before
+func (cp *checkpoint) done() int {
+	n := 0
+	for _, r := range cp.Roots {
+		n += len(r.Done)
+	}
+	return n
+}
+
+// root returns the progress recorded for root, adding it if there is none yet
+func (cp *checkpoint) root(root string) *checkpointRoot {
+	for i := range cp.Roots {
+		if cp.Roots[i].Root == root {
+			return &cp.Roots[i]
+		}
+	}
+	cp.Roots = append(cp.Roots, checkpointRoot{Root: root, Result: scanner.NewResult()})
+	return &cp.Roots[len(cp.Roots)-1]
+}
+
+// save writes the checkpoint to its file, replacing it atomically so an interruption
+// midway leaves the previous one intact
+func (cp *checkpoint) save() error {
+	cp.saved = time.Now()
+	tmp, err := os.CreateTemp(filepath.Dir(cp.path), filepath.Base(cp.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // fails harmlessly once renamed
+
+	err = json.NewEncoder(tmp).Encode(cp)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), cp.path)
+}
+
+// scanParts scans root one part at a time with scan, which is scanner.ScanContext or
+// scanner.CountByDir, skipping the parts cp has as done and saving cp at most every
+// checkpointEvery after finishing one. The returned result covers the earlier runs too. If
+// the scan is cancelled or fails, cp is saved with the parts finished so far, and the
+// result still includes what was found of the unfinished part.
+func scanParts(ctx context.Context, root string, opts scanner.Options, cp *checkpoint, scan func(context.Context, string, scanner.Options) (*scanner.Result, error)) (*scanner.Result, error) {
+	progress := cp.root(root)
+	result := scanner.NewResult()
+	result.Merge(progress.Result)
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return result, err
+	}
+	parts := []string{rootFilesPart}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			parts = append(parts, entry.Name())
+		}
+	}
+
+	for _, part := range parts {
+		if slices.Contains(progress.Done, part) {
+			continue
+		}
+		partOpts := opts
+		partOpts.TopLevel = func(name string, isDir bool) bool {
+			if part == rootFilesPart {
+				return !isDir
+			}
+			return isDir && name == part
+		}
+		partResult, err := scan(ctx, root, partOpts)
+		if partResult != nil {
+			result.Merge(partResult)
+		}
+		if err != nil {
+			if saveErr := cp.save(); saveErr != nil {
+				logger.Error("saving checkpoint failed", "path", cp.path, "err", saveErr)
+			}
+			return result, err
+		}
+
+		progress.Result.Merge(partResult)
+		progress.Done = append(progress.Done, part)
+		if time.Since(cp.saved) >= checkpointEvery {
+			if err := cp.save(); err != nil {
+				logger.Error("saving checkpoint failed", "path", cp.path, "err", err)
+			}
+		}
+	}
+	return result, nil
+}
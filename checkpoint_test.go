@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kwdowicz/image_sorter/scanner"
+)
+
+// checkpointTree creates a root with images directly inside it and in five subdirectories
+func checkpointTree(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "top.jpg"), "top")
+	for i := range 5 {
+		for j := range i + 1 {
+			writeFile(t, filepath.Join(root, fmt.Sprintf("dir%d", i), "sub", fmt.Sprintf("%d.png", j)), strings.Repeat("x", i+j+1))
+		}
+	}
+	return root
+}
+
+func TestCheckpointResume(t *testing.T) {
+	root := checkpointTree(t)
+	path := filepath.Join(t.TempDir(), "scan.checkpoint")
+	opts := scanner.Options{MaxDepth: -1}
+	full, err := scanner.Scan(root, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first run is interrupted while scanning its fourth part, having found some of it
+	cp, err := loadCheckpoint(path, []string{root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := 0
+	interrupted := func(ctx context.Context, root string, opts scanner.Options) (*scanner.Result, error) {
+		if calls++; calls == 4 {
+			partial, _ := scanner.ScanContext(ctx, root, opts)
+			return partial, context.Canceled
+		}
+		return scanner.ScanContext(ctx, root, opts)
+	}
+	if _, err := scanParts(context.Background(), root, opts, cp, interrupted); err != context.Canceled {
+		t.Fatalf("interrupted run: got %v, want context.Canceled", err)
+	}
+
+	cp, err = loadCheckpoint(path, []string{root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cp.done(); got != 3 {
+		t.Errorf("checkpoint has %d parts done, want the 3 finished before the interruption", got)
+	}
+	resumed, err := scanParts(context.Background(), root, opts, cp, scanner.ScanContext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resumed.TotalImages != full.TotalImages || resumed.TotalSize != full.TotalSize || !maps.Equal(resumed.DirFileCount, full.DirFileCount) {
+		t.Errorf("resumed: got %d images, %d bytes, %v; want %d, %d, %v",
+			resumed.TotalImages, resumed.TotalSize, resumed.DirFileCount, full.TotalImages, full.TotalSize, full.DirFileCount)
+	}
+}
+
+func TestCheckpointFlag(t *testing.T) {
+	root := checkpointTree(t)
+	path := filepath.Join(t.TempDir(), "scan.checkpoint")
+	want := runJSON(t, "-min-count", "0", root)
+
+	// Leave a checkpoint with part of the tree done, as an interrupted run would
+	cp, err := loadCheckpoint(path, []string{root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	progress := cp.root(root)
+	for _, part := range []string{rootFilesPart, "dir0", "dir1"} {
+		opts := scanner.Options{MaxDepth: -1, TopLevel: func(name string, isDir bool) bool {
+			return (part == rootFilesPart && !isDir) || (isDir && name == part)
+		}}
+		result, err := scanner.Scan(root, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		progress.Result.Merge(result)
+		progress.Done = append(progress.Done, part)
+	}
+	if err := cp.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, stderr, code := runCLI(t, "-checkpoint", path, "-min-count", "0", root)
+	if code != exitOK {
+		t.Fatalf("exit code %d, stderr:\n%s", code, stderr)
+	}
+	if !strings.Contains(stdout, "skipping 3 directories scanned before") {
+		t.Errorf("the run didn't resume:\n%s", stdout)
+	}
+	if total := fmt.Sprintf("Total Images: %d\n", want.TotalImages); !strings.Contains(stdout, total) {
+		t.Errorf("resumed run has other totals than %q:\n%s", total, stdout)
+	}
+	for _, dir := range want.AcceptedDirs {
+		if !strings.Contains(stdout, "Directory: "+dir+" | Image Files:") {
+			t.Errorf("resumed run doesn't list %s:\n%s", dir, stdout)
+		}
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("checkpoint left behind after a complete scan: %v", err)
+	}
+}
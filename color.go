@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// useColor is whether the text report is colored, set once from -color before the scan
+// starts. The JSON, CSV and other machine-readable outputs never are.
+var useColor bool
+
+// ANSI SGR codes of the report's colors
+const (
+	colorLarge = "31" // red, for sizes above largeDirSize
+	colorRAW   = "35" // magenta, for RAW files
+	colorWarn  = "33" // yellow
+	colorError = "31" // red
+	colorDim   = "2"
+)
+
+// Directories whose images take up more than this are colored in the size ranking
+const largeDirSize = 1 << 30
+
+// colorEnabled reports whether output to f is colored under the -color mode: always,
+// never, or auto to color only a terminal, unless the NO_COLOR environment variable is set
+func colorEnabled(mode string, f *os.File) (bool, error) {
+	switch mode {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "auto":
+		return os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb" && isTerminal(f), nil
+	}
+	return false, fmt.Errorf("unknown mode %q: must be auto, always or never", mode)
+}
+
+// paint wraps text in the ANSI escape codes for the color code when the report is colored
+func paint(code, text string) string {
+	if !useColor {
+		return text
+	}
+	return "\x1b[" + code + "m" + text + "\x1b[0m"
+}
+
+// Color of the diagnostics at each level; info is left plain
+var levelColors = map[slog.Level]string{
+	slog.LevelDebug: colorDim,
+	slog.LevelWarn:  colorWarn,
+	slog.LevelError: colorError,
+}
+
+// colorWriter colors each line written to w, as a slog.TextHandler writes one line per
+// record. code is set by colorHandler for the record being written, under mu.
+type colorWriter struct {
+	mu   sync.Mutex
+	w    io.Writer
+	code string
+}
+
+func (c *colorWriter) Write(p []byte) (int, error) {
+	if c.code == "" {
+		return c.w.Write(p)
+	}
+	line := strings.TrimSuffix(string(p), "\n")
+	if _, err := io.WriteString(c.w, "\x1b["+c.code+"m"+line+"\x1b[0m\n"); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// colorHandler colors the lines of handler, which must write to w, by their level. The
+// handler quotes escape codes in messages and values, so whole lines are colored instead.
+type colorHandler struct {
+	slog.Handler
+	w *colorWriter
+}
+
+func (h colorHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.w.mu.Lock()
+	defer h.w.mu.Unlock()
+	h.w.code = levelColors[r.Level]
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h colorHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return colorHandler{h.Handler.WithAttrs(attrs), h.w}
+}
+
+func (h colorHandler) WithGroup(name string) slog.Handler {
+	return colorHandler{h.Handler.WithGroup(name), h.w}
+}
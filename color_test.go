@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestColorNever(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "a.jpg"), "image")
+	writeFile(t, filepath.Join(root, "raw", "b.cr2"), "raw image")
+	writeFile(t, filepath.Join(root, "Windows", "c.jpg"), "ignored") // logged at debug level
+	// An extension that isn't a known image format is warned about
+	args := []string{"-log-level", "debug", "-ext", "jpg,cr2,xyz", "-sort-by", "path", root}
+
+	stdout, stderr, code := runCLI(t, append([]string{"-color", "never"}, args...)...)
+	if code != exitOK {
+		t.Fatalf("exit code %d, stderr:\n%s", code, stderr)
+	}
+	if strings.Contains(stdout, "\x1b") || strings.Contains(stderr, "\x1b") {
+		t.Errorf("escape sequences with -color never:\nstdout:\n%q\nstderr:\n%q", stdout, stderr)
+	}
+	if !strings.Contains(stderr, "skipping ignored directory") || !strings.Contains(stderr, "not a recognized image extension") {
+		t.Errorf("stderr lacks the diagnostics:\n%s", stderr)
+	}
+	if !strings.Contains(stdout, "File: "+filepath.Join(root, "raw", "b.cr2")+" |") {
+		t.Errorf("the RAW file isn't listed plainly:\n%s", stdout)
+	}
+
+	// With always, the same run is colored, but only ever in the text report
+	stdout, stderr, _ = runCLI(t, append([]string{"-color", "always"}, args...)...)
+	if !strings.Contains(stderr, "\x1b["+colorDim+"m") || !strings.Contains(stderr, "\x1b["+colorWarn+"m") {
+		t.Errorf("the diagnostics aren't colored with -color always:\n%q", stderr)
+	}
+	if !strings.Contains(stdout, "\x1b["+colorRAW+"m"+filepath.Join(root, "raw", "b.cr2")+"\x1b[0m") {
+		t.Errorf("the RAW file isn't colored with -color always:\n%q", stdout)
+	}
+	stdout, _, _ = runCLI(t, "-color", "always", "-format", "json", root)
+	if strings.Contains(stdout, "\x1b") {
+		t.Errorf("escape sequences in the JSON output:\n%q", stdout)
+	}
+}
+
+func TestColorEnabled(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	for mode, want := range map[string]bool{"always": true, "never": false, "auto": false} {
+		if got, err := colorEnabled(mode, f); got != want || err != nil {
+			t.Errorf("%s to a file: got %v, %v, want %v", mode, got, err, want)
+		}
+	}
+	t.Setenv("NO_COLOR", "1")
+	if got, _ := colorEnabled("always", f); !got {
+		t.Error("NO_COLOR overrides always")
+	}
+	if _, err := colorEnabled("sometimes", f); err == nil {
+		t.Error("sometimes: no error")
+	}
+	if _, _, code := runCLI(t, "-color", "sometimes", t.TempDir()); code != exitUsage {
+		t.Errorf("-color sometimes: got exit code %d, want %d", code, exitUsage)
+	}
+}
+
+func TestPaint(t *testing.T) {
+	defer func(saved bool) { useColor = saved }(useColor)
+	useColor = false
+	if got := paint(colorWarn, "text"); got != "text" {
+		t.Errorf("uncolored: got %q", got)
+	}
+	useColor = true
+	if got := paint(colorWarn, "text"); got != "\x1b[33mtext\x1b[0m" {
+		t.Errorf("colored: got %q", got)
+	}
+}
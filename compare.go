@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync"
+
+	"github.com/kwdowicz/image_sorter/scanner"
+)
+
+// CompareResult is how the images of the scanned root and those of the -compare tree
+// match up, by relative path or by content
+type CompareResult struct {
+	Other     string   `json:"other"`
+	By        string   `json:"by"`                 // "path" or "hash"
+	OnlyRoot  []string `json:"only_in_root"`       // no match in the other tree
+	OnlyOther []string `json:"only_in_other"`      // no match in the root, these being paths in the other tree
+	Both      []string `json:"in_both"`            // matched, as paths in the root
+	Changed   []string `json:"changed,omitempty"`  // by path, of Both those whose content differs
+	Unhashed  []string `json:"unhashed,omitempty"` // files that couldn't be read when they had to be hashed, left out of the other lists
+}
+
+// hashFiles returns the SHA-256 of each of files, using the given number of worker
+// goroutines, leaving it empty for those that can't be read
+func hashFiles(files []ImageFile, workers int) []string {
+	if workers < 1 {
+		workers = 1
+	}
+
+	// Each worker writes only the entries for the indexes it receives
+	hashes := make([]string, len(files))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				hash, err := hashFile(files[i].Path)
+				if err != nil {
+					logger.Error("hashing failed", "path", files[i].Path, "err", err)
+					continue
+				}
+				hashes[i] = hash
+			}
+		}()
+	}
+	for i := range files {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return hashes
+}
+
+// compareByPath matches the images below root with those below other by their path
+// relative to each. A matched pair differing in size has changed; one of the same size
+// whose modification times differ, like a copy made without keeping them or a file
+// rewritten in place, is hashed on both sides to tell.
+func compareByPath(root string, files []ImageFile, other string, otherFiles []ImageFile, workers int) CompareResult {
+	result := CompareResult{Other: other, By: "path"}
+	theirs := make(map[string]ImageFile, len(otherFiles))
+	for _, file := range otherFiles {
+		theirs[relPath(other, file.Path)] = file
+	}
+	ours := make(map[string]bool, len(files))
+	var pairs []ImageFile // ours and theirs in turn, for the same-size pairs to hash
+	for _, file := range files {
+		rel := relPath(root, file.Path)
+		ours[rel] = true
+		match, ok := theirs[rel]
+		switch {
+		case !ok:
+			result.OnlyRoot = append(result.OnlyRoot, file.Path)
+		case match.Size != file.Size:
+			result.Both = append(result.Both, file.Path)
+			result.Changed = append(result.Changed, file.Path)
+		case !match.ModTime.Equal(file.ModTime):
+			pairs = append(pairs, file, match)
+		default:
+			result.Both = append(result.Both, file.Path)
+		}
+	}
+	hashes := hashFiles(pairs, workers)
+	for i := 0; i < len(pairs); i += 2 {
+		switch {
+		case hashes[i] == "" || hashes[i+1] == "":
+			result.Unhashed = append(result.Unhashed, pairs[i].Path)
+		case hashes[i] != hashes[i+1]:
+			result.Both = append(result.Both, pairs[i].Path)
+			result.Changed = append(result.Changed, pairs[i].Path)
+		default:
+			result.Both = append(result.Both, pairs[i].Path)
+		}
+	}
+	for _, file := range otherFiles {
+		if !ours[relPath(other, file.Path)] {
+			result.OnlyOther = append(result.OnlyOther, file.Path)
+		}
+	}
+	return result
+}
+
+// compareByHash matches the images in files with those in otherFiles by content, wherever
+// they are. Only the files whose size occurs in the other tree too are hashed.
+func compareByHash(files []ImageFile, other string, otherFiles []ImageFile, workers int) CompareResult {
+	result := CompareResult{Other: other, By: "hash"}
+
+	// Sizes found on each side, to pick the files that may have a match
+	ourSizes := make(map[int64]bool, len(files))
+	for _, file := range files {
+		ourSizes[file.Size] = true
+	}
+	theirSizes := make(map[int64]bool, len(otherFiles))
+	for _, file := range otherFiles {
+		theirSizes[file.Size] = true
+	}
+	var candidates []ImageFile
+	var fromOther []bool
+	for _, file := range files {
+		if theirSizes[file.Size] {
+			candidates = append(candidates, file)
+			fromOther = append(fromOther, false)
+		}
+	}
+	for _, file := range otherFiles {
+		if ourSizes[file.Size] {
+			candidates = append(candidates, file)
+			fromOther = append(fromOther, true)
+		}
+	}
+
+	hashes := hashFiles(candidates, workers)
+	ourHashes, theirHashes := make(map[string]bool), make(map[string]bool)
+	hashOf := make(map[string]string, len(candidates))
+	for i, file := range candidates {
+		if hashes[i] == "" {
+			result.Unhashed = append(result.Unhashed, file.Path)
+			continue
+		}
+		hashOf[file.Path] = hashes[i]
+		if fromOther[i] {
+			theirHashes[hashes[i]] = true
+		} else {
+			ourHashes[hashes[i]] = true
+		}
+	}
+
+	for _, file := range files {
+		hash, hashed := hashOf[file.Path]
+		switch {
+		case theirSizes[file.Size] && !hashed: // unreadable, listed in Unhashed
+		case theirHashes[hash] && hashed:
+			result.Both = append(result.Both, file.Path)
+		default:
+			result.OnlyRoot = append(result.OnlyRoot, file.Path)
+		}
+	}
+	for _, file := range otherFiles {
+		hash, hashed := hashOf[file.Path]
+		if ourSizes[file.Size] && !hashed {
+			continue
+		}
+		if !hashed || !ourHashes[hash] {
+			result.OnlyOther = append(result.OnlyOther, file.Path)
+		}
+	}
+	return result
+}
+
+// relPath returns path relative to root, or path itself if it lies below no root
+func relPath(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return path
+	}
+	return rel
+}
+
+// compareTrees scans other with opts and matches its images with files, those found below
+// root, by relative path or, with byHash set, by content. Images inside archives are
+// left out on both sides, as they can't be hashed.
+func compareTrees(ctx context.Context, root string, files []ImageFile, other string, opts scanner.Options, byHash bool, workers int) (CompareResult, error) {
+	opts.OnImage = nil // the other tree's images aren't part of the report
+	opts.Cache = nil   // nor of the stat cache, which was saved already
+	scanned, err := scanner.ScanContext(ctx, other, opts)
+	if err != nil {
+		return CompareResult{}, err
+	}
+	var otherFiles []ImageFile
+	for _, file := range scanned.Files {
+		if file.Archive == "" {
+			otherFiles = append(otherFiles, file)
+		}
+	}
+	if byHash {
+		return compareByHash(files, other, otherFiles, workers), nil
+	}
+	return compareByPath(root, files, other, otherFiles, workers), nil
+}
+
+// printCompare prints the images found on one side only, those matched and, by path,
+// those whose content changed, followed by a count of each
+func printCompare(w io.Writer, root string, result CompareResult) {
+	fmt.Fprintf(w, "\nCompared with %s by %s:\n", pathText(result.Other), result.By)
+	for _, path := range result.OnlyRoot {
+		fmt.Fprintf(w, "ONLY IN ROOT: %s\n", pathText(path))
+	}
+	for _, path := range result.OnlyOther {
+		fmt.Fprintf(w, "ONLY IN OTHER: %s\n", pathText(path))
+	}
+	changed := make(map[string]bool, len(result.Changed))
+	for _, path := range result.Changed {
+		changed[path] = true
+	}
+	for _, path := range result.Both {
+		if changed[path] {
+			fmt.Fprintf(w, "CHANGED: %s\n", pathText(path))
+		} else {
+			fmt.Fprintf(w, "IN BOTH: %s\n", pathText(path))
+		}
+	}
+	for _, path := range result.Unhashed {
+		fmt.Fprintf(w, "UNREADABLE: %s\n", pathText(path))
+	}
+	fmt.Fprintf(w, "%d only in %s, %d only in %s, %d in both", len(result.OnlyRoot), pathText(root), len(result.OnlyOther), pathText(result.Other), len(result.Both))
+	if result.By == "path" {
+		fmt.Fprintf(w, " (%d changed)", len(result.Changed))
+	}
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// compareFixture builds a root and an other tree holding, relative to each, one image in
+// both, one only in the root, one only in the other, one whose size changed and one
+// rewritten in place with the same size, and returns root, other and the scanned files
+func compareFixture(t *testing.T) (root, other string, files, otherFiles []ImageFile) {
+	t.Helper()
+	root, other = t.TempDir(), t.TempDir()
+	for name, content := range map[string]string{"same.jpg": "same", "sub/grown.jpg": "small", "sub/edited.jpg": "before", "ours.png": "ours"} {
+		writeFile(t, filepath.Join(root, name), content)
+	}
+	for name, content := range map[string]string{"same.jpg": "same", "sub/grown.jpg": "bigger", "sub/edited.jpg": "after!", "theirs.gif": "theirs"} {
+		writeFile(t, filepath.Join(other, name), content)
+	}
+	// Copies rarely keep their modification times, so only same.jpg has them match
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(other, "same.jpg"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(filepath.Join(root, "same.jpg"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(filepath.Join(other, "sub", "edited.jpg"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	return root, other, scanFiles(t, root), scanFiles(t, other)
+}
+
+func TestCompareByPath(t *testing.T) {
+	root, other, files, otherFiles := compareFixture(t)
+	result := compareByPath(root, files, other, otherFiles, 2)
+
+	in := func(dir string, names ...string) []string {
+		paths := make([]string, len(names))
+		for i, name := range names {
+			paths[i] = filepath.Join(dir, filepath.FromSlash(name))
+		}
+		slices.Sort(paths)
+		return paths
+	}
+	sorted := func(paths []string) []string {
+		paths = slices.Clone(paths)
+		slices.Sort(paths)
+		return paths
+	}
+	checks := []struct {
+		name      string
+		got, want []string
+	}{
+		{"only in root", sorted(result.OnlyRoot), in(root, "ours.png")},
+		{"only in other", sorted(result.OnlyOther), in(other, "theirs.gif")},
+		{"in both", sorted(result.Both), in(root, "same.jpg", "sub/edited.jpg", "sub/grown.jpg")},
+		{"changed", sorted(result.Changed), in(root, "sub/edited.jpg", "sub/grown.jpg")},
+	}
+	for _, c := range checks {
+		if !slices.Equal(c.got, c.want) {
+			t.Errorf("%s: got %q, want %q", c.name, c.got, c.want)
+		}
+	}
+	if len(result.Unhashed) != 0 {
+		t.Errorf("unhashed: got %q, want none", result.Unhashed)
+	}
+}
+
+func TestCompareByPathSameContent(t *testing.T) {
+	root, other := t.TempDir(), t.TempDir()
+	writeFile(t, filepath.Join(root, "a.jpg"), "image")
+	writeFile(t, filepath.Join(other, "a.jpg"), "image")
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(other, "a.jpg"), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	// A copy with another modification time but the same content hasn't changed
+	result := compareByPath(root, scanFiles(t, root), other, scanFiles(t, other), 1)
+	if len(result.Both) != 1 || len(result.Changed) != 0 {
+		t.Errorf("got %d in both, %d changed; want 1, 0", len(result.Both), len(result.Changed))
+	}
+}
+
+func TestCompareByHash(t *testing.T) {
+	root, other, files, _ := compareFixture(t)
+	writeFile(t, filepath.Join(other, "moved", "same.jpg"), "same")
+	result := compareByHash(files, other, scanFiles(t, other), 2)
+
+	if want := []string{filepath.Join(root, "same.jpg")}; !slices.Equal(result.Both, want) {
+		t.Errorf("in both: got %q, want %q", result.Both, want)
+	}
+	if len(result.OnlyRoot) != 3 {
+		t.Errorf("only in root: got %q, want the 3 images whose content isn't in the other tree", result.OnlyRoot)
+	}
+	if len(result.OnlyOther) != 3 {
+		t.Errorf("only in other: got %q, want the 3 images whose content isn't in the root", result.OnlyOther)
+	}
+}
+
+func TestCompareNested(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "backup", "a.jpg"), "image")
+	for _, tt := range []struct{ scan, other string }{
+		{root, filepath.Join(root, "backup")},
+		{filepath.Join(root, "backup"), root},
+		{root, root},
+	} {
+		if _, stderr, code := runCLI(t, "-compare", tt.other, tt.scan); code != exitUsage || !strings.Contains(stderr, "one is inside the other") {
+			t.Errorf("%s -compare %s: got exit code %d (%q), want %d", tt.scan, tt.other, code, stderr, exitUsage)
+		}
+	}
+}
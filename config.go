@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kwdowicz/image_sorter/scanner"
+)
+
+// fileConfig is the content of a -config file, for example:
+//
+//	{
+//	  "extensions": ["xpf", ".cr3", "jpe"],
+//	  "categories": {".xpf": "RAW", ".svg": "Vector"},
+//	  "aliases": {".jpe": ".jpg", ".heif": ".heif"},
+//	  "ignore_dirs": ["Thumbnails"],
+//	  "flags": {"min-count": 2, "workers": 4, "include-glob": ["*/DCIM/*"]}
+//	}
+type fileConfig struct {
+	Extensions []string          `json:"extensions"`  // added to the built-in image extensions
+	Categories map[string]string `json:"categories"`  // category per extension, replacing the built-in one
+	Aliases    map[string]string `json:"aliases"`     // canonical extension per alias; mapping one to itself drops the alias
+	IgnoreDirs []string          `json:"ignore_dirs"` // added to the built-in ignored directories
+	Flags      map[string]any    `json:"flags"`       // default flag values; a list sets a repeatable flag several times
+}
+
+// loadConfig reads the JSON config file at path and merges it into the built-in
+// extensions, categories and ignored directories. Its flag values are applied to the
+// flags in fs that weren't set yet, on the command line or by applyEnv, so those still
+// win. Unknown keys and flags, and flags outside defaultFlags, are reported as errors.
+func loadConfig(path string, fs *flag.FlagSet) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var config fileConfig
+	decoder := json.NewDecoder(f)
+	decoder.DisallowUnknownFields()
+	decoder.UseNumber() // keeps large integers like 1000000 out of float notation
+	if err := decoder.Decode(&config); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	for _, ext := range normalizeExtensions(strings.Join(config.Extensions, ",")) {
+		scanner.RegisterFormat(ext, builtinFormat{ext: ext})
+	}
+	for ext, category := range config.Categories {
+		exts := normalizeExtensions(ext)
+		if len(exts) != 1 || !scanner.DefaultExtensions[exts[0]] {
+			return fmt.Errorf("%s: category for unknown extension %q", path, ext)
+		}
+		scanner.SetCategory(exts[0], category)
+	}
+	for alias, canonical := range config.Aliases {
+		aliases, canonicals := normalizeExtensions(alias), normalizeExtensions(canonical)
+		if len(aliases) != 1 || !scanner.DefaultExtensions[aliases[0]] {
+			return fmt.Errorf("%s: alias for unknown extension %q", path, alias)
+		}
+		if len(canonicals) != 1 {
+			return fmt.Errorf("%s: invalid canonical extension %q for %q", path, canonical, alias)
+		}
+		scanner.SetAlias(aliases[0], canonicals[0])
+	}
+	scanner.DefaultIgnoreDirs = append(scanner.DefaultIgnoreDirs, config.IgnoreDirs...)
+
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	for name, value := range config.Flags {
+		if fs.Lookup(name) == nil || name == "config" {
+			return fmt.Errorf("%s: unknown flag %q", path, name)
+		}
+		if !defaultFlags[name] {
+			return fmt.Errorf("%s: flag %q can only be given on the command line", path, name)
+		}
+		if given[name] {
+			continue
+		}
+		values, ok := value.([]any)
+		if !ok {
+			values = []any{value}
+		}
+		for _, v := range values {
+			if err := fs.Set(name, fmt.Sprint(v)); err != nil {
+				return fmt.Errorf("%s: flag %q: %w", path, name, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"flag"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/kwdowicz/image_sorter/scanner"
+)
+
+// restoreScannerConfig puts back the extensions, categories, aliases and ignored
+// directories a config file changes, once the test is done
+func restoreScannerConfig(t *testing.T, categories, aliases []string) {
+	t.Helper()
+	extensions := maps.Clone(scanner.DefaultExtensions)
+	ignoreDirs := slices.Clone(scanner.DefaultIgnoreDirs)
+	savedCategories := make(map[string]string)
+	for _, ext := range categories {
+		savedCategories[ext] = scanner.CategoryOf(ext)
+	}
+	savedAliases := make(map[string]string)
+	for _, ext := range aliases {
+		savedAliases[ext] = scanner.CanonicalExt(ext)
+	}
+	t.Cleanup(func() {
+		scanner.DefaultExtensions = extensions
+		scanner.DefaultIgnoreDirs = ignoreDirs
+		for ext, category := range savedCategories {
+			scanner.SetCategory(ext, category)
+		}
+		for ext, canonical := range savedAliases {
+			scanner.SetAlias(ext, canonical)
+		}
+	})
+}
+
+func TestLoadConfig(t *testing.T) {
+	restoreScannerConfig(t, []string{".xpf", ".svg"}, []string{".jpe", ".jpeg"})
+	config := filepath.Join(t.TempDir(), "config.json")
+	writeFile(t, config, `{
+		"extensions": ["xpf", ".CR3", " jpe "],
+		"categories": {".xpf": "RAW", "svg": "Vector"},
+		"aliases": {".jpe": ".jpg", ".jpeg": ".jpeg"},
+		"ignore_dirs": ["Thumbnails"],
+		"flags": {"min-count": 2}
+	}`)
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	minCount := fs.Int("min-count", 5, "")
+
+	builtin := maps.Clone(scanner.DefaultExtensions)
+	if err := loadConfig(config, fs); err != nil {
+		t.Fatal(err)
+	}
+	want := maps.Clone(builtin)
+	for _, ext := range []string{".xpf", ".cr3", ".jpe"} {
+		want[ext] = true
+	}
+	if !maps.Equal(scanner.DefaultExtensions, want) {
+		t.Errorf("got extensions %v, want the built-in ones and .xpf, .cr3 and .jpe", slices.Sorted(maps.Keys(scanner.DefaultExtensions)))
+	}
+	if got := scanner.CategoryOf(".xpf"); got != "RAW" {
+		t.Errorf("CategoryOf(.xpf) = %q, want RAW", got)
+	}
+	if got := scanner.CategoryOf(".svg"); got != "Vector" {
+		t.Errorf("CategoryOf(.svg) = %q, want Vector", got)
+	}
+	if got := scanner.CanonicalExt(".jpe"); got != ".jpg" {
+		t.Errorf("CanonicalExt(.jpe) = %q, want .jpg", got)
+	}
+	if got := scanner.CanonicalExt(".jpeg"); got != ".jpeg" {
+		t.Errorf("CanonicalExt(.jpeg) = %q, want the alias dropped", got)
+	}
+	if !slices.Contains(scanner.DefaultIgnoreDirs, "Thumbnails") || !slices.Contains(scanner.DefaultIgnoreDirs, "Windows") {
+		t.Errorf("ignored directories %q, want the built-in ones and Thumbnails", scanner.DefaultIgnoreDirs)
+	}
+	if *minCount != 2 {
+		t.Errorf("-min-count = %d, want 2 from the config", *minCount)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	restoreScannerConfig(t, nil, nil)
+	tests := map[string]string{
+		`{"extension": ["xpf"]}`:              "unknown field",
+		`{"categories": {".nope": "RAW"}}`:    "unknown extension",
+		`{"aliases": {".nope": ".jpg"}}`:      "unknown extension",
+		`{"aliases": {".jpeg": "a,b"}}`:       "invalid canonical extension",
+		`{"flags": {"no-such-flag": 1}}`:      "unknown flag",
+		`{"flags": {"config": "other.json"}}`: "unknown flag",
+		`{"flags": {"min-count": "many"}}`:    "min-count",
+		`{"flags": {"delete-dupes": true}}`:   "only be given on the command line",
+		`{"flags": {"sort-into": "/tmp/x"}}`:  "only be given on the command line",
+		`{"extensions": `:                     "unexpected EOF",
+	}
+	for content, want := range tests {
+		config := filepath.Join(t.TempDir(), "config.json")
+		writeFile(t, config, content)
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.Int("min-count", 5, "")
+		fs.String("config", "", "")
+		fs.Bool("delete-dupes", false, "")
+		fs.String("sort-into", "", "")
+		if err := loadConfig(config, fs); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: got error %v, want one mentioning %q", content, err, want)
+		}
+	}
+}
+
+func TestConfigExtensionsScanned(t *testing.T) {
+	restoreScannerConfig(t, nil, nil)
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "a.xpf"), "raw")
+	writeFile(t, filepath.Join(root, "b.jpg"), "jpeg")
+	config := filepath.Join(t.TempDir(), "config.json")
+	writeFile(t, config, `{"extensions": ["xpf"]}`)
+
+	if result := runJSON(t, "-config", config, root); result.TotalImages != 2 {
+		t.Errorf("got %d images, want the .xpf file counted too", result.TotalImages)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// errDeclined is returned by confirm when the user doesn't answer yes
+var errDeclined = errors.New("declined")
+
+// confirm asks on the terminal whether to go ahead with what summary describes and returns
+// nil only if the user answers y or yes. With assumeYes set (-yes) it doesn't ask. When stdin
+// isn't a terminal it refuses, so a script can't change files without saying -yes.
+func confirm(summary string, assumeYes bool) error {
+	if assumeYes {
+		return nil
+	}
+	if !isTerminal(os.Stdin) {
+		return errors.New("stdin is not a terminal; pass -yes to proceed without confirmation")
+	}
+
+	fmt.Fprintf(os.Stderr, "%s — proceed? [y/N] ", summary)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+	return errDeclined
+}
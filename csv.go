@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// writeCSV writes one row per image to the file at path, creating or truncating it.
+// The header row is always written, even when no images were found. Paths that aren't
+// valid UTF-8 are escaped, see sanitizePath.
+func writeCSV(path string, files []ImageFile) (err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	w := csv.NewWriter(f)
+	if err := w.Write([]string{"path", "size_bytes", "extension", "dir"}); err != nil {
+		return err
+	}
+	for _, file := range files {
+		row := []string{
+			sanitizePath(file.Path),
+			strconv.FormatInt(file.Size, 10),
+			strings.TrimPrefix(file.Ext, "."),
+			sanitizePath(filepath.Dir(file.Path)),
+		}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// readCSV returns the rows of the CSV file at path, header included
+func readCSV(t *testing.T, path string) [][]string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
+
+func TestCSV(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a.jpg", "b.PNG", "sub/c, with comma.gif", "sub/d.jpeg", "notes.txt"} {
+		writeFile(t, filepath.Join(root, name), "image")
+	}
+	out := filepath.Join(t.TempDir(), "images.csv")
+
+	result := runJSON(t, "-csv", out, root)
+	rows := readCSV(t, out)
+	if want := []string{"path", "size_bytes", "extension", "dir"}; len(rows) == 0 || !slices.Equal(rows[0], want) {
+		t.Fatalf("header: got %q, want %q", rows, want)
+	}
+	if len(rows)-1 != result.TotalImages || result.TotalImages != 4 {
+		t.Errorf("got %d rows for %d images, want 4", len(rows)-1, result.TotalImages)
+	}
+	for _, row := range rows[1:] {
+		if filepath.Dir(row[0]) != row[3] || row[1] != "5" {
+			t.Errorf("row %q: want the path's directory and a size of 5", row)
+		}
+	}
+}
+
+func TestCSVNoImages(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "images.csv")
+	if err := writeCSV(out, nil); err != nil {
+		t.Fatal(err)
+	}
+	if rows := readCSV(t, out); len(rows) != 1 {
+		t.Errorf("got %d rows, want only the header", len(rows))
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// DuplicateGroup is a set of images with identical content
+type DuplicateGroup struct {
+	Hash   string      `json:"sha256"`
+	Size   int64       `json:"size"`
+	Files  []ImageFile `json:"files"`
+	Wasted int64       `json:"wasted"` // bytes taken up by all copies but one
+}
+
+// hashFile returns the hex-encoded SHA-256 of the file's content, streaming it from disk
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// findDuplicates groups images with identical content, hashing them with the given number
+// of worker goroutines. Files are first grouped by size and only hashed when another file
+// has the same size, so unique sizes are never read and only the hashes of the others are
+// kept. Files that can't be hashed are reported and left out. Groups are returned with the
+// most wasted space first.
+func findDuplicates(files []ImageFile, workers int) []DuplicateGroup {
+	if workers < 1 {
+		workers = 1
+	}
+
+	sizeCounts := make(map[int64]int)
+	for _, file := range files {
+		sizeCounts[file.Size]++
+	}
+	var candidates []int // indexes into files of the images sharing their size
+	for i, file := range files {
+		if sizeCounts[file.Size] > 1 {
+			candidates = append(candidates, i)
+		}
+	}
+
+	// Each worker writes only the entries for the indexes it receives
+	hashes := make([]string, len(candidates))
+	errs := make([]error, len(candidates))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				hashes[i], errs[i] = hashFile(files[candidates[i]].Path)
+			}
+		}()
+	}
+	for i := range candidates {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	type sizeHash struct {
+		size int64
+		hash string
+	}
+	bySizeHash := make(map[sizeHash][]ImageFile)
+	var keys []sizeHash // in first-seen order, so groups come out deterministically
+	for i, index := range candidates {
+		file := files[index]
+		if errs[i] != nil {
+			logger.Error("hashing failed", "path", file.Path, "err", errs[i])
+			continue
+		}
+		key := sizeHash{file.Size, hashes[i]}
+		if bySizeHash[key] == nil {
+			keys = append(keys, key)
+		}
+		bySizeHash[key] = append(bySizeHash[key], file)
+	}
+
+	var groups []DuplicateGroup
+	for _, key := range keys {
+		if same := distinctFiles(bySizeHash[key]); len(same) > 1 {
+			groups = append(groups, DuplicateGroup{
+				Hash:   key.hash,
+				Size:   key.size,
+				Files:  same,
+				Wasted: key.size * int64(len(same)-1),
+			})
+		}
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Wasted != groups[j].Wasted {
+			return groups[i].Wasted > groups[j].Wasted
+		}
+		return groups[i].Hash < groups[j].Hash
+	})
+	return groups
+}
+
+// printDuplicates prints each group of duplicate images and the total wasted space
+func printDuplicates(w io.Writer, groups []DuplicateGroup) {
+	var totalWasted int64
+	fmt.Fprintln(w, "\nDuplicate images:")
+	for _, group := range groups {
+		fmt.Fprintf(w, "SHA-256: %s | Copies: %d | Wasted: %s\n", group.Hash, len(group.Files), sizeText(group.Wasted))
+		for _, file := range group.Files {
+			fmt.Fprintf(w, "  %s\n", pathText(file.Path))
+		}
+		totalWasted += group.Wasted
+	}
+	fmt.Fprintf(w, "Total wasted space: %s in %d duplicate groups\n", sizeText(totalWasted), len(groups))
+}
+
+// sameFile reports whether the paths a and b lead to the same file on disk: the same path,
+// the same resolved symlink target or the same file by os.SameFile, like hard links
+func sameFile(a, b string) bool {
+	if filepath.Clean(a) == filepath.Clean(b) {
+		return true
+	}
+	if realA, err := filepath.EvalSymlinks(a); err == nil {
+		if realB, err := filepath.EvalSymlinks(b); err == nil && realA == realB {
+			return true
+		}
+	}
+	infoA, errA := os.Stat(a)
+	infoB, errB := os.Stat(b)
+	return errA == nil && errB == nil && os.SameFile(infoA, infoB)
+}
+
+// distinctFiles returns files without those leading to the same file on disk as an earlier
+// one, as found when roots overlap or symlinks are followed. Deleting such a "copy" would
+// delete the only one.
+func distinctFiles(files []ImageFile) []ImageFile {
+	var distinct []ImageFile
+	for _, file := range files {
+		seen := false
+		for _, kept := range distinct {
+			if sameFile(file.Path, kept.Path) {
+				seen = true
+				break
+			}
+		}
+		if !seen {
+			distinct = append(distinct, file)
+		}
+	}
+	return distinct
+}
+
+// isSymlink reports whether path is a symbolic link itself
+func isSymlink(path string) bool {
+	info, err := os.Lstat(path)
+	return err == nil && info.Mode()&os.ModeSymlink != 0
+}
+
+// deleteDuplicates keeps the oldest file of each group, by modification time, and removes
+// the rest. Symlinks are only kept when the group has nothing but symlinks, so a followed
+// link is never kept in place of its target, and files that turn out to be the kept one
+// under another path are left alone. With dryRun set it only logs what would be removed.
+// It returns the paths of the files removed, or that would have been, and the bytes freed.
+func deleteDuplicates(groups []DuplicateGroup, dryRun bool, out io.Writer) ([]string, int64) {
+	var deleted []string
+	var freed int64
+	for _, group := range groups {
+		keep := -1
+		for i, file := range group.Files {
+			if isSymlink(file.Path) {
+				continue
+			}
+			if keep < 0 || file.ModTime.Before(group.Files[keep].ModTime) {
+				keep = i
+			}
+		}
+		if keep < 0 {
+			keep = 0
+			for i, file := range group.Files {
+				if file.ModTime.Before(group.Files[keep].ModTime) {
+					keep = i
+				}
+			}
+		}
+
+		for i, file := range group.Files {
+			if i == keep || sameFile(file.Path, group.Files[keep].Path) {
+				continue
+			}
+			if dryRun {
+				fmt.Fprintf(out, "Would delete: %s (keeping %s)\n", file.Path, group.Files[keep].Path)
+			} else if err := os.Remove(file.Path); err != nil {
+				logger.Error("deleting failed", "path", file.Path, "err", err)
+				continue
+			} else {
+				fmt.Fprintf(out, "Deleted: %s (keeping %s)\n", file.Path, group.Files[keep].Path)
+			}
+			deleted = append(deleted, file.Path)
+			freed += file.Size
+		}
+	}
+	return deleted, freed
+}
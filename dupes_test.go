@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeleteDuplicatesKeepsOldest(t *testing.T) {
+	dir := t.TempDir()
+	older, newer := filepath.Join(dir, "older.jpg"), filepath.Join(dir, "newer.jpg")
+	writeFile(t, older, "same")
+	writeFile(t, newer, "same")
+	now := time.Now()
+	group := DuplicateGroup{Files: []ImageFile{
+		{Path: newer, Size: 4, ModTime: now},
+		{Path: older, Size: 4, ModTime: now.Add(-time.Hour)},
+	}}
+
+	deleted, freed := deleteDuplicates([]DuplicateGroup{group}, true, io.Discard)
+	if len(deleted) != 1 || deleted[0] != newer || freed != 4 {
+		t.Errorf("dry run: got %q deleted, %d freed, want %s and 4", deleted, freed, newer)
+	}
+	if _, err := os.Stat(newer); err != nil {
+		t.Fatal("dry run removed a file:", err)
+	}
+
+	deleteDuplicates([]DuplicateGroup{group}, false, io.Discard)
+	if _, err := os.Stat(older); err != nil {
+		t.Error("the oldest file was removed:", err)
+	}
+	if _, err := os.Stat(newer); !os.IsNotExist(err) {
+		t.Error("the newer file was kept")
+	}
+}
+
+func TestDeleteDuplicatesSameFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "x.jpg")
+	writeFile(t, path, "only copy")
+	link := filepath.Join(dir, "link.jpg")
+	if err := os.Symlink(path, link); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+	hard := filepath.Join(dir, "hard.jpg")
+	if err := os.Link(path, hard); err != nil {
+		t.Skip("hard links not supported:", err)
+	}
+	now := time.Now()
+
+	tests := []struct {
+		name  string
+		files []ImageFile
+	}{
+		{"same path twice", []ImageFile{{Path: path, ModTime: now}, {Path: dir + string(filepath.Separator) + "." + string(filepath.Separator) + "x.jpg", ModTime: now}, {Path: path, ModTime: now}}},
+		{"symlink older than target", []ImageFile{{Path: link, ModTime: now.Add(-time.Hour)}, {Path: path, ModTime: now}}},
+		{"hard link", []ImageFile{{Path: hard, ModTime: now}, {Path: path, ModTime: now}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deleted, _ := deleteDuplicates([]DuplicateGroup{{Files: tt.files}}, false, io.Discard)
+			if len(deleted) != 0 {
+				t.Errorf("deleted %q, want nothing", deleted)
+			}
+			if _, err := os.Stat(path); err != nil {
+				t.Fatal("the only copy is gone:", err)
+			}
+			if _, err := os.Stat(link); err != nil {
+				t.Error("the symlink is dangling or gone:", err)
+			}
+		})
+	}
+}
+
+func TestDeleteDupesOverlappingRoots(t *testing.T) {
+	tests := []struct {
+		name string
+		args func(root string) []string
+	}{
+		{"same root twice", func(root string) []string { return []string{root, root + string(filepath.Separator)} }},
+		{"nested roots", func(root string) []string { return []string{root, filepath.Join(root, "sub")} }},
+		{"followed symlink", func(root string) []string { return []string{"-follow-symlinks", root} }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			path := filepath.Join(root, "sub", "x.jpg")
+			writeFile(t, path, "only copy")
+			link := filepath.Join(root, "link.jpg")
+			if err := os.Symlink(path, link); err != nil {
+				t.Skip("symlinks not supported:", err)
+			}
+			runCLI(t, append([]string{"-delete-dupes", "-yes", "-q"}, tt.args(root)...)...)
+			if _, err := os.Stat(path); err != nil {
+				t.Fatal("the only copy is gone:", err)
+			}
+			if _, err := os.Stat(link); err != nil {
+				t.Error("the symlink is dangling or gone:", err)
+			}
+		})
+	}
+}
+
+func TestFindDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.jpg"), "same")
+	writeFile(t, filepath.Join(dir, "sub", "b.jpg"), "same")
+	writeFile(t, filepath.Join(dir, "c.jpg"), "diff") // same size, other content
+	writeFile(t, filepath.Join(dir, "d.jpg"), "unique size")
+
+	files := scanFiles(t, dir)
+	for _, workers := range []int{1, 4} {
+		groups := findDuplicates(files, workers)
+		if len(groups) != 1 {
+			t.Fatalf("workers %d: got %d groups, want 1", workers, len(groups))
+		}
+		if got := len(groups[0].Files); got != 2 || groups[0].Wasted != 4 {
+			t.Errorf("workers %d: got %d files wasting %d bytes, want 2 wasting 4", workers, got, groups[0].Wasted)
+		}
+	}
+}
+
+// naiveDuplicates groups the paths of files by reading each of them whole, keeping the
+// groups with more than one file, sorted
+func naiveDuplicates(t *testing.T, files []ImageFile) [][]string {
+	t.Helper()
+	byContent := make(map[string][]string)
+	for _, file := range files {
+		data, err := os.ReadFile(file.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		byContent[string(data)] = append(byContent[string(data)], file.Path)
+	}
+	var groups [][]string
+	for _, paths := range byContent {
+		if len(paths) > 1 {
+			groups = append(groups, slices.Sorted(slices.Values(paths)))
+		}
+	}
+	slices.SortFunc(groups, slices.Compare)
+	return groups
+}
+
+func TestFindDuplicatesMatchesNaive(t *testing.T) {
+	dir := t.TempDir()
+	for i := range 60 {
+		// Contents repeat every 7 files and sizes every 5, so equal sizes often differ in content
+		content := strings.Repeat(string(rune('a'+i%7)), 1+i%5)
+		writeFile(t, filepath.Join(dir, fmt.Sprint(i%3), fmt.Sprintf("img%d.png", i)), content)
+	}
+	files := scanFiles(t, dir)
+	want := naiveDuplicates(t, files)
+
+	for _, workers := range []int{1, 8} {
+		var got [][]string
+		for _, group := range findDuplicates(files, workers) {
+			paths := make([]string, len(group.Files))
+			for i, file := range group.Files {
+				paths[i] = file.Path
+			}
+			got = append(got, slices.Sorted(slices.Values(paths)))
+		}
+		slices.SortFunc(got, slices.Compare)
+		if !slices.EqualFunc(got, want, slices.Equal) {
+			t.Errorf("workers %d: got %q, want %q", workers, got, want)
+		}
+	}
+}
+
+func BenchmarkFindDuplicates(b *testing.B) {
+	dir := b.TempDir()
+	// 200 files of one size, in 50 groups of identical content
+	for i := range 200 {
+		content := make([]byte, 64*1024)
+		content[0] = byte(i % 50)
+		writeFile(b, filepath.Join(dir, fmt.Sprintf("img%d.jpg", i)), string(content))
+	}
+	files := scanFiles(b, dir)
+	for _, workers := range []int{1, 4} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				findDuplicates(files, workers)
+			}
+		})
+	}
+}
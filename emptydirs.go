@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+)
+
+// EmptyDir is a directory with no images anywhere below it
+type EmptyDir struct {
+	Path  string `json:"path"`
+	Files int    `json:"files"` // files of other kinds below it; 0 if it is truly empty
+}
+
+// findEmptyDirs returns the directories of dirEntries, as recorded by a scan with
+// scanner.Options.RecordDirs, that hold no images, not even in their subdirectories, sorted
+// by path. Images inside archives count for the directory of the archive.
+func findEmptyDirs(dirEntries map[string]int, files []ImageFile) []EmptyDir {
+	images := make(map[string]int)
+	for _, file := range files {
+		path := file.Path
+		if file.Archive != "" {
+			path = file.Archive
+		}
+		images[filepath.Dir(path)]++
+	}
+
+	// Add the counts of every directory to all its recorded ancestors
+	totalImages := make(map[string]int)
+	totalFiles := make(map[string]int)
+	for dir, count := range dirEntries {
+		for d := dir; ; d = filepath.Dir(d) {
+			if _, ok := dirEntries[d]; !ok {
+				break
+			}
+			totalImages[d] += images[dir]
+			totalFiles[d] += count
+			if filepath.Dir(d) == d {
+				break
+			}
+		}
+	}
+
+	var empty []EmptyDir
+	for dir := range dirEntries {
+		if totalImages[dir] == 0 {
+			empty = append(empty, EmptyDir{Path: dir, Files: totalFiles[dir]})
+		}
+	}
+	sort.Slice(empty, func(i, j int) bool {
+		return empty[i].Path < empty[j].Path
+	})
+	return empty
+}
+
+// printEmptyDirs prints the directories without images, telling the empty ones apart from
+// those holding other files
+func printEmptyDirs(w io.Writer, dirs []EmptyDir) {
+	fmt.Fprintln(w, "\nDirectories without images:")
+	truly := 0
+	for _, dir := range dirs {
+		if dir.Files == 0 {
+			fmt.Fprintf(w, "%s: empty\n", pathText(dir.Path))
+			truly++
+		} else {
+			fmt.Fprintf(w, "%s: %s other files\n", pathText(dir.Path), formatInt(int64(dir.Files)))
+		}
+	}
+	fmt.Fprintf(w, "%s directories without images (%s empty)\n", formatInt(int64(len(dirs))), formatInt(int64(truly)))
+}
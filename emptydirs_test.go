@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestEmptyDirs(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "photos", "a.jpg"), "image")
+	writeFile(t, filepath.Join(root, "photos", "raw", "b.png"), "image")
+	writeFile(t, filepath.Join(root, "junk", "notes.txt"), "text")
+	writeFile(t, filepath.Join(root, "junk", "deeper", "more.txt"), "text")
+	writeFile(t, filepath.Join(root, "mixed", "readme.md"), "text")
+	writeFile(t, filepath.Join(root, "mixed", "inner", "c.gif"), "image")
+	for _, dir := range []string{"empty", filepath.Join("empty", "nested"), filepath.Join("photos", "unused")} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result := runJSON(t, "-empty-dirs", root)
+	want := []EmptyDir{
+		{Path: filepath.Join(root, "empty"), Files: 0},
+		{Path: filepath.Join(root, "empty", "nested"), Files: 0},
+		{Path: filepath.Join(root, "junk"), Files: 2}, // counting the file in deeper
+		{Path: filepath.Join(root, "junk", "deeper"), Files: 1},
+		{Path: filepath.Join(root, "photos", "unused"), Files: 0},
+	}
+	if !slices.Equal(result.EmptyDirs, want) {
+		t.Errorf("got %+v, want %+v", result.EmptyDirs, want)
+	}
+	if result.TotalImages != 3 {
+		t.Errorf("got %d images, want 3", result.TotalImages)
+	}
+}
+
+func TestEmptyDirsArchive(t *testing.T) {
+	// An image inside an archive keeps the archive's directory off the list
+	dirs := map[string]int{"/r": 0, "/r/zips": 1}
+	files := []ImageFile{{Path: "/r/zips/photos.zip/a.jpg", Archive: "/r/zips/photos.zip"}}
+	if got := findEmptyDirs(dirs, files); len(got) != 0 {
+		t.Errorf("got %+v, want no directories", got)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Prefix of the environment variables giving the flags their defaults, like
+// IMAGE_SORTER_WORKERS=4 for -workers 4
+const envPrefix = "IMAGE_SORTER_"
+
+// Environment variable listing the directories to scan when none are given on the command
+// line, separated like PATH
+const rootEnv = envPrefix + "ROOT"
+
+// Flags that take a default from the environment or a -config file. Only those changing
+// what is scanned or how it is reported are listed; a stray variable or a shared config
+// must never turn on -yes, -move, -delete-dupes or anything else that changes files.
+var defaultFlags = map[string]bool{
+	"workers":         true,
+	"format":          true,
+	"pretty":          true,
+	"min-count":       true,
+	"top":             true,
+	"bytes":           true,
+	"no-sep":          true,
+	"relative":        true,
+	"color":           true,
+	"log-level":       true,
+	"ext":             true,
+	"exclude-ext":     true,
+	"include-glob":    true,
+	"exclude-glob":    true,
+	"include-video":   true,
+	"max-depth":       true,
+	"follow-symlinks": true,
+	"io-retries":      true,
+	"io-backoff":      true,
+}
+
+// envName returns the environment variable for the flag called name
+func envName(name string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// applyEnv sets each of the defaultFlags in fs that wasn't given on the command line from its
+// environment variable, if that is set. Flags given and values from a -config file applied later both
+// leave these alone, so the command line wins over the environment, which wins over the
+// config file.
+func applyEnv(fs *flag.FlagSet) error {
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		value, ok := os.LookupEnv(envName(f.Name))
+		if !ok || !defaultFlags[f.Name] || given[f.Name] || err != nil {
+			return
+		}
+		if setErr := fs.Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("%s: %w", envName(f.Name), setErr)
+		}
+	})
+	return err
+}
+
+// rootArgs returns the directories given on the command line, or else those in rootEnv
+func rootArgs(args []string) []string {
+	if len(args) > 0 {
+		return args
+	}
+	var roots []string
+	for _, root := range strings.Split(os.Getenv(rootEnv), string(os.PathListSeparator)) {
+		if root != "" {
+			roots = append(roots, root)
+		}
+	}
+	return roots
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// newTestFlags returns a flag set with -workers, -format and -yes, parsed from args, with
+// the environment applied and then the config file at configPath, if it isn't ""
+func newTestFlags(t *testing.T, args []string, configPath string) (workers *int, format *string, yes *bool) {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	workers = fs.Int("workers", 1, "")
+	format = fs.String("format", "text", "")
+	yes = fs.Bool("yes", false, "")
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyEnv(fs); err != nil {
+		t.Fatal(err)
+	}
+	if configPath != "" {
+		if err := loadConfig(configPath, fs); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return workers, format, yes
+}
+
+func TestFlagPrecedence(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "config.json")
+	writeFile(t, config, `{"flags": {"workers": 3, "format": "markdown"}}`)
+
+	tests := []struct {
+		name    string
+		args    []string
+		env     string // IMAGE_SORTER_WORKERS, unset if ""
+		config  string
+		workers int
+	}{
+		{"default", nil, "", "", 1},
+		{"config over default", nil, "", config, 3},
+		{"env over config", nil, "2", config, 2},
+		{"flag over env", []string{"-workers", "5"}, "2", config, 5},
+		{"flag over config", []string{"-workers", "5"}, "", config, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.env != "" {
+				t.Setenv("IMAGE_SORTER_WORKERS", tt.env)
+			}
+			workers, format, _ := newTestFlags(t, tt.args, tt.config)
+			if *workers != tt.workers {
+				t.Errorf("got -workers %d, want %d", *workers, tt.workers)
+			}
+			if want := map[bool]string{true: "markdown", false: "text"}[tt.config != ""]; *format != want {
+				t.Errorf("got -format %q, want %q", *format, want)
+			}
+		})
+	}
+}
+
+func TestEnvIgnoresDestructiveFlags(t *testing.T) {
+	t.Setenv("IMAGE_SORTER_YES", "true")
+	t.Setenv("IMAGE_SORTER_FORMAT", "json")
+	_, format, yes := newTestFlags(t, nil, "")
+	if *yes {
+		t.Error("IMAGE_SORTER_YES set -yes")
+	}
+	if *format != "json" {
+		t.Errorf("got -format %q, want json from IMAGE_SORTER_FORMAT", *format)
+	}
+}
+
+func TestEnvInvalidValue(t *testing.T) {
+	t.Setenv("IMAGE_SORTER_WORKERS", "many")
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Int("workers", 1, "")
+	if err := applyEnv(fs); err == nil {
+		t.Error("no error for IMAGE_SORTER_WORKERS=many")
+	}
+}
+
+func TestRootArgs(t *testing.T) {
+	t.Setenv(rootEnv, "a"+string(os.PathListSeparator)+string(os.PathListSeparator)+"b")
+	if got := rootArgs(nil); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("from %s: got %q, want [a b]", rootEnv, got)
+	}
+	if got := rootArgs([]string{"c"}); !reflect.DeepEqual(got, []string{"c"}) {
+		t.Errorf("with an argument: got %q, want [c]", got)
+	}
+}
+
+func TestRootFromEnv(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "a.jpg"), "a")
+	t.Setenv(rootEnv, root)
+	if result := runJSON(t); result.TotalImages != 1 {
+		t.Errorf("got %d images, want 1 from the %s directory", result.TotalImages, rootEnv)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// fileOrders compares two images by each -sort-by key, breaking ties by path so the listing
+// is the same on every run
+var fileOrders = map[string]func(a, b ImageFile) int{
+	"path": func(a, b ImageFile) int { return cmp.Compare(a.Path, b.Path) },
+	"size": func(a, b ImageFile) int { return cmp.Or(cmp.Compare(a.Size, b.Size), cmp.Compare(a.Path, b.Path)) },
+	"date": func(a, b ImageFile) int { return cmp.Or(a.ModTime.Compare(b.ModTime), cmp.Compare(a.Path, b.Path)) },
+	"name": func(a, b ImageFile) int {
+		return cmp.Or(cmp.Compare(strings.ToLower(filepath.Base(a.Path)), strings.ToLower(filepath.Base(b.Path))), cmp.Compare(a.Path, b.Path))
+	},
+}
+
+// sortedFiles returns a copy of files ordered by the -sort-by key, which must be one of
+// fileOrders, from the smallest, oldest or first in the alphabet, or the other way around
+// with reverse set. Dates are modification times.
+func sortedFiles(files []ImageFile, key string, reverse bool) []ImageFile {
+	order := fileOrders[key]
+	sorted := slices.Clone(files)
+	slices.SortFunc(sorted, func(a, b ImageFile) int {
+		if reverse {
+			return order(b, a)
+		}
+		return order(a, b)
+	})
+	return sorted
+}
+
+// checkSortKey returns an error unless key is a -sort-by key
+func checkSortKey(key string) error {
+	if _, ok := fileOrders[key]; !ok {
+		return fmt.Errorf("unknown key %q: must be path, size, date or name", key)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSortedFiles(t *testing.T) {
+	day := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	files := []ImageFile{
+		{Path: "/p/b/Zebra.jpg", Size: 30, ModTime: day},
+		{Path: "/p/a/apple.png", Size: 10, ModTime: day.Add(2 * time.Hour)},
+		{Path: "/p/c/mango.gif", Size: 20, ModTime: day.Add(-time.Hour)},
+		{Path: "/p/a/zebra.jpg", Size: 10, ModTime: day}, // ties a size, a date and a name
+	}
+	tests := []struct {
+		key  string
+		want []string
+	}{
+		{"path", []string{"/p/a/apple.png", "/p/a/zebra.jpg", "/p/b/Zebra.jpg", "/p/c/mango.gif"}},
+		{"size", []string{"/p/a/apple.png", "/p/a/zebra.jpg", "/p/c/mango.gif", "/p/b/Zebra.jpg"}},
+		{"date", []string{"/p/c/mango.gif", "/p/a/zebra.jpg", "/p/b/Zebra.jpg", "/p/a/apple.png"}},
+		{"name", []string{"/p/a/apple.png", "/p/c/mango.gif", "/p/a/zebra.jpg", "/p/b/Zebra.jpg"}},
+	}
+	for _, tt := range tests {
+		got := filePaths(sortedFiles(files, tt.key, false))
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.key, got, tt.want)
+		}
+		got = filePaths(sortedFiles(files, tt.key, true))
+		want := slices.Clone(tt.want)
+		slices.Reverse(want)
+		if !slices.Equal(got, want) {
+			t.Errorf("%s reversed: got %q, want %q", tt.key, got, want)
+		}
+	}
+	if files[0].Path != "/p/b/Zebra.jpg" {
+		t.Error("sortedFiles reordered its argument")
+	}
+}
+
+// filePaths returns the paths of files
+func filePaths(files []ImageFile) []string {
+	paths := make([]string, len(files))
+	for i, file := range files {
+		paths[i] = file.Path
+	}
+	return paths
+}
+
+func TestSortByFlag(t *testing.T) {
+	root := t.TempDir()
+	for i, name := range []string{"small.jpg", "sub/large.jpg", "medium.png"} {
+		writeFile(t, filepath.Join(root, name), strings.Repeat("x", []int{1, 100, 10}[i]))
+	}
+
+	stdout, stderr, code := runCLI(t, "-sort-by", "size", "-reverse", root)
+	if code != exitOK {
+		t.Fatalf("exit code %d, stderr:\n%s", code, stderr)
+	}
+	var listed []string
+	for _, line := range strings.Split(stdout, "\n") {
+		if path, ok := strings.CutPrefix(line, "File: "); ok {
+			path, _, _ = strings.Cut(path, " | ")
+			listed = append(listed, filepath.Base(path))
+		}
+	}
+	if want := []string{"large.jpg", "medium.png", "small.jpg"}; !slices.Equal(listed, want) {
+		t.Errorf("got %q, want %q", listed, want)
+	}
+
+	for _, args := range [][]string{{"-sort-by", "color"}, {"-reverse"}} {
+		if _, stderr, code := runCLI(t, append(args, root)...); code != exitUsage {
+			t.Errorf("%q: got exit code %d, want %d; stderr:\n%s", args, code, exitUsage, stderr)
+		}
+	}
+}
+
+func TestCheckSortKey(t *testing.T) {
+	for key := range fileOrders {
+		if err := checkSortKey(key); err != nil {
+			t.Errorf("%s: %v", key, err)
+		}
+	}
+	if err := checkSortKey("Size"); err == nil {
+		t.Error("Size: no error, want an unknown key")
+	}
+}
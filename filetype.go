@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/kwdowicz/image_sorter/scanner"
+)
+
+// How many leading bytes identify a file's real format; also all http.DetectContentType reads
+const sniffLen = 512
+
+// Magic numbers of the formats we can recognize by content, checked in order
+var magicNumbers = []struct {
+	format string
+	offset int
+	magic  []byte
+}{
+	{"jpg", 0, []byte{0xFF, 0xD8, 0xFF}},
+	{"png", 0, []byte("\x89PNG\r\n\x1a\n")},
+	{"gif", 0, []byte("GIF8")},
+	{"bmp", 0, []byte("BM")},
+	{"tiff", 0, []byte("II*\x00")},
+	{"tiff", 0, []byte("MM\x00*")},
+	{"orf", 0, []byte("IIRO")}, // Olympus RAW uses its own TIFF variant
+	{"rw2", 0, []byte("IIU\x00")},
+	{"webp", 8, []byte("WEBP")},
+	{"heic", 4, []byte("ftypheic")},
+	{"heic", 4, []byte("ftypheix")},
+	{"heic", 4, []byte("ftyphevc")},
+	{"heic", 4, []byte("ftypmif1")},
+	{"heic", 4, []byte("ftypmsf1")},
+}
+
+// Formats each extension may legitimately contain. Most RAW formats are TIFF containers,
+// and HEIC and HEIF are the same container.
+var declaredFormats = map[string][]string{
+	".jpg":  {"jpg"},
+	".jpeg": {"jpg"},
+	".png":  {"png"},
+	".gif":  {"gif"},
+	".bmp":  {"bmp"},
+	".tiff": {"tiff"},
+	".tif":  {"tiff"},
+	".webp": {"webp"},
+	".heic": {"heic"},
+	".heif": {"heic"},
+	".svg":  {"svg"},
+	".cr2":  {"tiff"},
+	".nef":  {"tiff"},
+	".arw":  {"tiff"},
+	".sr2":  {"tiff"},
+	".dng":  {"tiff"},
+	".orf":  {"orf", "tiff"},
+	".rw2":  {"rw2", "tiff"},
+}
+
+// TypeMismatch is an image whose content doesn't match the format its extension claims
+type TypeMismatch struct {
+	Path     string `json:"path"`
+	Declared string `json:"declared"`
+	Detected string `json:"detected"`
+}
+
+// detectFormat returns the format of the data read from the start of a file: the name of
+// a recognized image format like "png", or otherwise the MIME type http.DetectContentType
+// reports for it without parameters, e.g. "text/html" for an error page saved as an image
+func detectFormat(head []byte) string {
+	for _, m := range magicNumbers {
+		if len(head) >= m.offset+len(m.magic) && bytes.Equal(head[m.offset:m.offset+len(m.magic)], m.magic) {
+			return m.format
+		}
+	}
+
+	contentType, _, _ := strings.Cut(http.DetectContentType(head), ";")
+	if (contentType == "text/xml" || contentType == "text/plain") && bytes.Contains(bytes.ToLower(head), []byte("<svg")) {
+		return "svg"
+	}
+	return contentType
+}
+
+// sniffFile reads the first sniffLen bytes of the file at path and detects their format
+func sniffFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return detectFormat(head[:n]), nil
+}
+
+// customFormat returns the handler registered for ext if it is not one of ours, like one
+// added by a program using the scanner package with scanner.RegisterFormat
+func customFormat(ext string) (scanner.FormatHandler, bool) {
+	handler, ok := scanner.LookupFormat(ext)
+	if _, builtin := handler.(builtinFormat); !ok || builtin {
+		return nil, false
+	}
+	return handler, true
+}
+
+// verifyTypes sniffs the content of every image whose extension we know the formats of, or
+// which has a custom format handler to validate it, using the given number of worker
+// goroutines. It returns those whose content is another format, in the order of files.
+// Unreadable files are reported to stderr and left out.
+func verifyTypes(files []ImageFile, workers int) []TypeMismatch {
+	if workers < 1 {
+		workers = 1
+	}
+
+	// Each worker writes only the entries for the indexes it receives
+	detected := make([]string, len(files))
+	invalid := make([]bool, len(files))
+	errs := make([]error, len(files))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				detected[i], errs[i] = sniffFile(files[i].Path)
+				if handler, ok := customFormat(files[i].Ext); ok && errs[i] == nil {
+					invalid[i] = handler.Validate(files[i].Path) != nil
+				}
+			}
+		}()
+	}
+
+	for i, file := range files {
+		if _, custom := customFormat(file.Ext); custom || declaredFormats[file.Ext] != nil {
+			indexes <- i
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	var mismatches []TypeMismatch
+	for i, file := range files {
+		allowed := declaredFormats[file.Ext]
+		_, custom := customFormat(file.Ext)
+		if allowed == nil && !custom {
+			continue
+		}
+		if errs[i] != nil {
+			logger.Error("reading failed", "path", file.Path, "err", errs[i])
+			continue
+		}
+		if (custom && invalid[i]) || (!custom && !slices.Contains(allowed, detected[i])) {
+			mismatches = append(mismatches, TypeMismatch{
+				Path:     file.Path,
+				Declared: strings.TrimPrefix(file.Ext, "."),
+				Detected: detected[i],
+			})
+		}
+	}
+	return mismatches
+}
+
+// printTypeMismatches prints the images whose content doesn't match their extension
+func printTypeMismatches(w io.Writer, mismatches []TypeMismatch) {
+	fmt.Fprintln(w, "\nImages whose content doesn't match their extension:")
+	for _, m := range mismatches {
+		fmt.Fprintf(w, "%s: declared=%s detected=%s\n", pathText(m.Path), m.Declared, m.Detected)
+	}
+	fmt.Fprintf(w, "%d mismatched\n", len(mismatches))
+}
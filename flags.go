@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// stringList is a flag that may be given several times, collecting every value in order
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// optionalBool is a boolean flag that also records whether it was given, for flags whose
+// default depends on other flags
+type optionalBool struct {
+	value bool
+	set   bool
+}
+
+func (b *optionalBool) String() string {
+	return strconv.FormatBool(b.value)
+}
+
+func (b *optionalBool) Set(value string) error {
+	v, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	b.value, b.set = v, true
+	return nil
+}
+
+func (b *optionalBool) IsBoolFlag() bool { return true }
+
+// or returns the flag's value if it was given and def otherwise
+func (b *optionalBool) or(def bool) bool {
+	if b.set {
+		return b.value
+	}
+	return def
+}
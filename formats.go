@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kwdowicz/image_sorter/scanner"
+)
+
+// builtinFormat reads the formats image_sorter ships with: it checks their content by magic
+// number, reads HEIC/HEIF dimensions from the container and others' with image.DecodeConfig,
+// and takes capture times from EXIF for the formats carrying it
+type builtinFormat struct {
+	ext string
+}
+
+// Replaces the scanner's plain handlers for the built-in formats, and for the extensions a
+// -config file adds
+func init() {
+	for ext := range scanner.DefaultExtensions {
+		scanner.RegisterFormat(ext, builtinFormat{ext: ext})
+	}
+}
+
+// Validate checks the file's leading bytes against the formats its extension may contain.
+// Extensions without known magic numbers accept any content.
+func (f builtinFormat) Validate(path string) error {
+	allowed := declaredFormats[f.ext]
+	if allowed == nil {
+		return nil
+	}
+	detected, err := sniffFile(path)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(allowed, detected) {
+		return fmt.Errorf("content is %s, not %s", detected, strings.TrimPrefix(f.ext, "."))
+	}
+	return nil
+}
+
+// Dimensions reads the pixel width and height from the image header
+func (f builtinFormat) Dimensions(path string) (width, height int, err error) {
+	if f.ext != ".heic" && f.ext != ".heif" {
+		return scanner.HeaderFormat{}.Dimensions(path)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+	return heifDimensions(file)
+}
+
+// CaptureTime reads the EXIF DateTimeOriginal tag
+func (f builtinFormat) CaptureTime(path string) (time.Time, error) {
+	if !exifExtensions[f.ext] {
+		return time.Time{}, scanner.ErrNotSupported
+	}
+	// A partially corrupt EXIF block can still carry a usable date, so only x is checked
+	x, err := readExif(path)
+	if x == nil {
+		return time.Time{}, err
+	}
+	return x.DateTime()
+}
+
+// formatOf returns the handler for the image at path, falling back to a plain
+// scanner.HeaderFormat for extensions nothing was registered for
+func formatOf(path string) scanner.FormatHandler {
+	if handler, ok := scanner.LookupFormat(strings.ToLower(filepath.Ext(path))); ok {
+		return handler
+	}
+	return scanner.HeaderFormat{}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Grid cells per degree of latitude and longitude that -by-geo counts images in, so a cell
+// is 0.1° on a side: about 11 km north to south
+const geoCellsPerDegree = 10
+
+// Group of the images -by-geo finds no GPS position for
+const noGPSGroup = "unknown"
+
+// LocationCount holds the number of images taken inside one -by-geo grid cell, identified by
+// its south-west corner
+type LocationCount struct {
+	Lat   float64 `json:"lat"`
+	Lon   float64 `json:"lon"`
+	Count int     `json:"count"`
+}
+
+// readGPS returns the position in the EXIF GPS block of the JPEG or HEIC/HEIF image at path.
+// Images without one, and those reporting exactly 0°, 0° as some cameras do before they
+// have a fix, return false.
+func readGPS(path string) (lat, lon float64, ok bool) {
+	if !exifExtensions[strings.ToLower(filepath.Ext(path))] {
+		return 0, 0, false
+	}
+	x, err := readExif(path)
+	if x == nil {
+		return 0, 0, false
+	}
+	lat, lon, err = x.LatLong()
+	if err != nil || (lat == 0 && lon == 0) || math.IsNaN(lat) || math.IsNaN(lon) {
+		return 0, 0, false
+	}
+	return lat, lon, true
+}
+
+// geoCell groups an image by the grid cell its GPS position lies in, as "lat,lon" of the
+// cell's south-west corner in whole cells, or noGPSGroup if it has no position
+func geoCell(path string) string {
+	lat, lon, ok := readGPS(path)
+	if !ok {
+		return noGPSGroup
+	}
+	return fmt.Sprintf("%d,%d", int(math.Floor(lat*geoCellsPerDegree)), int(math.Floor(lon*geoCellsPerDegree)))
+}
+
+// sortLocationCounts turns the counts per geoCell group into grid cells with the most
+// images first, and returns them along with the number of images without a position
+func sortLocationCounts(cellCount map[string]int) (locations []LocationCount, noGPS int) {
+	for cell, count := range cellCount {
+		latText, lonText, found := strings.Cut(cell, ",")
+		lat, latErr := strconv.Atoi(latText)
+		lon, lonErr := strconv.Atoi(lonText)
+		if !found || latErr != nil || lonErr != nil {
+			noGPS += count
+			continue
+		}
+		locations = append(locations, LocationCount{
+			Lat:   float64(lat) / geoCellsPerDegree,
+			Lon:   float64(lon) / geoCellsPerDegree,
+			Count: count,
+		})
+	}
+	sort.Slice(locations, func(i, j int) bool {
+		if locations[i].Count != locations[j].Count {
+			return locations[i].Count > locations[j].Count
+		}
+		if locations[i].Lat != locations[j].Lat {
+			return locations[i].Lat < locations[j].Lat
+		}
+		return locations[i].Lon < locations[j].Lon
+	})
+	return locations, noGPS
+}
+
+// printLocationCounts prints the images per grid cell and how many had no GPS position
+func printLocationCounts(w io.Writer, locations []LocationCount, noGPS int) {
+	fmt.Fprintf(w, "\nImage files by location (%g° grid cells, south-west corner):\n", 1.0/geoCellsPerDegree)
+	for _, lc := range locations {
+		fmt.Fprintf(w, "Location: %.1f, %.1f | Image Files: %s\n", lc.Lat, lc.Lon, formatInt(int64(lc.Count)))
+	}
+	fmt.Fprintf(w, "No GPS data: %s\n", formatInt(int64(noGPS)))
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Central London and Warsaw, as a camera would record them
+var (
+	londonGPS = gpsEntries("N", 51, 30, 2640, "W", 0, 7, 3960)
+	warsawGPS = gpsEntries("N", 52, 13, 4860, "E", 21, 0, 4200)
+)
+
+func TestReadGPS(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"london.jpg":  exifJPEG(t, 8, 8, nil, londonGPS),
+		"no-gps.jpg":  exifJPEG(t, 8, 8, []exifEntry{shortEntry(0x112, 1)}, nil),
+		"no-exif.jpg": jpegBytes(t, 8, 8),
+		"null.jpg":    exifJPEG(t, 8, 8, nil, gpsEntries("N", 0, 0, 0, "E", 0, 0, 0)), // no fix yet
+		"london.png":  exifJPEG(t, 8, 8, nil, londonGPS),                              // not looked into
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lat, lon, ok := readGPS(filepath.Join(dir, "london.jpg"))
+	if !ok || math.Abs(lat-51.50733) > 1e-4 || math.Abs(lon+0.12767) > 1e-4 {
+		t.Errorf("london.jpg: got %v, %v, %v, want 51.50733, -0.12767, true", lat, lon, ok)
+	}
+	if got := geoCell(filepath.Join(dir, "london.jpg")); got != "515,-2" {
+		t.Errorf("london.jpg: got cell %q, want 515,-2", got)
+	}
+	for _, name := range []string{"no-gps.jpg", "no-exif.jpg", "null.jpg", "london.png"} {
+		if _, _, ok := readGPS(filepath.Join(dir, name)); ok {
+			t.Errorf("%s: got a position, want none", name)
+		}
+		if got := geoCell(filepath.Join(dir, name)); got != noGPSGroup {
+			t.Errorf("%s: got cell %q, want %q", name, got, noGPSGroup)
+		}
+	}
+}
+
+// geoTree writes two images taken in London, one in Warsaw and one without a position
+func geoTree(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	files := map[string][]byte{
+		"trip/a.jpg":  exifJPEG(t, 8, 8, nil, londonGPS),
+		"trip/b.jpg":  exifJPEG(t, 8, 8, nil, londonGPS),
+		"home/c.jpeg": exifJPEG(t, 8, 8, nil, warsawGPS),
+		"home/d.jpg":  jpegBytes(t, 8, 8),
+	}
+	for name, data := range files {
+		writeFile(t, filepath.Join(root, name), string(data))
+	}
+	return root
+}
+
+func TestByGeo(t *testing.T) {
+	result := runJSON(t, "-by-geo", geoTree(t))
+	want := []LocationCount{{Lat: 51.5, Lon: -0.2, Count: 2}, {Lat: 52.2, Lon: 21, Count: 1}}
+	if !slices.Equal(result.Locations, want) || result.NoGPS != 1 {
+		t.Errorf("got %+v and %d without GPS, want %+v and 1", result.Locations, result.NoGPS, want)
+	}
+}
+
+func TestByGeoOutput(t *testing.T) {
+	root := geoTree(t)
+
+	stdout, stderr, code := runCLI(t, "-by-geo", "-sort-by", "path", root)
+	if code != exitOK {
+		t.Fatalf("exit code %d, stderr:\n%s", code, stderr)
+	}
+	for _, want := range []string{
+		"File: " + filepath.Join(root, "trip", "a.jpg") + " | Size: ",
+		"GPS: 51.50733, -0.12767",
+		"| GPS: none",
+		"Location: 51.5, -0.2 | Image Files: 2",
+		"No GPS data: 1",
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("output lacks %q:\n%s", want, stdout)
+		}
+	}
+
+	stdout, stderr, code = runCLI(t, "-by-geo", "-format", "jsonl", root)
+	if code != exitOK {
+		t.Fatalf("jsonl: exit code %d, stderr:\n%s", code, stderr)
+	}
+	positions := 0
+	for _, line := range strings.Split(strings.TrimSpace(stdout), "\n") {
+		var record jsonlImage
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("decoding %q: %v", line, err)
+		}
+		if record.Type != "image" {
+			continue
+		}
+		if (record.Lat != nil) != (filepath.Base(record.Path) != "d.jpg") {
+			t.Errorf("%s: got lat %v", record.Path, record.Lat)
+		}
+		if record.Lat != nil {
+			positions++
+		}
+	}
+	if positions != 3 {
+		t.Errorf("got %d records with a position, want 3", positions)
+	}
+}
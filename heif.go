@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// How many bytes of a box's payload we are willing to read into memory while looking for
+// the image properties; the meta box holding them is normally a few KB
+const heifMetaLimit = 1 << 20
+
+// heifDimensions reads the pixel width and height of a HEIC/HEIF image from the image spatial
+// extents ("ispe") property of its primary item, walking the ISO BMFF box structure
+// meta → iprp → ipco without decoding any pixels
+func heifDimensions(r io.Reader) (width, height int, err error) {
+	meta, err := findBox(r, "meta")
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(meta) < 4 {
+		return 0, 0, errors.New("truncated meta box")
+	}
+	meta = meta[4:] // meta is a full box: skip version and flags
+
+	primary, hasPrimary := uint32(0), false
+	if pitm, ok := childBox(meta, "pitm"); ok && len(pitm) >= 6 {
+		if pitm[0] == 0 {
+			primary = uint32(binary.BigEndian.Uint16(pitm[4:]))
+		} else if len(pitm) >= 8 {
+			primary = binary.BigEndian.Uint32(pitm[4:])
+		}
+		hasPrimary = true
+	}
+
+	iprp, ok := childBox(meta, "iprp")
+	if !ok {
+		return 0, 0, errors.New("no item properties box")
+	}
+	ipco, ok := childBox(iprp, "ipco")
+	if !ok {
+		return 0, 0, errors.New("no item property container")
+	}
+	properties := childBoxes(ipco)
+
+	// Without an association map, fall back to the largest extent, which for grid images is
+	// the full image rather than a tile
+	candidates := make([]int, 0, len(properties))
+	if ipma, ok := childBox(iprp, "ipma"); ok && hasPrimary {
+		candidates = itemProperties(ipma, primary)
+	} else {
+		for i := range properties {
+			candidates = append(candidates, i)
+		}
+	}
+
+	for _, i := range candidates {
+		if i < 0 || i >= len(properties) || properties[i].kind != "ispe" || len(properties[i].data) < 12 {
+			continue
+		}
+		w := int(binary.BigEndian.Uint32(properties[i].data[4:]))
+		h := int(binary.BigEndian.Uint32(properties[i].data[8:]))
+		if w*h > width*height {
+			width, height = w, h
+		}
+	}
+	if width == 0 || height == 0 {
+		return 0, 0, errors.New("no image spatial extents property")
+	}
+	return width, height, nil
+}
+
+// box is a single ISO BMFF box: its four-character type and its payload
+type box struct {
+	kind string
+	data []byte
+}
+
+// findBox reads top-level boxes from r until it finds one of the given type and returns
+// its payload, skipping the payloads of all others
+func findBox(r io.Reader, kind string) ([]byte, error) {
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return nil, err
+		}
+		size := uint64(binary.BigEndian.Uint32(header))
+		name := string(header[4:8])
+		headerLen := uint64(8)
+		if size == 1 {
+			// 64-bit size follows the type
+			if _, err := io.ReadFull(r, header[:8]); err != nil {
+				return nil, err
+			}
+			size = binary.BigEndian.Uint64(header)
+			headerLen = 16
+		}
+		if size != 0 && size < headerLen {
+			return nil, errors.New("invalid box size")
+		}
+
+		if name == kind {
+			if size == 0 || size-headerLen > heifMetaLimit {
+				return nil, errors.New(kind + " box too large")
+			}
+			data := make([]byte, size-headerLen)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return nil, err
+			}
+			return data, nil
+		}
+		if size == 0 {
+			return nil, errors.New("no " + kind + " box")
+		}
+		if _, err := io.CopyN(io.Discard, r, int64(size-headerLen)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// childBoxes splits a container box's payload into its child boxes, stopping at the first
+// malformed one
+func childBoxes(data []byte) []box {
+	var boxes []box
+	for len(data) >= 8 {
+		size := uint64(binary.BigEndian.Uint32(data))
+		headerLen := uint64(8)
+		if size == 1 {
+			if len(data) < 16 {
+				break
+			}
+			size = binary.BigEndian.Uint64(data[8:])
+			headerLen = 16
+		} else if size == 0 {
+			size = uint64(len(data))
+		}
+		if size < headerLen || size > uint64(len(data)) {
+			break
+		}
+		boxes = append(boxes, box{kind: string(data[4:8]), data: data[headerLen:size]})
+		data = data[size:]
+	}
+	return boxes
+}
+
+// childBox returns the payload of the first child box of the given type
+func childBox(data []byte, kind string) ([]byte, bool) {
+	for _, b := range childBoxes(data) {
+		if b.kind == kind {
+			return b.data, true
+		}
+	}
+	return nil, false
+}
+
+// itemProperties returns the zero-based ipco indexes of the properties the item property
+// association ("ipma") box links to item
+func itemProperties(ipma []byte, item uint32) []int {
+	if len(ipma) < 8 {
+		return nil
+	}
+	version, wideIndex := ipma[0], ipma[3]&1 != 0
+	count := binary.BigEndian.Uint32(ipma[4:])
+	data := ipma[8:]
+
+	for ; count > 0; count-- {
+		var id uint32
+		if version < 1 {
+			if len(data) < 2 {
+				return nil
+			}
+			id, data = uint32(binary.BigEndian.Uint16(data)), data[2:]
+		} else {
+			if len(data) < 4 {
+				return nil
+			}
+			id, data = binary.BigEndian.Uint32(data), data[4:]
+		}
+		if len(data) < 1 {
+			return nil
+		}
+		n := int(data[0])
+		data = data[1:]
+
+		var indexes []int
+		for ; n > 0; n-- {
+			// The top bit marks the property as essential; the rest is a one-based index
+			var index int
+			if wideIndex {
+				if len(data) < 2 {
+					return nil
+				}
+				index, data = int(binary.BigEndian.Uint16(data)&0x7fff), data[2:]
+			} else {
+				if len(data) < 1 {
+					return nil
+				}
+				index, data = int(data[0]&0x7f), data[1:]
+			}
+			indexes = append(indexes, index-1)
+		}
+		if id == item {
+			return indexes
+		}
+	}
+	return nil
+}
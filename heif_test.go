@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"path/filepath"
+	"testing"
+)
+
+// bmffBox returns an ISO BMFF box of the given type holding the concatenated payloads
+func bmffBox(kind string, payload ...[]byte) []byte {
+	data := bytes.Join(payload, nil)
+	b := binary.BigEndian.AppendUint32(nil, uint32(8+len(data)))
+	return append(append(b, kind...), data...)
+}
+
+// ispe returns an image spatial extents property of w×h pixels
+func ispe(w, h uint32) []byte {
+	return bmffBox("ispe", []byte{0, 0, 0, 0}, binary.BigEndian.AppendUint32(nil, w), binary.BigEndian.AppendUint32(nil, h))
+}
+
+// heifFile returns a HEIF file whose primary item 1 is associated with the one-based
+// property indexes given, out of a 512×512 tile extent and a 4032×3024 full image extent.
+// Without indexes there is no association box.
+func heifFile(indexes ...byte) []byte {
+	iprp := [][]byte{bmffBox("ipco", ispe(512, 512), ispe(4032, 3024))}
+	if len(indexes) > 0 {
+		// Version 0 with narrow indexes: one entry for item 1 with its properties
+		ipma := append([]byte{0, 0, 0, 0, 0, 0, 0, 1, 0, 1, byte(len(indexes))}, indexes...)
+		iprp = append(iprp, bmffBox("ipma", ipma))
+	}
+	meta := bmffBox("meta", []byte{0, 0, 0, 0}, bmffBox("pitm", []byte{0, 0, 0, 0, 0, 1}), bmffBox("iprp", iprp...))
+	return append(bmffBox("ftyp", []byte("heic"), []byte{0, 0, 0, 0}, []byte("mif1heic")), meta...)
+}
+
+func TestHEIFDimensions(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		w, h int
+	}{
+		{"primary item's extent", heifFile(0x80 | 2), 4032, 3024}, // the top bit marks it essential
+		{"primary item is a tile", heifFile(1), 512, 512},
+		{"no association, largest extent", heifFile(), 4032, 3024},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "image.heic")
+			writeFile(t, path, string(tt.data))
+			w, h, err := imageDimensions(path)
+			if err != nil || w != tt.w || h != tt.h {
+				t.Errorf("got %d×%d, %v; want %d×%d", w, h, err, tt.w, tt.h)
+			}
+		})
+	}
+
+	for name, data := range map[string][]byte{
+		"no meta box":  bmffBox("ftyp", []byte("heic")),
+		"no extents":   append(bmffBox("ftyp", []byte("heic")), bmffBox("meta", []byte{0, 0, 0, 0}, bmffBox("iprp", bmffBox("ipco")))...),
+		"cut off meta": heifFile(1)[:40],
+	} {
+		path := filepath.Join(t.TempDir(), "image.heif")
+		writeFile(t, path, string(data))
+		if w, h, err := imageDimensions(path); err == nil {
+			t.Errorf("%s: got %d×%d, want an error", name, w, h)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"html/template"
+	"io"
+	"strings"
+)
+
+// htmlReport is what htmlTemplate renders: the result with its numbers already formatted
+// the way the text report prints them
+type htmlReport struct {
+	TotalImages string
+	TotalSize   string
+	Sampled     bool
+	ZeroByte    string
+	Roots       [][]string
+	Categories  [][]string
+	Extensions  [][]string
+	Directories [][]string
+	DirSizes    []htmlBar
+	Largest     [][]string
+}
+
+// htmlBar is a row of the directory size chart, Percent being its share of the largest one
+type htmlBar struct {
+	Path    string
+	Size    string
+	Percent float64
+}
+
+// htmlTemplate lays out the -format html page. Everything is inline, so the file can be
+// opened or sent on its own, and html/template escapes the paths.
+var htmlTemplate = template.Must(template.New("-format html").Funcs(template.FuncMap{"table": htmlTable}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Image scan</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { padding: 0.25em 0.75em; border-bottom: 1px solid #ddd; text-align: left; }
+td.n { text-align: right; font-variant-numeric: tabular-nums; }
+.bar { background: #4a90d9; height: 0.9em; min-width: 1px; }
+.chart td:last-child { width: 20em; }
+</style>
+</head>
+<body>
+<h1>Image scan</h1>
+<ul>
+<li><strong>Total images:</strong> {{.TotalImages}}</li>
+<li><strong>Total size:</strong> {{.TotalSize}}</li>
+{{- if .Sampled}}
+<li><strong>Sample only:</strong> the scan stopped at the -sample limit</li>
+{{- end}}
+{{- if .ZeroByte}}
+<li><strong>Zero-byte images left out:</strong> {{.ZeroByte}}</li>
+{{- end}}
+</ul>
+{{- define "table"}}
+{{- if .Rows}}
+<h2>{{.Title}}</h2>
+<table>
+<tr>{{range .Header}}<th>{{.}}</th>{{end}}</tr>
+{{- range .Rows}}
+<tr>{{range $i, $cell := .}}{{if $i}}<td class="n">{{$cell}}</td>{{else}}<td>{{$cell}}</td>{{end}}{{end}}</tr>
+{{- end}}
+</table>
+{{- end}}
+{{- end}}
+{{template "table" (table "Roots" .Roots "Root" "Image Files" "Size")}}
+{{template "table" (table "Image files by category" .Categories "Category" "Image Files" "Size")}}
+{{template "table" (table "Image files by extension" .Extensions "Extension" "Image Files" "Size")}}
+{{template "table" (table "Directories sorted by number of image files" .Directories "Directory" "Image Files")}}
+{{- if .DirSizes}}
+<h2>Directories sorted by size of image files</h2>
+<table class="chart">
+<tr><th>Directory</th><th>Size</th><th></th></tr>
+{{- range .DirSizes}}
+<tr><td>{{.Path}}</td><td class="n">{{.Size}}</td><td><div class="bar" style="width: {{printf "%.1f" .Percent}}%"></div></td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{template "table" (table "Largest images" .Largest "File" "Size")}}
+</body>
+</html>
+`))
+
+// htmlTable bundles the arguments of the "table" template
+func htmlTable(title string, rows [][]string, header ...string) map[string]any {
+	return map[string]any{"Title": title, "Rows": rows, "Header": header}
+}
+
+// printHTML prints the result of -format html: a self-contained page with the totals, the
+// roots, categories and extensions, the accepted directories, a bar chart of the top
+// directories by size (at most top of them when top > 0) and the -largest images
+func printHTML(w io.Writer, result ScanResult, top int) error {
+	report := htmlReport{
+		TotalImages: formatInt(int64(result.TotalImages)),
+		TotalSize:   sizeText(result.TotalSize),
+		Sampled:     result.Sampled,
+	}
+	if len(result.ZeroByte) > 0 {
+		report.ZeroByte = formatInt(int64(len(result.ZeroByte)))
+	}
+	for _, rt := range result.Roots {
+		report.Roots = append(report.Roots, []string{pathText(rt.Root), formatInt(int64(rt.Images)), sizeText(rt.TotalSize)})
+	}
+	for _, ct := range result.Categories {
+		report.Categories = append(report.Categories, []string{ct.Category, formatInt(int64(ct.Count)), sizeText(ct.Size)})
+	}
+	for _, et := range result.Extensions {
+		report.Extensions = append(report.Extensions, []string{strings.TrimPrefix(et.Ext, "."), formatInt(int64(et.Count)), sizeText(et.Size)})
+	}
+	accepted := make(map[string]bool)
+	for _, dir := range result.AcceptedDirs {
+		accepted[dir] = true
+	}
+	for _, dc := range result.Directories {
+		if accepted[dc.Path] {
+			report.Directories = append(report.Directories, []string{pathText(dc.Path), formatInt(int64(dc.Count))})
+		}
+	}
+	for i, ds := range result.DirSizes {
+		if top > 0 && i >= top {
+			break
+		}
+		bar := htmlBar{Path: pathText(ds.Path), Size: sizeText(ds.Size)}
+		if largest := result.DirSizes[0].Size; largest > 0 {
+			bar.Percent = float64(ds.Size) * 100 / float64(largest)
+		}
+		report.DirSizes = append(report.DirSizes, bar)
+	}
+	for _, file := range result.Largest {
+		report.Largest = append(report.Largest, []string{pathText(file.Path), sizeText(file.Size)})
+	}
+	return htmlTemplate.Execute(w, report)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// htmlPage is what parseHTML finds on a -format html page
+type htmlPage struct {
+	items []string   // text of each list item
+	rows  [][]string // text of the cells of each table row with any
+	bars  []string   // style of each bar of the chart
+}
+
+// parseHTML reads the page with encoding/xml in its lenient HTML mode, failing unless every
+// element that was opened is closed again
+func parseHTML(t *testing.T, page string) htmlPage {
+	t.Helper()
+	d := xml.NewDecoder(strings.NewReader(page))
+	d.Strict = false
+	d.AutoClose = xml.HTMLAutoClose
+	d.Entity = xml.HTMLEntity
+
+	var result htmlPage
+	var open []string
+	var text strings.Builder
+	var row []string
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("parsing: %v\n%s", err, page)
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			open = append(open, tok.Name.Local)
+			switch tok.Name.Local {
+			case "li", "td", "th":
+				text.Reset()
+			case "tr":
+				row = nil
+			case "div":
+				for _, attr := range tok.Attr {
+					if attr.Name.Local == "style" {
+						result.bars = append(result.bars, attr.Value)
+					}
+				}
+			}
+		case xml.EndElement:
+			if len(open) == 0 || open[len(open)-1] != tok.Name.Local {
+				t.Fatalf("</%s> closes %q", tok.Name.Local, open)
+			}
+			open = open[:len(open)-1]
+			switch tok.Name.Local {
+			case "li":
+				result.items = append(result.items, text.String())
+			case "td":
+				row = append(row, text.String())
+			case "tr":
+				if row != nil {
+					result.rows = append(result.rows, row)
+				}
+			}
+		case xml.CharData:
+			text.Write(tok)
+		}
+	}
+	if len(open) > 0 {
+		t.Fatalf("unclosed elements %q", open)
+	}
+	return result
+}
+
+func TestHTMLFormat(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "a.jpg"), strings.Repeat("x", 4000))
+	writeFile(t, filepath.Join(root, "b.jpg"), strings.Repeat("x", 1000))
+	writeFile(t, filepath.Join(root, "sub", "c.png"), strings.Repeat("x", 2500))
+
+	stdout, stderr, code := runCLI(t, "-format", "html", "-min-count", "0", "-largest", "1", root)
+	if code != exitOK {
+		t.Fatalf("exit code %d, stderr:\n%s", code, stderr)
+	}
+	page := parseHTML(t, stdout)
+	for _, want := range []string{"Total images: 3", "Total size: " + sizeText(7500)} {
+		if !slices.Contains(page.items, want) {
+			t.Errorf("the totals %q lack %q", page.items, want)
+		}
+	}
+	for _, want := range [][]string{
+		{"Photo", "3", sizeText(7500)},
+		{"jpg", "2", sizeText(5000)},
+		{"png", "1", sizeText(2500)},
+		{pathText(root), "2"}, // directories by count
+		{pathText(filepath.Join(root, "sub")), "1"},
+		{pathText(root), sizeText(5000), ""}, // the chart
+		{pathText(filepath.Join(root, "a.jpg")), sizeText(4000)},
+	} {
+		if !slices.ContainsFunc(page.rows, func(row []string) bool { return slices.Equal(row, want) }) {
+			t.Errorf("no row %q in %q", want, page.rows)
+		}
+	}
+	if want := []string{"width: 100.0%", "width: 50.0%"}; !slices.Equal(page.bars, want) {
+		t.Errorf("got bars %q, want %q", page.bars, want)
+	}
+}
+
+func TestHTMLEscapesPaths(t *testing.T) {
+	path := `/photos/<script>alert("x")</script>&more`
+	var b strings.Builder
+	err := printHTML(&b, ScanResult{
+		TotalImages:  1,
+		Directories:  []DirCount{{Path: path, Count: 1}},
+		DirSizes:     []DirSize{{Path: path, Size: 10}},
+		AcceptedDirs: []string{path},
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(b.String(), "<script>") {
+		t.Fatalf("the path wasn't escaped:\n%s", b.String())
+	}
+	page := parseHTML(t, b.String())
+	if !slices.ContainsFunc(page.rows, func(row []string) bool { return row[0] == pathText(path) }) {
+		t.Errorf("no row for the path in %q", page.rows)
+	}
+}
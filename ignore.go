@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kwdowicz/image_sorter/scanner"
+)
+
+// Name of the optional per-root file listing extra directories to ignore
+const ignoreFileName = ".imagesorterignore"
+
+// readIgnoreFile returns the ignore patterns listed one per line in the file at path.
+// Blank lines and lines starting with # are skipped. A missing file yields no patterns.
+func readIgnoreFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns, scanner.Err()
+}
+
+// selectIgnoreDirs builds the list of ignored directory names for a scan of root: the
+// built-in scanner.DefaultIgnoreDirs (unless only is set), the comma-separated extra list, and the
+// entries of root's .imagesorterignore file
+func selectIgnoreDirs(root, extra string, only bool) ([]string, error) {
+	var dirs []string
+	if !only {
+		dirs = append(dirs, scanner.DefaultIgnoreDirs...)
+	}
+	for _, dir := range strings.Split(extra, ",") {
+		if dir = strings.TrimSpace(dir); dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	fromFile, err := readIgnoreFile(filepath.Join(root, ignoreFileName))
+	if err != nil {
+		return nil, err
+	}
+	return append(dirs, fromFile...), nil
+}
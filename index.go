@@ -0,0 +1,154 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+
+	_ "modernc.org/sqlite" // pure-Go SQLite driver, registered as "sqlite"
+)
+
+// Schema of the -db image index. mod_time is stored as Unix nanoseconds.
+const indexSchema = `CREATE TABLE IF NOT EXISTS images (
+	path     TEXT PRIMARY KEY,
+	size     INTEGER NOT NULL,
+	mod_time INTEGER NOT NULL,
+	hash     TEXT NOT NULL
+)`
+
+// IndexSummary counts what updateIndex did with the scanned files
+type IndexSummary struct {
+	Images    int   // images in the index under the scanned roots after the update
+	Size      int64 // total size of those images
+	Hashed    int   // new or changed files that were hashed
+	Unchanged int   // files whose size and modification time matched the index
+	Removed   int   // index entries under the scanned roots that no longer exist
+	Failed    int   // files that could not be hashed
+}
+
+// indexedFile is the stored state of a file in the index
+type indexedFile struct {
+	size    int64
+	modTime int64
+}
+
+// updateIndex records the scanned files in the SQLite database at dbPath. Files whose size and
+// modification time match their index entry keep their stored hash; all others are hashed.
+// Entries under the scanned roots that were not found by the scan are removed. With reindex
+// set the index is cleared first, so every file is hashed again.
+func updateIndex(dbPath string, roots []string, files []ImageFile, reindex bool) (IndexSummary, error) {
+	var summary IndexSummary
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return summary, err
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(indexSchema); err != nil {
+		return summary, err
+	}
+	if reindex {
+		if _, err := db.Exec(`DELETE FROM images`); err != nil {
+			return summary, err
+		}
+	}
+
+	known, err := loadIndex(db)
+	if err != nil {
+		return summary, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return summary, err
+	}
+	defer tx.Rollback()
+
+	seen := make(map[string]bool, len(files))
+	for _, file := range files {
+		seen[file.Path] = true
+		modTime := file.ModTime.UnixNano()
+		if entry, ok := known[file.Path]; ok && entry.size == file.Size && entry.modTime == modTime {
+			summary.Unchanged++
+			continue
+		}
+
+		hash, err := hashFile(file.Path)
+		if err != nil {
+			logger.Error("hashing failed", "path", file.Path, "err", err)
+			summary.Failed++
+			continue
+		}
+		_, err = tx.Exec(`INSERT INTO images (path, size, mod_time, hash) VALUES (?, ?, ?, ?)
+			ON CONFLICT(path) DO UPDATE SET size = excluded.size, mod_time = excluded.mod_time, hash = excluded.hash`,
+			file.Path, file.Size, modTime, hash)
+		if err != nil {
+			return summary, err
+		}
+		summary.Hashed++
+	}
+
+	for path := range known {
+		if !seen[path] && underAnyRoot(path, roots) {
+			if _, err := tx.Exec(`DELETE FROM images WHERE path = ?`, path); err != nil {
+				return summary, err
+			}
+			summary.Removed++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return summary, err
+	}
+
+	// Report the totals as stored in the freshly updated index
+	rows, err := db.Query(`SELECT path, size FROM images`)
+	if err != nil {
+		return summary, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var path string
+		var size int64
+		if err := rows.Scan(&path, &size); err != nil {
+			return summary, err
+		}
+		if underAnyRoot(path, roots) {
+			summary.Images++
+			summary.Size += size
+		}
+	}
+	return summary, rows.Err()
+}
+
+// loadIndex reads the size and modification time of every indexed file
+func loadIndex(db *sql.DB) (map[string]indexedFile, error) {
+	rows, err := db.Query(`SELECT path, size, mod_time FROM images`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	known := make(map[string]indexedFile)
+	for rows.Next() {
+		var path string
+		var entry indexedFile
+		if err := rows.Scan(&path, &entry.size, &entry.modTime); err != nil {
+			return nil, err
+		}
+		known[path] = entry
+	}
+	return known, rows.Err()
+}
+
+// underAnyRoot reports whether path lies inside one of the given root directories
+func underAnyRoot(path string, roots []string) bool {
+	for _, root := range roots {
+		rel, err := filepath.Rel(root, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"path/filepath"
+)
+
+// Number of image records written between flushes of the -format jsonl stream
+const jsonlFlushEvery = 1000
+
+// jsonlImage is the record streamed for each image with -format jsonl
+type jsonlImage struct {
+	Type string   `json:"type"` // always "image"
+	Path string   `json:"path"`
+	Size int64    `json:"size"`
+	Ext  string   `json:"ext"`
+	Dir  string   `json:"dir"`
+	Lat  *float64 `json:"lat,omitempty"` // GPS position, only looked up with -by-geo
+	Lon  *float64 `json:"lon,omitempty"`
+	Live *bool    `json:"live_photo,omitempty"` // whether the image is a Live Photo still, only looked up with -group-live
+}
+
+// jsonlSummary is the record that ends a -format jsonl stream
+type jsonlSummary struct {
+	Type        string `json:"type"`                  // always "summary"
+	Interrupted bool   `json:"interrupted,omitempty"` // the scan was cancelled and the totals are partial
+	ScanResult
+}
+
+// jsonlWriter streams one JSON object per line to w as images are found. A record is
+// never split across flushes, so whatever reached w so far is valid JSON Lines even
+// if the process dies. After the first write error nothing more is written, and summary
+// returns that error.
+type jsonlWriter struct {
+	w       *bufio.Writer
+	gps     bool // add the GPS position of images to their records
+	live    bool // add whether images are Live Photo stills to their records
+	records int
+	err     error
+}
+
+// write appends a record to the stream
+func (s *jsonlWriter) write(record any) {
+	if s.err != nil {
+		return
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		s.err = err
+		return
+	}
+	line = append(line, '\n')
+	// Flush first rather than let bufio write out part of the line
+	if len(line) > s.w.Available() && s.w.Buffered() > 0 {
+		if s.err = s.w.Flush(); s.err != nil {
+			return
+		}
+	}
+	if _, s.err = s.w.Write(line); s.err != nil {
+		return
+	}
+	s.records++
+	if s.records%jsonlFlushEvery == 0 {
+		s.err = s.w.Flush()
+	}
+}
+
+// image streams the record for a found image
+func (s *jsonlWriter) image(file ImageFile) {
+	record := jsonlImage{Type: "image", Path: file.Path, Size: file.Size, Ext: file.Ext, Dir: filepath.Dir(file.Path)}
+	if s.gps {
+		if lat, lon, ok := readGPS(file.Path); ok {
+			record.Lat, record.Lon = &lat, &lon
+		}
+	}
+	if s.live {
+		live := isLivePhoto(file.Path)
+		record.Live = &live
+	}
+	s.write(record)
+}
+
+// summary ends the stream with the scan result and flushes it, returning the first error
+// the stream ran into
+func (s *jsonlWriter) summary(result ScanResult, interrupted bool) error {
+	s.write(jsonlSummary{Type: "summary", Interrupted: interrupted, ScanResult: result})
+	if s.err == nil {
+		s.err = s.w.Flush()
+	}
+	return s.err
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"container/heap"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// fileHeap is a min-heap of images by size, so the smallest of the kept images is on top
+type fileHeap []ImageFile
+
+func (h fileHeap) Len() int           { return len(h) }
+func (h fileHeap) Less(i, j int) bool { return h[i].Size < h[j].Size }
+func (h fileHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *fileHeap) Push(x any)        { *h = append(*h, x.(ImageFile)) }
+func (h *fileHeap) Pop() any {
+	old := *h
+	file := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return file
+}
+
+// largestFiles keeps the n largest images it is given, in O(n) memory
+type largestFiles struct {
+	n     int
+	files fileHeap
+}
+
+// add offers file, which is kept if it is among the n largest seen so far
+func (l *largestFiles) add(file ImageFile) {
+	if l.n <= 0 {
+		return
+	}
+	if len(l.files) < l.n {
+		heap.Push(&l.files, file)
+	} else if file.Size > l.files[0].Size {
+		l.files[0] = file
+		heap.Fix(&l.files, 0)
+	}
+}
+
+// sorted returns the kept images, largest first and by path for equal sizes
+func (l *largestFiles) sorted() []ImageFile {
+	files := append([]ImageFile(nil), l.files...)
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].Size != files[j].Size {
+			return files[i].Size > files[j].Size
+		}
+		return files[i].Path < files[j].Path
+	})
+	return files
+}
+
+// printLargest prints the largest images with their sizes
+func printLargest(w io.Writer, files []ImageFile) {
+	fmt.Fprintln(w, "\nLargest images:")
+	for _, file := range files {
+		fmt.Fprintf(w, "File: %s | Size: %s\n", pathText(file.Path), sizeText(file.Size))
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLargestFiles(t *testing.T) {
+	// Sizes 1 to 20 in random order, so the kept set must change as larger ones turn up
+	var files []ImageFile
+	for _, size := range rand.New(rand.NewSource(1)).Perm(20) {
+		files = append(files, ImageFile{Path: fmt.Sprintf("img%02d.jpg", size+1), Size: int64(size + 1)})
+	}
+	largest := &largestFiles{n: 5}
+	for _, file := range files {
+		largest.add(file)
+	}
+
+	var got []int64
+	for _, file := range largest.sorted() {
+		got = append(got, file.Size)
+	}
+	if want := []int64{20, 19, 18, 17, 16}; !slices.Equal(got, want) {
+		t.Errorf("got sizes %v, want %v", got, want)
+	}
+
+	few := &largestFiles{n: 5}
+	few.add(ImageFile{Path: "b.jpg", Size: 7})
+	few.add(ImageFile{Path: "a.jpg", Size: 7})
+	if got := few.sorted(); len(got) != 2 || got[0].Path != "a.jpg" {
+		t.Errorf("got %v, want both files, equal sizes by path", got)
+	}
+
+	none := &largestFiles{}
+	none.add(ImageFile{Path: "a.jpg", Size: 1})
+	if got := none.sorted(); len(got) != 0 {
+		t.Errorf("n = 0 kept %v", got)
+	}
+}
+
+func TestLargestFlag(t *testing.T) {
+	root := t.TempDir()
+	for i, size := range []int{300, 5, 4000, 60, 1, 2000, 70, 800, 9} {
+		writeFile(t, filepath.Join(root, fmt.Sprintf("dir%d", i%3), fmt.Sprintf("%d.jpg", i)), strings.Repeat("x", size))
+	}
+
+	result := runJSON(t, "-largest", "5", root)
+	var got []int64
+	for _, file := range result.Largest {
+		got = append(got, file.Size)
+	}
+	if want := []int64{4000, 2000, 800, 300, 70}; !slices.Equal(got, want) {
+		t.Errorf("-largest 5: got sizes %v, want %v", got, want)
+	}
+}
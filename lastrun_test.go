@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLastRunState(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state")
+	if got, err := readLastRun(path); err != nil || !got.IsZero() {
+		t.Errorf("missing file: got %v, %v, want the zero time", got, err)
+	}
+
+	at := time.Date(2024, 5, 6, 7, 8, 9, 123456789, time.UTC)
+	for _, when := range []time.Time{at.Add(-time.Hour), at} { // replacing the earlier time
+		if err := writeLastRun(path, when); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, err := readLastRun(path); err != nil || !got.Equal(at) {
+		t.Errorf("got %v, %v, want %v", got, err, at)
+	}
+	if got := listFiles(t, dir); !slices.Equal(got, []string{"state"}) {
+		t.Errorf("got %q, want only the state file", got)
+	}
+
+	writeFile(t, path, "yesterday")
+	if _, err := readLastRun(path); err == nil {
+		t.Error("corrupt file: no error")
+	}
+}
+
+func TestSinceLastRun(t *testing.T) {
+	root := t.TempDir()
+	state := filepath.Join(t.TempDir(), "last-run")
+	old := time.Now().Add(-time.Hour)
+	for _, name := range []string{"a.jpg", "sub/b.png"} {
+		writeFile(t, filepath.Join(root, name), "image")
+		if err := os.Chtimes(filepath.Join(root, name), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The first run takes in everything; a dry run leaves the state alone
+	if result := runJSON(t, "-since-last-run", state, "-dry-run", root); result.TotalImages != 2 {
+		t.Errorf("dry run: got %d images, want 2", result.TotalImages)
+	}
+	if _, err := os.Stat(state); !os.IsNotExist(err) {
+		t.Errorf("the dry run wrote the state file: %v", err)
+	}
+	if result := runJSON(t, "-since-last-run", state, root); result.TotalImages != 2 {
+		t.Errorf("first run: got %d images, want 2", result.TotalImages)
+	}
+
+	// A second run picks up only what was added after the first started, a third nothing
+	firstRun, err := readLastRun(state)
+	if err != nil || firstRun.IsZero() {
+		t.Fatalf("state after the first run: %v, %v", firstRun, err)
+	}
+	added := filepath.Join(root, "sub", "new.jpg")
+	modified := firstRun.Add(time.Millisecond)
+	writeFile(t, added, "image")
+	if err := os.Chtimes(added, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Until(modified.Add(time.Millisecond))) // so the second run starts after it
+	result := runJSON(t, "-since-last-run", state, root)
+	if result.TotalImages != 1 || len(result.Directories) != 1 || result.Directories[0].Path != filepath.Dir(added) {
+		t.Errorf("second run: got %d images in %+v, want only new.jpg", result.TotalImages, result.Directories)
+	}
+	if result := runJSON(t, "-since-last-run", state, root); result.TotalImages != 0 {
+		t.Errorf("third run: got %d images, want none", result.TotalImages)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// How the EXIF MakerNote of iPhone images starts: the maker, a version and the byte order
+// of the IFD that follows. Offsets inside the note count from its first byte.
+const appleMakerNotePrefix = "Apple iOS\x00\x00\x01MM"
+
+// Apple MakerNote tags that tie stills together
+const (
+	appleBurstUUID         = 0x000b // shared by all shots of a burst
+	appleContentIdentifier = 0x0011 // shared by the still and the video of a Live Photo
+)
+
+// Kinds of LiveGroup
+const (
+	liveKindPhoto = "live_photo"
+	liveKindBurst = "burst"
+)
+
+// LiveGroup is a set of stills the iPhone took together: a Live Photo, whose video isn't
+// scanned, with any edited copies of its still, or the shots of a burst
+type LiveGroup struct {
+	ID    string   `json:"id"`   // the burst UUID or Live Photo content identifier
+	Kind  string   `json:"kind"` // "live_photo" or "burst"
+	Files []string `json:"files"`
+}
+
+// parseAppleMakerNote returns the string value of each tag in an Apple MakerNote. Tags of
+// other types are left out, so the result is empty for notes of other makers.
+func parseAppleMakerNote(note []byte) map[uint16]string {
+	values := make(map[uint16]string)
+	if !bytes.HasPrefix(note, []byte(appleMakerNotePrefix)) || len(note) < len(appleMakerNotePrefix)+2 {
+		return values
+	}
+	ifd := note[len(appleMakerNotePrefix):]
+	count := int(binary.BigEndian.Uint16(ifd))
+	for i := 0; i < count && 2+12*(i+1) <= len(ifd); i++ {
+		entry := ifd[2+12*i : 2+12*(i+1)]
+		tag, kind, size := binary.BigEndian.Uint16(entry), binary.BigEndian.Uint16(entry[2:]), binary.BigEndian.Uint32(entry[4:])
+		if kind != 2 { // ASCII
+			continue
+		}
+		value := entry[8:12]
+		if size > 4 {
+			offset := binary.BigEndian.Uint32(entry[8:])
+			if uint64(offset)+uint64(size) > uint64(len(note)) {
+				continue
+			}
+			value = note[offset : offset+size]
+		} else {
+			value = value[:size]
+		}
+		values[tag] = strings.TrimRight(string(value), "\x00")
+	}
+	return values
+}
+
+// readLiveIDs returns the Live Photo content identifier and the burst UUID of the image at
+// path, either of them empty if it has none, as not every iPhone still has them and other
+// cameras never do
+func readLiveIDs(path string) (contentID, burstID string) {
+	if !exifExtensions[strings.ToLower(filepath.Ext(path))] {
+		return "", ""
+	}
+	x, _ := readExif(path)
+	if x == nil {
+		return "", ""
+	}
+	tag, err := x.Get(exif.MakerNote)
+	if err != nil {
+		return "", ""
+	}
+	values := parseAppleMakerNote(tag.Val)
+	return values[appleContentIdentifier], values[appleBurstUUID]
+}
+
+// isLivePhoto reports whether the image at path is the still of a Live Photo
+func isLivePhoto(path string) bool {
+	contentID, _ := readLiveIDs(path)
+	return contentID != ""
+}
+
+// findLiveGroups reads the Apple metadata of every image in a format that carries EXIF,
+// using the given number of worker goroutines, and groups the stills by burst, or failing
+// that by Live Photo. Groups come in the order of their first file, and their files in the
+// order of files.
+func findLiveGroups(files []ImageFile, workers int) []LiveGroup {
+	if workers < 1 {
+		workers = 1
+	}
+
+	// Each worker writes only the entries for the indexes it receives
+	contentIDs := make([]string, len(files))
+	burstIDs := make([]string, len(files))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				contentIDs[i], burstIDs[i] = readLiveIDs(files[i].Path)
+			}
+		}()
+	}
+	for i, file := range files {
+		if exifExtensions[file.Ext] {
+			indexes <- i
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	var groups []LiveGroup
+	index := make(map[string]int) // kind and ID -> position in groups
+	for i, file := range files {
+		group := LiveGroup{ID: burstIDs[i], Kind: liveKindBurst}
+		if group.ID == "" {
+			group = LiveGroup{ID: contentIDs[i], Kind: liveKindPhoto}
+		}
+		if group.ID == "" {
+			continue
+		}
+		key := group.Kind + ":" + group.ID
+		j, ok := index[key]
+		if !ok {
+			j = len(groups)
+			index[key] = j
+			groups = append(groups, group)
+		}
+		groups[j].Files = append(groups[j].Files, file.Path)
+	}
+	return groups
+}
+
+// printLiveGroups prints the Live Photos and bursts found, listing the files of those with
+// more than one still
+func printLiveGroups(w io.Writer, groups []LiveGroup) {
+	photos, bursts := 0, 0
+	fmt.Fprintln(w, "\nLive Photos and bursts:")
+	for _, group := range groups {
+		if group.Kind == liveKindBurst {
+			bursts++
+		} else {
+			photos++
+		}
+		if len(group.Files) < 2 {
+			continue
+		}
+		paths := make([]string, len(group.Files))
+		for i, path := range group.Files {
+			paths[i] = pathText(path)
+		}
+		fmt.Fprintf(w, "Group: %s | Kind: %s | Files: %s\n", group.ID, group.Kind, strings.Join(paths, ", "))
+	}
+	fmt.Fprintf(w, "%d Live Photos, %d bursts\n", photos, bursts)
+}
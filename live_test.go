@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// appleMakerNote returns an Apple MakerNote holding the string values of tags
+func appleMakerNote(values map[uint16]string) []byte {
+	tags := slices.Sorted(maps.Keys(values))
+	ifdStart := len(appleMakerNotePrefix)
+	dataOffset := ifdStart + 2 + 12*len(tags) + 4
+	note := binary.BigEndian.AppendUint16([]byte(appleMakerNotePrefix), uint16(len(tags)))
+	var data []byte
+	for _, tag := range tags {
+		value := append([]byte(values[tag]), 0)
+		note = binary.BigEndian.AppendUint16(note, tag)
+		note = binary.BigEndian.AppendUint16(note, exifASCII)
+		note = binary.BigEndian.AppendUint32(note, uint32(len(value)))
+		if len(value) <= 4 {
+			note = append(note, value...)
+			note = append(note, make([]byte, 4-len(value))...)
+			continue
+		}
+		note = binary.BigEndian.AppendUint32(note, uint32(dataOffset+len(data)))
+		data = append(data, value...)
+	}
+	note = binary.BigEndian.AppendUint32(note, 0)
+	return append(note, data...)
+}
+
+// appleExif returns the IFD0 entries of an iPhone image with the MakerNote values of tags
+// in its Exif IFD
+func appleExif(values map[uint16]string) []exifEntry {
+	return []exifEntry{
+		asciiEntry(0x10f, "Apple"), // Make
+		subIFDEntry(0x8769, []exifEntry{undefinedEntry(0x927c, appleMakerNote(values))}),
+	}
+}
+
+// fakeHEIC returns the start of a HEIC file with the EXIF block of ifd0 somewhere inside,
+// which is all readExif looks for
+func fakeHEIC(ifd0 []exifEntry) []byte {
+	return append([]byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic....Exif\x00\x00"), exifTIFF(ifd0, nil)...)
+}
+
+func TestParseAppleMakerNote(t *testing.T) {
+	note := appleMakerNote(map[uint16]string{appleBurstUUID: "6A1C2F0E-BURST", appleContentIdentifier: "ABC", 0x0001: "x"})
+	want := map[uint16]string{appleBurstUUID: "6A1C2F0E-BURST", appleContentIdentifier: "ABC", 0x0001: "x"}
+	if got := parseAppleMakerNote(note); !maps.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// Values pointing past the end are left out, as are notes of other makers
+	if got := parseAppleMakerNote(note[:len(note)-4]); got[appleBurstUUID] != "" || got[appleContentIdentifier] != "ABC" {
+		t.Errorf("truncated: got %q, want only the short value", got)
+	}
+	for _, other := range [][]byte{[]byte("Nikon\x00\x02\x10\x00\x00MM\x00*"), nil, []byte(appleMakerNotePrefix)} {
+		if got := parseAppleMakerNote(other); len(got) != 0 {
+			t.Errorf("%q: got %q, want nothing", other, got)
+		}
+	}
+}
+
+// liveTree writes the still of a Live Photo with an edited copy, two shots of a burst, an
+// iPhone still without the identifiers and images of other kinds
+func liveTree(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"IMG_0001.HEIC":  fakeHEIC(appleExif(map[uint16]string{appleContentIdentifier: "LIVE-1"})),
+		"IMG_E0001.jpg":  exifJPEG(t, 8, 8, appleExif(map[uint16]string{appleContentIdentifier: "LIVE-1"}), nil),
+		"IMG_0002.jpg":   exifJPEG(t, 8, 8, appleExif(map[uint16]string{appleBurstUUID: "BURST-A", appleContentIdentifier: "LIVE-2"}), nil),
+		"IMG_0003.jpg":   exifJPEG(t, 8, 8, appleExif(map[uint16]string{appleBurstUUID: "BURST-A", appleContentIdentifier: "LIVE-3"}), nil),
+		"IMG_0004.HEIC":  fakeHEIC(appleExif(map[uint16]string{0x0001: "x"})),
+		"camera.jpg":     jpegBytes(t, 8, 8),
+		"broken.heic":    []byte("not a HEIC file"),
+		"screenshot.png": []byte("no EXIF here"),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestFindLiveGroups(t *testing.T) {
+	dir := liveTree(t)
+	files := scanFiles(t, dir)
+	for _, file := range files {
+		want := map[string]bool{"IMG_0001.HEIC": true, "IMG_E0001.jpg": true, "IMG_0002.jpg": true, "IMG_0003.jpg": true}[filepath.Base(file.Path)]
+		if got := isLivePhoto(file.Path); got != want {
+			t.Errorf("isLivePhoto(%s) = %v, want %v", filepath.Base(file.Path), got, want)
+		}
+	}
+
+	groups := findLiveGroups(files, 4)
+	var got []string
+	for _, group := range groups {
+		var names []string
+		for _, path := range group.Files {
+			names = append(names, filepath.Base(path))
+		}
+		got = append(got, group.Kind+" "+group.ID+": "+strings.Join(names, ", "))
+	}
+	// The burst's shots group by burst, even though each is a Live Photo of its own
+	want := []string{"live_photo LIVE-1: IMG_0001.HEIC, IMG_E0001.jpg", "burst BURST-A: IMG_0002.jpg, IMG_0003.jpg"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestGroupLiveFlag(t *testing.T) {
+	dir := liveTree(t)
+	if result := runJSON(t, "-group-live", dir); len(result.LivePhotos) != 2 {
+		t.Errorf("got %d groups, want 2", len(result.LivePhotos))
+	}
+
+	stdout, stderr, code := runCLI(t, "-group-live", "-format", "jsonl", dir)
+	if code != exitOK {
+		t.Fatalf("jsonl: exit code %d, stderr:\n%s", code, stderr)
+	}
+	live := 0
+	for _, line := range strings.Split(strings.TrimSpace(stdout), "\n") {
+		var record jsonlImage
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("decoding %q: %v", line, err)
+		}
+		if record.Type != "image" {
+			continue
+		}
+		if record.Live == nil {
+			t.Errorf("%s: no live_photo field", record.Path)
+		} else if *record.Live {
+			live++
+		}
+	}
+	if live != 4 {
+		t.Errorf("got %d Live Photo stills, want 4", live)
+	}
+
+	// The edited copy was saved a month later, but stays with its Live Photo
+	edited := filepath.Join(dir, "IMG_E0001.jpg")
+	for path, at := range map[string]time.Time{
+		filepath.Join(dir, "IMG_0001.HEIC"): time.Date(2022, 3, 10, 12, 0, 0, 0, time.Local),
+		edited:                              time.Date(2022, 4, 10, 12, 0, 0, 0, time.Local),
+	} {
+		if err := os.Chtimes(path, at, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dest := filepath.Join(t.TempDir(), "sorted")
+	if _, stderr, code := runCLI(t, "-q", "-yes", "-group-live", "-sort-by-date", dest, dir); code != exitOK {
+		t.Fatalf("sort: exit code %d, stderr:\n%s", code, stderr)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "2022", "03", "IMG_E0001.jpg")); err != nil {
+		t.Errorf("the edited copy isn't next to its Live Photo: %v\n%q", err, listFiles(t, dest))
+	}
+}
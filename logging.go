@@ -0,0 +1,35 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"os"
+)
+
+// logger reports diagnostics, like files that couldn't be read and paths that were skipped, to
+// stderr so they never mix with the report. It is replaced once from the -log-level flag
+// before the scan starts.
+var logger = newLogger(slog.LevelInfo, false)
+
+// newLogger returns a logger writing messages at level or above to stderr, colored by level
+// with color set. The timestamp is left out, as the messages of a single run are read as
+// they appear.
+func newLogger(level slog.Level, color bool) *slog.Logger {
+	var w io.Writer = os.Stderr
+	if color {
+		w = &colorWriter{w: os.Stderr}
+	}
+	handler := slog.NewTextHandler(w, &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	if color {
+		return slog.New(colorHandler{handler, w.(*colorWriter)})
+	}
+	return slog.New(handler)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ManifestCheck is the outcome of checking the found images against a manifest
+type ManifestCheck struct {
+	OK         int      `json:"ok"`
+	Mismatched []string `json:"mismatched,omitempty"` // content changed since the manifest was written
+	Missing    []string `json:"missing,omitempty"`    // listed in the manifest but gone
+	New        []string `json:"new,omitempty"`        // found by the scan but not listed
+}
+
+// manifestName returns how path is listed in a manifest: relative to base with forward
+// slashes if it lies below base, and as is otherwise or when base is ""
+func manifestName(base, path string) string {
+	if base == "" {
+		return path
+	}
+	rel, err := filepath.Rel(base, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// manifestPath resolves a name read from a manifest written with manifestName
+func manifestPath(base, name string) string {
+	name = filepath.FromSlash(name)
+	if base == "" || filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(base, name)
+}
+
+// writeManifest writes the SHA-256 of every image to the file at path in the format of
+// sha256sum, so it can also be checked with sha256sum -c from within base. Names with a
+// backslash or newline are escaped the way sha256sum does it. Images that can't be read are
+// reported to stderr and left out, so written can be less than len(files).
+func writeManifest(path, base string, files []ImageFile) (written int, err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	w := bufio.NewWriter(f)
+	for _, file := range files {
+		hash, err := hashFile(file.Path)
+		if err != nil {
+			logger.Error("hashing failed", "path", file.Path, "err", err)
+			continue
+		}
+		name := manifestName(base, file.Path)
+		if strings.ContainsAny(name, "\\\n") {
+			name = strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(name)
+			hash = `\` + hash
+		}
+		fmt.Fprintf(w, "%s  %s\n", hash, name)
+		written++
+	}
+	return written, w.Flush()
+}
+
+// readManifest parses a sha256sum-style manifest into the hash of each listed name, keeping
+// the order of the lines in names
+func readManifest(r io.Reader) (hashes map[string]string, names []string, err error) {
+	hashes = make(map[string]string)
+	lines := bufio.NewScanner(r)
+	for n := 1; lines.Scan(); n++ {
+		line := lines.Text()
+		if line == "" {
+			continue
+		}
+		escaped := strings.HasPrefix(line, `\`)
+		line = strings.TrimPrefix(line, `\`)
+
+		// "hash  name" in text mode, "hash *name" in binary mode
+		hash, name, ok := strings.Cut(line, " ")
+		if !ok || len(hash) != 64 || name == "" || (name[0] != ' ' && name[0] != '*') {
+			return nil, nil, fmt.Errorf("line %d: not a sha256sum line", n)
+		}
+		name = name[1:]
+		if escaped {
+			name = strings.NewReplacer(`\\`, `\`, `\n`, "\n").Replace(name)
+		}
+		if _, dup := hashes[name]; !dup {
+			names = append(names, name)
+		}
+		hashes[name] = strings.ToLower(hash)
+	}
+	return hashes, names, lines.Err()
+}
+
+// verifyManifest re-hashes the files listed in the manifest at path, with names relative
+// to base, and compares the found images against it. Files that can't be read other than
+// for being gone are reported to stderr and left out.
+func verifyManifest(path, base string, files []ImageFile) (ManifestCheck, error) {
+	var check ManifestCheck
+	f, err := os.Open(path)
+	if err != nil {
+		return check, err
+	}
+	hashes, names, err := readManifest(f)
+	f.Close()
+	if err != nil {
+		return check, fmt.Errorf("%s: %w", path, err)
+	}
+
+	listed := make(map[string]bool, len(names))
+	for _, name := range names {
+		file := manifestPath(base, name)
+		listed[file] = true
+		hash, err := hashFile(file)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			check.Missing = append(check.Missing, file)
+		case err != nil:
+			logger.Error("hashing failed", "path", file, "err", err)
+		case hash != hashes[name]:
+			check.Mismatched = append(check.Mismatched, file)
+		default:
+			check.OK++
+		}
+	}
+
+	for _, file := range files {
+		if !listed[file.Path] {
+			check.New = append(check.New, file.Path)
+		}
+	}
+	return check, nil
+}
+
+// printManifestCheck prints the files that don't match the manifest and a count of each kind
+func printManifestCheck(w io.Writer, check ManifestCheck) {
+	fmt.Fprintln(w, "\nManifest check:")
+	for _, path := range check.Mismatched {
+		fmt.Fprintf(w, "FAILED: %s\n", pathText(path))
+	}
+	for _, path := range check.Missing {
+		fmt.Fprintf(w, "MISSING: %s\n", pathText(path))
+	}
+	for _, path := range check.New {
+		fmt.Fprintf(w, "NEW: %s\n", pathText(path))
+	}
+	fmt.Fprintf(w, "%d OK, %d mismatched, %d missing, %d new\n", check.OK, len(check.Mismatched), len(check.Missing), len(check.New))
+}
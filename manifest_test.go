@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestManifestRoundTrip(t *testing.T) {
+	root := t.TempDir()
+	manifest := filepath.Join(t.TempDir(), "manifest.sha256")
+	for _, name := range []string{"a.jpg", "sub/b.png", "sub/c.gif"} {
+		writeFile(t, filepath.Join(root, name), name)
+	}
+
+	files := scanFiles(t, root)
+	if written, err := writeManifest(manifest, root, files); err != nil || written != 3 {
+		t.Fatalf("writeManifest: wrote %d, %v; want 3", written, err)
+	}
+	check, err := verifyManifest(manifest, root, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if check.OK != 3 || len(check.Mismatched)+len(check.Missing)+len(check.New) != 0 {
+		t.Errorf("unchanged tree: got %+v, want 3 OK", check)
+	}
+
+	changed, gone, added := filepath.Join(root, "a.jpg"), filepath.Join(root, "sub", "b.png"), filepath.Join(root, "sub", "d.jpg")
+	writeFile(t, changed, "rewritten")
+	if err := os.Remove(gone); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, added, "new")
+	check, err = verifyManifest(manifest, root, scanFiles(t, root))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if check.OK != 1 || !slices.Equal(check.Mismatched, []string{changed}) || !slices.Equal(check.Missing, []string{gone}) || !slices.Equal(check.New, []string{added}) {
+		t.Errorf("changed tree: got %+v, want 1 OK, %s mismatched, %s missing, %s new", check, changed, gone, added)
+	}
+}
+
+func TestManifestUnreadableFile(t *testing.T) {
+	root := t.TempDir()
+	manifest := filepath.Join(t.TempDir(), "manifest.sha256")
+	writeFile(t, filepath.Join(root, "a.jpg"), "a")
+	writeFile(t, filepath.Join(root, "b.jpg"), "b")
+	files := scanFiles(t, root)
+
+	// Gone between the scan and the hashing, the way an unreadable file fails
+	if err := os.Remove(filepath.Join(root, "a.jpg")); err != nil {
+		t.Fatal(err)
+	}
+	written, err := writeManifest(manifest, root, files)
+	if err != nil || written != 1 {
+		t.Fatalf("writeManifest: wrote %d, %v; want 1 and no error", written, err)
+	}
+	check, err := verifyManifest(manifest, root, files[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if check.OK != 1 {
+		t.Errorf("got %+v, want the readable image OK", check)
+	}
+}
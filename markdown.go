@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// markdownCell escapes text for a GitHub-flavored Markdown table cell, where a pipe would
+// end the cell
+func markdownCell(text string) string {
+	return strings.ReplaceAll(text, "|", `\|`)
+}
+
+// printMarkdownTable prints a table with a left-aligned first column and right-aligned
+// numeric columns after it
+func printMarkdownTable(w io.Writer, title string, header []string, rows [][]string) {
+	if len(rows) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n### %s\n\n", title)
+	fmt.Fprintf(w, "| %s |\n", strings.Join(header, " | "))
+	align := make([]string, len(header))
+	align[0] = ":---"
+	for i := 1; i < len(align); i++ {
+		align[i] = "---:"
+	}
+	fmt.Fprintf(w, "| %s |\n", strings.Join(align, " | "))
+	for _, row := range rows {
+		for i, cell := range row {
+			row[i] = markdownCell(cell)
+		}
+		fmt.Fprintf(w, "| %s |\n", strings.Join(row, " | "))
+	}
+}
+
+// printMarkdown prints the result of -format markdown: the totals, then tables of the roots,
+// categories, extensions, accepted directories, the top directories by size (at most top of
+// them when top > 0) and the months or locations of -by-date or -by-geo
+func printMarkdown(w io.Writer, result ScanResult, top int) {
+	fmt.Fprintln(w, "## Image scan")
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "- **Total images:** %s\n", formatInt(int64(result.TotalImages)))
+	fmt.Fprintf(w, "- **Total size:** %s\n", sizeText(result.TotalSize))
+	if result.Sampled {
+		fmt.Fprintln(w, "- **Sample only:** the scan stopped at the -sample limit")
+	}
+	if len(result.ZeroByte) > 0 {
+		fmt.Fprintf(w, "- **Zero-byte images left out:** %s\n", formatInt(int64(len(result.ZeroByte))))
+	}
+
+	var rows [][]string
+	for _, rt := range result.Roots {
+		rows = append(rows, []string{pathText(rt.Root), formatInt(int64(rt.Images)), sizeText(rt.TotalSize)})
+	}
+	printMarkdownTable(w, "Roots", []string{"Root", "Image Files", "Size"}, rows)
+
+	rows = nil
+	for _, ct := range result.Categories {
+		rows = append(rows, []string{ct.Category, formatInt(int64(ct.Count)), sizeText(ct.Size)})
+	}
+	printMarkdownTable(w, "Image files by category", []string{"Category", "Image Files", "Size"}, rows)
+
+	rows = nil
+	for _, et := range result.Extensions {
+		rows = append(rows, []string{strings.TrimPrefix(et.Ext, "."), formatInt(int64(et.Count)), sizeText(et.Size)})
+	}
+	printMarkdownTable(w, "Image files by extension", []string{"Extension", "Image Files", "Size"}, rows)
+
+	accepted := make(map[string]bool)
+	for _, dir := range result.AcceptedDirs {
+		accepted[dir] = true
+	}
+	rows = nil
+	for _, dc := range result.Directories {
+		if accepted[dc.Path] {
+			rows = append(rows, []string{pathText(dc.Path), formatInt(int64(dc.Count))})
+		}
+	}
+	printMarkdownTable(w, "Directories sorted by number of image files", []string{"Directory", "Image Files"}, rows)
+
+	rows = nil
+	for i, ds := range result.DirSizes {
+		if top > 0 && i >= top {
+			break
+		}
+		rows = append(rows, []string{pathText(ds.Path), sizeText(ds.Size)})
+	}
+	printMarkdownTable(w, "Directories sorted by size of image files", []string{"Directory", "Size"}, rows)
+
+	rows = nil
+	for _, mc := range result.Months {
+		rows = append(rows, []string{mc.Month, formatInt(int64(mc.Count))})
+	}
+	printMarkdownTable(w, "Image files by capture month", []string{"Month", "Image Files"}, rows)
+
+	rows = nil
+	for _, lc := range result.Locations {
+		rows = append(rows, []string{fmt.Sprintf("%.1f, %.1f", lc.Lat, lc.Lon), formatInt(int64(lc.Count))})
+	}
+	if result.NoGPS > 0 {
+		rows = append(rows, []string{"no GPS data", formatInt(int64(result.NoGPS))})
+	}
+	printMarkdownTable(w, "Image files by location", []string{"Location", "Image Files"}, rows)
+}
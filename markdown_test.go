@@ -0,0 +1,60 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPrintMarkdownTable(t *testing.T) {
+	var b strings.Builder
+	printMarkdownTable(&b, "Things", []string{"Name", "Count", "Size"}, [][]string{{"a|b", "1", "2 KB"}})
+	want := "\n### Things\n\n| Name | Count | Size |\n| :--- | ---: | ---: |\n| a\\|b | 1 | 2 KB |\n"
+	if b.String() != want {
+		t.Errorf("got %q, want %q", b.String(), want)
+	}
+
+	b.Reset()
+	printMarkdownTable(&b, "Nothing", []string{"Name", "Count"}, nil)
+	if b.Len() != 0 {
+		t.Errorf("no rows: got %q, want nothing", b.String())
+	}
+}
+
+func TestPrintMarkdownEscapesPaths(t *testing.T) {
+	var b strings.Builder
+	printMarkdown(&b, ScanResult{
+		TotalImages:  1,
+		Directories:  []DirCount{{Path: "/photos/a|b", Count: 1}},
+		AcceptedDirs: []string{"/photos/a|b"},
+	}, 0)
+	if !strings.Contains(b.String(), "| "+markdownCell(pathText("/photos/a|b"))+" | 1 |\n") || strings.Contains(b.String(), "a|b") {
+		t.Errorf("the pipe isn't escaped:\n%s", b.String())
+	}
+}
+
+func TestMarkdownFormat(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "a.jpg"), strings.Repeat("x", 2048))
+	writeFile(t, filepath.Join(root, "sub", "b.png"), "image")
+
+	stdout, stderr, code := runCLI(t, "-format", "markdown", "-min-count", "0", root)
+	if code != exitOK {
+		t.Fatalf("exit code %d, stderr:\n%s", code, stderr)
+	}
+	for _, want := range []string{
+		"## Image scan\n\n- **Total images:** 2\n- **Total size:** " + sizeText(2053) + "\n",
+		"### Image files by category\n\n| Category | Image Files | Size |\n| :--- | ---: | ---: |\n",
+		"### Directories sorted by number of image files\n\n| Directory | Image Files |\n| :--- | ---: |\n",
+		"| " + markdownCell(pathText(filepath.Join(root, "sub"))) + " | 1 |\n",
+		"| jpg | 1 | " + sizeText(2048) + " |\n",
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("output lacks %q:\n%s", want, stdout)
+		}
+	}
+	// The tables come after the totals
+	if strings.Index(stdout, "|") < strings.Index(stdout, "**Total size:**") {
+		t.Errorf("a table comes before the totals:\n%s", stdout)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	_ "image/gif" // register decoders for image.DecodeConfig
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// Formats we look for EXIF metadata in
+var exifExtensions = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".heic": true,
+	".heif": true,
+}
+
+// How much of a HEIC/HEIF file we search for the embedded EXIF block
+const heifExifSearchLimit = 16 * 1024 * 1024
+
+// readExif opens a JPEG or HEIC/HEIF file and decodes its EXIF metadata
+func readExif(path string) (*exif.Exif, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == ".heic" || ext == ".heif" {
+		return decodeHEIFExif(f)
+	}
+	return exif.Decode(f)
+}
+
+// decodeHEIFExif finds the raw "Exif\0\0" block stored inside a HEIF container and decodes it
+func decodeHEIFExif(r io.Reader) (*exif.Exif, error) {
+	data, err := io.ReadAll(io.LimitReader(r, heifExifSearchLimit))
+	if err != nil {
+		return nil, err
+	}
+	i := bytes.Index(data, []byte("Exif\x00\x00"))
+	if i < 0 {
+		return nil, errors.New("no EXIF block found")
+	}
+	return exif.Decode(bytes.NewReader(data[i:]))
+}
+
+// readCaptureDate returns when the image at path was taken, as read by its format handler
+// (the EXIF DateTimeOriginal tag for the built-in formats), falling back to the file's
+// modification time. It returns false if neither is available.
+func readCaptureDate(path string) (time.Time, bool) {
+	if t, err := formatOf(path).CaptureTime(path); err == nil {
+		return t, true
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return info.ModTime(), true
+}
+
+// captureMonth groups an image by the YYYY-MM of its capture date, or "unknown" if it has none
+func captureMonth(path string) string {
+	t, ok := readCaptureDate(path)
+	if !ok {
+		return "unknown"
+	}
+	return t.Format("2006-01")
+}
+
+// imageDimensions reads the pixel width and height of the image at path from its header,
+// without decoding the pixels, using its format handler. The built-in formats with a
+// registered decoder (JPEG, PNG, GIF, and WebP when built with -tags webp) are supported, as
+// are HEIC/HEIF; others return an error.
+func imageDimensions(path string) (width, height int, err error) {
+	return formatOf(path).Dimensions(path)
+}
+
+// MonthCount holds the number of image files captured in a single month
+type MonthCount struct {
+	Month string `json:"month"`
+	Count int    `json:"count"`
+}
+
+// sortMonthCounts returns the monthly counts in chronological order, with "unknown" last
+func sortMonthCounts(monthCount map[string]int) []MonthCount {
+	sortedMonths := make([]MonthCount, 0, len(monthCount))
+	for month, count := range monthCount {
+		sortedMonths = append(sortedMonths, MonthCount{Month: month, Count: count})
+	}
+	sort.Slice(sortedMonths, func(i, j int) bool {
+		return sortedMonths[i].Month < sortedMonths[j].Month
+	})
+	return sortedMonths
+}
+
+// printYearCounts prints how many files went into each year, given counts per YYYY/MM bucket
+func printYearCounts(out io.Writer, buckets map[string]int) {
+	yearCount := make(map[string]int)
+	for bucket, count := range buckets {
+		year, _, _ := strings.Cut(filepath.ToSlash(bucket), "/")
+		yearCount[year] += count
+	}
+
+	years := make([]string, 0, len(yearCount))
+	for year := range yearCount {
+		years = append(years, year)
+	}
+	sort.Strings(years)
+	for _, year := range years {
+		fmt.Fprintf(out, "Year: %s | Image Files: %s\n", year, formatInt(int64(yearCount[year])))
+	}
+}
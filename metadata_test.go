@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// EXIF field types
+const (
+	exifASCII     = 2
+	exifShort     = 3
+	exifLong      = 4
+	exifRational  = 5
+	exifUndefined = 7
+)
+
+// exifEntry is one field of an IFD, its value already encoded little-endian, or the
+// pointer to the sub-IFD of sub
+type exifEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	value []byte
+	sub   []exifEntry
+}
+
+func asciiEntry(tag uint16, s string) exifEntry {
+	value := append([]byte(s), 0)
+	return exifEntry{tag: tag, typ: exifASCII, count: uint32(len(value)), value: value}
+}
+
+func shortEntry(tag, v uint16) exifEntry {
+	return exifEntry{tag: tag, typ: exifShort, count: 1, value: binary.LittleEndian.AppendUint16(nil, v)}
+}
+
+func undefinedEntry(tag uint16, value []byte) exifEntry {
+	return exifEntry{tag: tag, typ: exifUndefined, count: uint32(len(value)), value: value}
+}
+
+// subIFDEntry points to an IFD of entries, like the Exif IFD (0x8769) or the GPS IFD (0x8825)
+func subIFDEntry(tag uint16, entries []exifEntry) exifEntry {
+	return exifEntry{tag: tag, typ: exifLong, count: 1, value: make([]byte, 4), sub: entries}
+}
+
+// rationalEntry encodes the numerator, denominator pairs of terms
+func rationalEntry(tag uint16, terms ...uint32) exifEntry {
+	var value []byte
+	for _, term := range terms {
+		value = binary.LittleEndian.AppendUint32(value, term)
+	}
+	return exifEntry{tag: tag, typ: exifRational, count: uint32(len(terms) / 2), value: value}
+}
+
+// appendIFD appends an IFD of entries to tiff, followed by the values too long to fit in
+// their entries and then the sub-IFDs they point to
+func appendIFD(tiff []byte, entries []exifEntry) []byte {
+	start := len(tiff)
+	dataOffset := start + 2 + 12*len(entries) + 4
+	tiff = binary.LittleEndian.AppendUint16(tiff, uint16(len(entries)))
+	var data []byte
+	for _, e := range entries {
+		tiff = binary.LittleEndian.AppendUint16(tiff, e.tag)
+		tiff = binary.LittleEndian.AppendUint16(tiff, e.typ)
+		tiff = binary.LittleEndian.AppendUint32(tiff, e.count)
+		if len(e.value) <= 4 {
+			tiff = append(tiff, e.value...)
+			tiff = append(tiff, make([]byte, 4-len(e.value))...)
+			continue
+		}
+		tiff = binary.LittleEndian.AppendUint32(tiff, uint32(dataOffset+len(data)))
+		data = append(data, e.value...)
+		if len(data)%2 == 1 {
+			data = append(data, 0) // values start on a word boundary
+		}
+	}
+	tiff = binary.LittleEndian.AppendUint32(tiff, 0) // no next IFD
+	tiff = append(tiff, data...)
+	for i, e := range entries {
+		if e.sub != nil {
+			binary.LittleEndian.PutUint32(tiff[start+2+12*i+8:], uint32(len(tiff)))
+			tiff = appendIFD(tiff, e.sub)
+		}
+	}
+	return tiff
+}
+
+// exifTIFF returns the TIFF structure of an EXIF block with the IFD0 entries, sorted by tag,
+// and a GPS IFD of the gps entries if there are any
+func exifTIFF(ifd0, gps []exifEntry) []byte {
+	if len(gps) > 0 {
+		ifd0 = append(ifd0, subIFDEntry(0x8825, gps)) // GPSInfo, the highest tag
+	}
+	return appendIFD([]byte("II*\x00\x08\x00\x00\x00"), ifd0)
+}
+
+// exifJPEG returns a w×h JPEG with the EXIF block of exifTIFF in its APP1 segment
+func exifJPEG(t testing.TB, w, h int, ifd0, gps []exifEntry) []byte {
+	t.Helper()
+	app1 := append([]byte("Exif\x00\x00"), exifTIFF(ifd0, gps)...)
+	var buf bytes.Buffer
+	img := jpegBytes(t, w, h)
+	buf.Write(img[:2]) // SOI
+	buf.Write([]byte{0xff, 0xe1})
+	buf.Write(binary.BigEndian.AppendUint16(nil, uint16(len(app1)+2)))
+	buf.Write(app1)
+	buf.Write(img[2:])
+	return buf.Bytes()
+}
+
+// gpsEntries returns the GPS IFD of a position given in degrees, minutes and hundredths of
+// seconds, with the hemispheres as "N" or "S" and "E" or "W"
+func gpsEntries(latRef string, latDeg, latMin, latSec100 uint32, lonRef string, lonDeg, lonMin, lonSec100 uint32) []exifEntry {
+	return []exifEntry{
+		asciiEntry(0x1, latRef),
+		rationalEntry(0x2, latDeg, 1, latMin, 1, latSec100, 100),
+		asciiEntry(0x3, lonRef),
+		rationalEntry(0x4, lonDeg, 1, lonMin, 1, lonSec100, 100),
+	}
+}
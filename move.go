@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// moveFile moves src to dst, which must not exist yet. On the same filesystem it links the
+// file at dst and removes src, as a rename would silently replace a file that appeared at
+// dst since it was picked; where hard links aren't supported it renames it after checking
+// dst is free. Across filesystems it falls back to moveAcross.
+func moveFile(src, dst string) error {
+	err := os.Link(src, dst)
+	switch {
+	case err == nil:
+		if err := os.Remove(src); err != nil {
+			os.Remove(dst)
+			return err
+		}
+		return nil
+	case errors.Is(err, fs.ErrExist):
+		return err
+	case isCrossDevice(err):
+		return moveAcross(src, dst)
+	}
+	if exists(dst) {
+		return fmt.Errorf("%s: %w", dst, fs.ErrExist)
+	}
+	if err := os.Rename(src, dst); err == nil || !isCrossDevice(err) {
+		return err
+	}
+	return moveAcross(src, dst)
+}
+
+// moveAcross moves src to dst on another filesystem, where it can't be renamed. The copy is
+// written to a temporary file next to dst, synced to disk and only then renamed into place,
+// so a crash leaves either no file or a complete one at dst. The source is removed last.
+// The permissions and modification time of src are kept.
+func moveAcross(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	// Until the rename the temporary file is all there is to clean up
+	done := false
+	defer func() {
+		if !done {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+	if _, err := io.Copy(tmp, in); err != nil {
+		return err
+	}
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chtimes(tmp.Name(), info.ModTime(), info.ModTime()); err != nil {
+		return err
+	}
+	// Rename would replace a file that appeared at dst since it was picked
+	if exists(dst) {
+		return fmt.Errorf("%s: %w", dst, fs.ErrExist)
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return err
+	}
+	done = true
+
+	in.Close()
+	return os.Remove(src)
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestIsCrossDevice(t *testing.T) {
+	if !isCrossDevice(&os.LinkError{Op: "rename", Old: "/a/x.jpg", New: "/b/x.jpg", Err: syscall.EXDEV}) {
+		t.Error("EXDEV isn't taken for crossing filesystems")
+	}
+}
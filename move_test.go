@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMoveAcross(t *testing.T) {
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "src", "a.jpg"), filepath.Join(dir, "dst", "a.jpg")
+	writeFile(t, src, "image data")
+	writeFile(t, filepath.Join(dir, "dst", "other.jpg"), "other")
+	modTime := time.Date(2019, 6, 1, 8, 30, 0, 0, time.UTC)
+	if err := os.Chmod(src, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(src, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	// Called directly, as moveFile would after os.Rename failed across filesystems
+	if err := moveAcross(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Errorf("the source is still there: %v", err)
+	}
+	info, err := os.Stat(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "image data" {
+		t.Errorf("got %q at the destination, want the source's content", data)
+	}
+	if !info.ModTime().Equal(modTime) {
+		t.Errorf("got modification time %v, want %v", info.ModTime(), modTime)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0o600 {
+		t.Errorf("got permissions %v, want -rw-------", info.Mode().Perm())
+	}
+	if got, want := listFiles(t, filepath.Join(dir, "dst")), []string{"a.jpg", "other.jpg"}; !slices.Equal(got, want) {
+		t.Errorf("got %q in the destination folder, want %q and no temporary file", got, want)
+	}
+}
+
+func TestMoveAcrossFailures(t *testing.T) {
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "a.jpg"), filepath.Join(dir, "taken.jpg")
+	writeFile(t, src, "image data")
+	writeFile(t, dst, "already here")
+
+	if err := moveAcross(src, dst); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("existing destination: got %v, want fs.ErrExist", err)
+	}
+	if err := moveAcross(src, filepath.Join(dir, "missing", "a.jpg")); err == nil {
+		t.Error("missing destination folder: no error")
+	}
+	if err := moveAcross(filepath.Join(dir, "gone.jpg"), filepath.Join(dir, "b.jpg")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("missing source: got %v, want fs.ErrNotExist", err)
+	}
+	// Nothing is lost or left behind
+	if data, _ := os.ReadFile(dst); string(data) != "already here" {
+		t.Errorf("the existing destination now holds %q", data)
+	}
+	if got, want := listFiles(t, dir), []string{"a.jpg", "taken.jpg"}; !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestMoveFile(t *testing.T) {
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "a.jpg"), filepath.Join(dir, "sub", "b.jpg")
+	writeFile(t, src, "image data")
+	writeFile(t, filepath.Join(dir, "sub", "c.jpg"), "other")
+	if err := moveFile(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := listFiles(t, dir), []string{"sub/b.jpg", "sub/c.jpg"}; !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// An existing destination is left alone, and so is the source
+	src, taken := filepath.Join(dir, "d.jpg"), filepath.Join(dir, "sub", "c.jpg")
+	writeFile(t, src, "image data")
+	if err := moveFile(src, taken); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("existing destination: got %v, want fs.ErrExist", err)
+	}
+	if data, _ := os.ReadFile(taken); string(data) != "other" {
+		t.Errorf("the existing destination now holds %q", data)
+	}
+	if got, want := listFiles(t, dir), []string{"d.jpg", "sub/b.jpg", "sub/c.jpg"}; !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if isCrossDevice(&os.LinkError{Op: "rename", Old: src, New: dst, Err: fs.ErrPermission}) {
+		t.Error("a permission error counts as crossing filesystems")
+	}
+}
@@ -0,0 +1,17 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"syscall"
+)
+
+// Returned by MoveFileEx, which os.Rename uses, for paths on different volumes
+const errorNotSameDevice = syscall.Errno(17)
+
+// isCrossDevice reports whether err is rename failing because its paths are on different
+// volumes
+func isCrossDevice(err error) bool {
+	return errors.Is(err, errorNotSameDevice)
+}
@@ -0,0 +1,14 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestIsCrossDevice(t *testing.T) {
+	if !isCrossDevice(&os.LinkError{Op: "rename", Old: `C:\x.jpg`, New: `D:\x.jpg`, Err: errorNotSameDevice}) {
+		t.Error("ERROR_NOT_SAME_DEVICE isn't taken for crossing volumes")
+	}
+}
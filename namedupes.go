@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// NameGroup is a set of images sharing a file name, compared case-insensitively, in more
+// than one directory
+type NameGroup struct {
+	Name  string      `json:"name"` // as spelled by the first file found
+	Files []ImageFile `json:"files"`
+}
+
+// findNameDuplicates groups images by base name, ignoring case, and returns the groups whose
+// name turns up in several directories, with the largest groups first. Nothing is read, so
+// it is a cheap first pass before the content hashing of -find-dupes.
+func findNameDuplicates(files []ImageFile) []NameGroup {
+	byName := make(map[string][]ImageFile)
+	for _, file := range files {
+		key := strings.ToLower(filepath.Base(file.Path))
+		byName[key] = append(byName[key], file)
+	}
+
+	var groups []NameGroup
+	for _, sameName := range byName {
+		dirs := make(map[string]bool)
+		for _, file := range sameName {
+			dirs[filepath.Dir(file.Path)] = true
+		}
+		if len(dirs) < 2 {
+			continue
+		}
+		groups = append(groups, NameGroup{Name: filepath.Base(sameName[0].Path), Files: sameName})
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		if len(groups[i].Files) != len(groups[j].Files) {
+			return len(groups[i].Files) > len(groups[j].Files)
+		}
+		return strings.ToLower(groups[i].Name) < strings.ToLower(groups[j].Name)
+	})
+	return groups
+}
+
+// printNameDuplicates prints each group of same-named images with the location and size of
+// every copy
+func printNameDuplicates(w io.Writer, groups []NameGroup) {
+	fmt.Fprintln(w, "\nImages with the same name in several directories:")
+	for _, group := range groups {
+		fmt.Fprintf(w, "Name: %s | Copies: %d\n", group.Name, len(group.Files))
+		for _, file := range group.Files {
+			fmt.Fprintf(w, "  %s | Size: %s\n", pathText(file.Path), sizeText(file.Size))
+		}
+	}
+	fmt.Fprintf(w, "%d names found in more than one directory\n", len(groups))
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestFindNameDuplicates(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"2020/img_1.jpg", "2021/img_1.jpg", "2021/img_2.jpg", "backup/IMG_2.JPG", "backup/img_3.jpg", "backup/2022/img_1.jpg"} {
+		writeFile(t, filepath.Join(root, name), name)
+	}
+
+	groups := findNameDuplicates(scanFiles(t, root))
+	if len(groups) != 2 {
+		t.Fatalf("got %d groups, want img_1.jpg and img_2.jpg: %+v", len(groups), groups)
+	}
+	// The largest group comes first, and names are compared case-insensitively
+	if groups[0].Name != "img_1.jpg" || len(groups[0].Files) != 3 {
+		t.Errorf("first group: got %s with %d files, want img_1.jpg with 3", groups[0].Name, len(groups[0].Files))
+	}
+	if len(groups[1].Files) != 2 {
+		t.Errorf("second group: got %s with %d files, want img_2.jpg and IMG_2.JPG", groups[1].Name, len(groups[1].Files))
+	}
+
+	result := runJSON(t, "-name-dupes", root)
+	if len(result.NameDupes) != 2 || len(result.NameDupes[0].Files) != 3 {
+		t.Errorf("JSON: got %+v, want the same 2 groups", result.NameDupes)
+	}
+}
+
+func TestFindNameDuplicatesSameDirectory(t *testing.T) {
+	// Different extensions aren't the same name, and one folder never holds a duplicate
+	files := []ImageFile{{Path: filepath.Join("a", "img_1.jpg")}, {Path: filepath.Join("a", "img_1.png")}, {Path: filepath.Join("b", "img_2.jpg")}}
+	if groups := findNameDuplicates(files); len(groups) != 0 {
+		t.Errorf("got %+v, want no groups", groups)
+	}
+}
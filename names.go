@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// InvalidName is an image whose path isn't valid UTF-8, as written by some older systems.
+// Elsewhere in the JSON result such paths have their invalid bytes replaced by U+FFFD, so
+// Path shows them as \xNN escapes instead and Hex holds the real path, hex-encoded.
+type InvalidName struct {
+	Path string `json:"path"`
+	Hex  string `json:"hex"`
+}
+
+// sanitizePath returns path with each byte that isn't part of valid UTF-8 replaced by a \xNN
+// escape, so it can be printed and encoded safely. Valid paths are returned unchanged. Only
+// output uses it; files are always opened, moved and renamed by their real path.
+func sanitizePath(path string) string {
+	if utf8.ValidString(path) {
+		return path
+	}
+	var b strings.Builder
+	for i := 0; i < len(path); {
+		r, size := utf8.DecodeRuneInString(path[i:])
+		if r == utf8.RuneError && size == 1 {
+			fmt.Fprintf(&b, `\x%02x`, path[i])
+		} else {
+			b.WriteString(path[i : i+size])
+		}
+		i += size
+	}
+	return b.String()
+}
+
+// findInvalidNames returns the images in files whose path isn't valid UTF-8, in order
+func findInvalidNames(files []ImageFile) []InvalidName {
+	var invalid []InvalidName
+	for _, file := range files {
+		if !utf8.ValidString(file.Path) {
+			invalid = append(invalid, InvalidName{Path: sanitizePath(file.Path), Hex: hex.EncodeToString([]byte(file.Path))})
+		}
+	}
+	return invalid
+}
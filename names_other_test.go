@@ -0,0 +1,61 @@
+//go:build !windows
+
+package main
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestInvalidFilename(t *testing.T) {
+	root := t.TempDir()
+	name := "bad\xff name.jpg"
+	if err := os.WriteFile(filepath.Join(root, name), []byte("image"), 0o644); err != nil {
+		t.Skip("the filesystem rejects names that aren't UTF-8:", err)
+	}
+	writeFile(t, filepath.Join(root, "good.jpg"), "image")
+	path := filepath.Join(root, name)
+	shown := sanitizePath(path)
+
+	stdout, stderr, code := runCLI(t, "-format", "json", root)
+	if code != exitOK {
+		t.Fatalf("exit code %d, stderr:\n%s", code, stderr)
+	}
+	if !utf8.ValidString(stdout) {
+		t.Errorf("the JSON output isn't valid UTF-8:\n%q", stdout)
+	}
+	result := runJSON(t, root)
+	if result.TotalImages != 2 || len(result.InvalidNames) != 1 || result.InvalidNames[0].Path != shown {
+		t.Fatalf("got %d images and invalid names %+v, want 2 and %q", result.TotalImages, result.InvalidNames, shown)
+	}
+	if raw, _ := hex.DecodeString(result.InvalidNames[0].Hex); string(raw) != path {
+		t.Errorf("hex decodes to %q, want %q", raw, path)
+	}
+
+	stdout, stderr, _ = runCLI(t, "-sort-by", "path", root)
+	if !strings.Contains(stdout, "File: "+shown+" |") || !utf8.ValidString(stdout) {
+		t.Errorf("text output lacks the sanitized path %q:\n%q", shown, stdout)
+	}
+	if !strings.Contains(stderr, "aren't valid UTF-8") {
+		t.Errorf("no warning about the names:\n%s", stderr)
+	}
+
+	csvFile := filepath.Join(t.TempDir(), "images.csv")
+	runJSON(t, "-csv", csvFile, root)
+	if data, err := os.ReadFile(csvFile); err != nil || !utf8.Valid(data) || !strings.Contains(string(data), shown) {
+		t.Errorf("CSV: got %q, %v, want valid UTF-8 with the sanitized path", data, err)
+	}
+
+	// Moving goes by the real name, which the file keeps
+	dest := filepath.Join(t.TempDir(), "sorted")
+	if _, stderr, code := runCLI(t, "-q", "-yes", "-move", "-sort-into", dest, root); code != exitOK {
+		t.Fatalf("move: exit code %d, stderr:\n%s", code, stderr)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "jpg", name)); err != nil {
+		t.Errorf("the moved file lost its name: %v", err)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestSanitizePath(t *testing.T) {
+	tests := map[string]string{
+		"/photos/zdjęcie.jpg":        "/photos/zdjęcie.jpg",
+		"/photos/bad\xff.jpg":        `/photos/bad\xff.jpg`,
+		"/photos/\xe9t\xe9.jpg":      `/photos/\xe9t\xe9.jpg`, // Latin-1
+		"/photos/cut\xc4":            `/photos/cut\xc4`,       // a sequence cut short
+		"/\xc4\x99/\xf0\x9f\x93\xb7": "/ę/📷",
+	}
+	for path, want := range tests {
+		if got := sanitizePath(path); got != want {
+			t.Errorf("sanitizePath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestFindInvalidNames(t *testing.T) {
+	files := []ImageFile{{Path: "/p/ok.jpg"}, {Path: "/p/bad\xff.jpg"}, {Path: "/p/ę.jpg"}}
+	invalid := findInvalidNames(files)
+	if len(invalid) != 1 || invalid[0].Path != `/p/bad\xff.jpg` {
+		t.Fatalf("got %+v, want only bad\\xff.jpg", invalid)
+	}
+	if raw, err := hex.DecodeString(invalid[0].Hex); err != nil || string(raw) != files[1].Path {
+		t.Errorf("hex %s decodes to %q, %v, want the real path", invalid[0].Hex, raw, err)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// What each EXIF Orientation value asks a viewer to do to display the image upright
+var orientationNames = map[int]string{
+	1: "normal",
+	2: "mirrored horizontally",
+	3: "rotated 180°",
+	4: "mirrored vertically",
+	5: "mirrored horizontally and rotated 90° counterclockwise",
+	6: "rotated 90° clockwise",
+	7: "mirrored horizontally and rotated 90° clockwise",
+	8: "rotated 90° counterclockwise",
+}
+
+// RotatedImage is an image whose EXIF Orientation is anything but normal, so viewers that
+// ignore the tag show it sideways or mirrored
+type RotatedImage struct {
+	Path        string `json:"path"`
+	Orientation int    `json:"orientation"`
+}
+
+// readOrientation returns the EXIF Orientation of the image at path, or false if the file
+// has no EXIF block or no Orientation tag in it
+func readOrientation(path string) (int, bool) {
+	x, _ := readExif(path) // as with capture dates, a damaged block may still hold the tag
+	if x == nil {
+		return 0, false
+	}
+	tag, err := x.Get(exif.Orientation)
+	if err != nil {
+		return 0, false
+	}
+	orientation, err := tag.Int(0)
+	if err != nil {
+		return 0, false
+	}
+	return orientation, true
+}
+
+// findRotated reads the EXIF Orientation of every image in a format that carries EXIF, using
+// the given number of worker goroutines. It returns the images with a non-normal orientation
+// and those whose orientation is unknown, both in the order of files.
+func findRotated(files []ImageFile, workers int) (rotated []RotatedImage, unknown []string) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	// Each worker writes only the entries for the indexes it receives
+	orientations := make([]int, len(files))
+	found := make([]bool, len(files))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				orientations[i], found[i] = readOrientation(files[i].Path)
+			}
+		}()
+	}
+
+	for i, file := range files {
+		if exifExtensions[file.Ext] {
+			indexes <- i
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	for i, file := range files {
+		switch {
+		case !exifExtensions[file.Ext]:
+			continue
+		case !found[i]:
+			unknown = append(unknown, file.Path)
+		case orientations[i] != 1:
+			rotated = append(rotated, RotatedImage{Path: file.Path, Orientation: orientations[i]})
+		}
+	}
+	return rotated, unknown
+}
+
+// printRotated prints the images that need rotating to display upright and how many
+// images had no orientation to check
+func printRotated(w io.Writer, rotated []RotatedImage, unknown []string) {
+	fmt.Fprintln(w, "\nImages with a non-default EXIF orientation:")
+	for _, r := range rotated {
+		name, ok := orientationNames[r.Orientation]
+		if !ok {
+			name = "invalid"
+		}
+		fmt.Fprintf(w, "File: %s | Orientation: %d (%s)\n", pathText(r.Path), r.Orientation, name)
+	}
+	fmt.Fprintf(w, "%d need rotation, %d with unknown orientation\n", len(rotated), len(unknown))
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+// startProfiles starts writing a CPU profile to cpuFile and arranges for a heap profile to
+// be written to memFile, either being skipped when empty. The returned stop function ends
+// the CPU profile and writes the heap profile; run defers it, so both are complete however
+// the scan ends. Inspect them with the standard tooling, for example:
+//
+//	image_sorter -cpuprofile cpu.out -memprofile mem.out -workers 16 ~/Pictures
+//	go tool pprof -top cpu.out
+//	go tool pprof -sample_index=alloc_space mem.out
+func startProfiles(cpuFile, memFile string) (stop func(), err error) {
+	var cpu *os.File
+	if cpuFile != "" {
+		if cpu, err = os.Create(cpuFile); err != nil {
+			return nil, err
+		}
+		if err := pprof.StartCPUProfile(cpu); err != nil {
+			cpu.Close()
+			return nil, err
+		}
+	}
+
+	return func() {
+		if cpu != nil {
+			pprof.StopCPUProfile()
+			if err := cpu.Close(); err != nil {
+				fmt.Fprintln(os.Stderr, "Error writing CPU profile:", err)
+			}
+		}
+		if memFile != "" {
+			if err := writeHeapProfile(memFile); err != nil {
+				fmt.Fprintln(os.Stderr, "Error writing memory profile:", err)
+			}
+		}
+	}, nil
+}
+
+// writeHeapProfile writes a heap profile to path, after a garbage collection so it shows
+// the memory still in use
+func writeHeapProfile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/mattn/go-isatty"
+)
+
+// scanProgress holds running totals that the scan updates and the progress reporter reads
+type scanProgress struct {
+	images atomic.Int64
+	bytes  atomic.Int64
+	total  int64 // images expected in all, from a counting pass (-eta); 0 if unknown
+}
+
+// Width of the -eta progress bar in characters
+const barWidth = 30
+
+// add counts one more scanned image of the given size
+func (p *scanProgress) add(size int64) {
+	p.images.Add(1)
+	p.bytes.Add(size)
+}
+
+// start prints the running totals to w once per interval on a single, rewritten line.
+// The returned function stops the reporter and clears the line.
+func (p *scanProgress) start(w io.Writer, interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	started := time.Now()
+	done := make(chan struct{})
+	finished := make(chan struct{})
+
+	go func() {
+		defer close(finished)
+		for {
+			select {
+			case <-ticker.C:
+				if p.total > 0 {
+					fmt.Fprintf(w, "\r\033[K%s", p.bar(time.Since(started)))
+				} else {
+					fmt.Fprintf(w, "\rscanned %s images, %s...", formatInt(p.images.Load()), humanSize(p.bytes.Load()))
+				}
+			case <-done:
+				fmt.Fprint(w, "\r\033[K")
+				return
+			}
+		}
+	}()
+
+	return func() {
+		ticker.Stop()
+		close(done)
+		<-finished
+	}
+}
+
+// bar renders the progress towards total as a bar with the percentage done and the time
+// remaining, estimated from the throughput so far
+func (p *scanProgress) bar(elapsed time.Duration) string {
+	images := p.images.Load()
+	fraction := min(float64(images)/float64(p.total), 1) // filtered-out images are never counted
+	filled := int(fraction * barWidth)
+
+	eta := "ETA --"
+	if images > 0 {
+		remaining := time.Duration(float64(elapsed) * (1 - fraction) / fraction)
+		eta = "ETA " + remaining.Round(time.Second).String()
+	}
+	return fmt.Sprintf("[%s%s] %3.0f%% %s/%s images, %s, %s",
+		strings.Repeat("=", filled), strings.Repeat(" ", barWidth-filled), fraction*100,
+		formatInt(images), formatInt(p.total), humanSize(p.bytes.Load()), eta)
+}
+
+// isTerminal reports whether f is connected to a terminal rather than a file, pipe or
+// device like /dev/null
+func isTerminal(f *os.File) bool {
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/kwdowicz/image_sorter/scanner"
+)
+
+// Most recommendations printed at the end of the text report
+const maxRecommendations = 5
+
+// findings are the results of the analyses a run did, in the shape the recommendations
+// need; those that didn't run are empty
+type findings struct {
+	files        []ImageFile // images on disk, for spotting RAW+JPEG pairs
+	totalSize    int64
+	keepPairs    bool // -keep-pairs was set
+	duplicates   []DuplicateGroup
+	dupesDeleted bool // -delete-dupes already ran
+	zeroByte     []string
+	largest      []ImageFile
+	corrupt      int
+	mismatches   int
+	rotated      int
+	screenshots  int
+	emptyDirs    int
+	similar      int
+}
+
+// rawWithJPEG counts the RAW images in files with a JPEG of the same name next to them
+func rawWithJPEG(files []ImageFile) int {
+	jpegs := make(map[string]bool)
+	for _, file := range files {
+		if scanner.CanonicalExt(file.Ext) == ".jpg" {
+			jpegs[pairKey(file.Path)] = true
+		}
+	}
+	count := 0
+	for _, file := range files {
+		if scanner.CategoryOf(file.Ext) == "RAW" && jpegs[pairKey(file.Path)] {
+			count++
+		}
+	}
+	return count
+}
+
+// recommendations turns the findings into plain-language advice, the most pressing first,
+// and at most maxRecommendations of them
+func recommendations(f findings) []string {
+	var recs []string
+	if f.corrupt > 0 {
+		recs = append(recs, fmt.Sprintf("%s images are corrupt and won't open; restore them from a backup while you still can", formatInt(int64(f.corrupt))))
+	}
+	if len(f.duplicates) > 0 && !f.dupesDeleted {
+		files, wasted := 0, int64(0)
+		for _, group := range f.duplicates {
+			files += len(group.Files)
+			wasted += group.Wasted
+		}
+		recs = append(recs, fmt.Sprintf("Found %s of exact-duplicate images across %s files — run with -delete-dupes to reclaim space",
+			sizeText(wasted), formatInt(int64(files))))
+	}
+	if len(f.zeroByte) > 0 {
+		recs = append(recs, fmt.Sprintf("%s images are empty, likely failed downloads or copies — delete them or copy them again", formatInt(int64(len(f.zeroByte)))))
+	}
+	if f.mismatches > 0 {
+		recs = append(recs, fmt.Sprintf("%s images have the wrong extension for their content — rename them so other programs open them", formatInt(int64(f.mismatches))))
+	}
+	if pairs := rawWithJPEG(f.files); pairs > 0 && !f.keepPairs {
+		recs = append(recs, fmt.Sprintf("%s RAW files with matching JPEGs detected — sort with -keep-pairs to keep each pair together", formatInt(int64(pairs))))
+	}
+	if f.rotated > 0 {
+		recs = append(recs, fmt.Sprintf("%s images are displayed rotated by their EXIF orientation — see the list above", formatInt(int64(f.rotated))))
+	}
+	if f.screenshots > 0 {
+		recs = append(recs, fmt.Sprintf("%s images look like screenshots — see the list above to move them out of your photos", formatInt(int64(f.screenshots))))
+	}
+	if len(f.largest) > 0 && f.totalSize > 0 {
+		size := int64(0)
+		for _, file := range f.largest {
+			size += file.Size
+		}
+		recs = append(recs, fmt.Sprintf("The %s largest images take up %s, %.0f%% of the total — archiving them frees the most space",
+			formatInt(int64(len(f.largest))), sizeText(size), float64(size)*100/float64(f.totalSize)))
+	}
+	if f.similar > 0 {
+		recs = append(recs, fmt.Sprintf("%s groups of near-identical images found — keeping the best of each saves space", formatInt(int64(f.similar))))
+	}
+	if f.emptyDirs > 0 {
+		recs = append(recs, fmt.Sprintf("%s folders hold no images — see the list above for the ones to remove", formatInt(int64(f.emptyDirs))))
+	}
+	if len(recs) > maxRecommendations {
+		recs = recs[:maxRecommendations]
+	}
+	return recs
+}
+
+// printRecommendations prints the recommendations for the findings, if there are any
+func printRecommendations(w io.Writer, f findings) {
+	recs := recommendations(f)
+	if len(recs) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "\nRecommendations:")
+	for _, rec := range recs {
+		fmt.Fprintln(w, rec)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// relativeRoots makes pathText print paths relative to the scan root holding them, for a
+// shorter report. It is set once from the -relative flag before anything is printed.
+var relativeRoots []string
+
+// pathText formats a file or directory path for the text report, as relativePath does, with
+// the bytes that aren't valid UTF-8 escaped, see sanitizePath
+func pathText(path string) string {
+	return sanitizePath(relativePath(path))
+}
+
+// relativePath returns path as found unless -relative is set. Then it is made relative to
+// the innermost root containing it, prefixed with that root's 1-based index when several
+// roots were scanned. Paths below no root, like those read with -from-stdin, are left absolute.
+func relativePath(path string) string {
+	best, bestIndex := "", 0
+	for i, root := range relativeRoots {
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if best == "" || len(rel) < len(best) {
+			best, bestIndex = rel, i
+		}
+	}
+	if best == "" {
+		return path
+	}
+	if len(relativeRoots) > 1 {
+		return fmt.Sprintf("[%d] %s", bestIndex+1, best)
+	}
+	return best
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRelativePath(t *testing.T) {
+	defer func(saved []string) { relativeRoots = saved }(relativeRoots)
+	photos, backup := filepath.FromSlash("/data/photos"), filepath.FromSlash("/data/backup")
+	nested := filepath.Join(photos, "2020")
+	in := func(dir, name string) string { return filepath.Join(dir, filepath.FromSlash(name)) }
+
+	tests := []struct {
+		roots      []string
+		path, want string
+	}{
+		{nil, in(photos, "a.jpg"), in(photos, "a.jpg")},
+		{[]string{photos}, in(photos, "sub/a.jpg"), filepath.FromSlash("sub/a.jpg")},
+		{[]string{photos}, photos, "."},
+		{[]string{photos}, in(backup, "a.jpg"), in(backup, "a.jpg")}, // below no root
+		{[]string{photos, backup}, in(backup, "a.jpg"), "[2] a.jpg"},
+		{[]string{photos, nested}, in(nested, "a.jpg"), "[2] a.jpg"}, // the innermost root wins
+	}
+	for _, tt := range tests {
+		relativeRoots = tt.roots
+		if got := relativePath(tt.path); got != tt.want {
+			t.Errorf("roots %q: relativePath(%q) = %q, want %q", tt.roots, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRelativeFlag(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "sub", "a.jpg"), "image")
+
+	stdout, _, _ := runCLI(t, "-relative", "-min-count", "0", root)
+	if !strings.Contains(stdout, "File: "+filepath.Join("sub", "a.jpg")+" |") || !strings.Contains(stdout, "Directory: sub |") {
+		t.Errorf("paths in the report aren't relative:\n%s", stdout)
+	}
+	if strings.Contains(stdout, "File: "+root) {
+		t.Errorf("absolute file path in the report:\n%s", stdout)
+	}
+
+	// The JSON output keeps absolute paths
+	if result := runJSON(t, "-relative", "-min-count", "0", root); len(result.AcceptedDirs) != 1 || result.AcceptedDirs[0] != filepath.Join(root, "sub") {
+		t.Errorf("JSON accepted dirs %q, want the absolute path", result.AcceptedDirs)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// Default -rename-pattern: the capture time, like 20230715_143012
+const defaultRenamePattern = `{{.Taken.Format "20060102_150405"}}`
+
+// RenameSummary counts what renameImages did with the files it was given
+type RenameSummary struct {
+	Renamed   int // files given a new name (or that would have been, in a dry run)
+	Unchanged int // files already named after the pattern
+	Skipped   int // files that could not be renamed
+}
+
+// renameStep is the planned new name of a single file
+type renameStep struct {
+	file   ImageFile
+	target string
+}
+
+// renameTarget returns the path in dir for an image at self to be renamed to stem plus ext,
+// that neither exists on disk nor is in taken, appending " (1)", " (2)", ... to the stem
+// until it is free. The image's own path counts as free, so renaming again is a no-op.
+func renameTarget(dir, stem, ext, self string, taken map[string]bool) string {
+	selfInfo, selfErr := os.Stat(self)
+	for i := 0; ; i++ {
+		name := stem
+		if i > 0 {
+			name = fmt.Sprintf("%s (%d)", stem, i)
+		}
+		target := filepath.Join(dir, name+ext)
+		if target == self {
+			return target
+		}
+		if taken[target] {
+			continue
+		}
+		info, err := os.Stat(target)
+		if err != nil {
+			return target
+		}
+		// A case-insensitive filesystem finds the image itself under a name differing in case
+		if selfErr == nil && os.SameFile(info, selfInfo) {
+			return target
+		}
+	}
+}
+
+// planRenames picks the new name of each file from tmpl, skipping those it fails on or
+// produces no usable name for
+func planRenames(files []ImageFile, tmpl *template.Template) (steps []renameStep, skipped int) {
+	taken := make(map[string]bool)
+	for _, file := range files {
+		var stem strings.Builder
+		if err := tmpl.Execute(&stem, newTemplateFile(file)); err != nil {
+			logger.Error("naming failed", "path", file.Path, "err", err)
+			skipped++
+			continue
+		}
+		name := strings.TrimSpace(stem.String())
+		if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+			logger.Error("naming failed", "path", file.Path, "err", fmt.Errorf("-rename-pattern gave %q, which is no file name", name))
+			skipped++
+			continue
+		}
+		target := renameTarget(filepath.Dir(file.Path), name, file.Ext, file.Path, taken)
+		taken[target] = true
+		steps = append(steps, renameStep{file: file, target: target})
+	}
+	return steps, skipped
+}
+
+// renameImages renames each image in its folder to the name tmpl gives it followed by its
+// lowercase extension, appending " (1)", " (2)", ... on a collision. With dryRun set the
+// renames are only logged to out. Otherwise confirm is asked first with the number of files
+// to rename, and its error returned if it declines. Failures on single files are reported
+// to stderr and counted as skipped.
+func renameImages(files []ImageFile, tmpl *template.Template, dryRun bool, confirm func(count int) error, out io.Writer) (RenameSummary, error) {
+	var summary RenameSummary
+	steps, skipped := planRenames(files, tmpl)
+	summary.Skipped = skipped
+	pending := 0
+	for _, step := range steps {
+		if step.target != step.file.Path {
+			pending++
+		}
+	}
+	if !dryRun && pending > 0 && confirm != nil {
+		if err := confirm(pending); err != nil {
+			return summary, err
+		}
+	}
+
+	for _, step := range steps {
+		path, target := step.file.Path, step.target
+		if target == path {
+			summary.Unchanged++
+			continue
+		}
+		if dryRun {
+			fmt.Fprintf(out, "Would rename: %s -> %s\n", sanitizePath(path), sanitizePath(target))
+			summary.Renamed++
+			continue
+		}
+		if err := os.Rename(path, target); err != nil {
+			logger.Error("renaming failed", "path", path, "err", err)
+			summary.Skipped++
+			continue
+		}
+		fmt.Fprintf(out, "Rename: %s -> %s\n", sanitizePath(path), sanitizePath(target))
+		summary.Renamed++
+	}
+	return summary, nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// renameTree writes images with inconsistent names: one with an EXIF capture time, two
+// without sharing a modification time, and one already named after it
+func renameTree(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	taken := asciiEntry(0x132, "2019:07:04 10:20:30") // DateTime
+	writeFile(t, filepath.Join(dir, "DSC_0001.JPG"), string(exifJPEG(t, 8, 8, []exifEntry{taken}, nil)))
+	modified := time.Date(2021, 12, 24, 18, 5, 0, 0, time.Local)
+	for _, name := range []string{"WhatsApp Image 1.jpg", "image.jpg", "20200101_000000.png"} {
+		path := filepath.Join(dir, name)
+		writeFile(t, path, name)
+		at := modified
+		if strings.HasPrefix(name, "2020") {
+			at = time.Date(2020, 1, 1, 0, 0, 0, 0, time.Local)
+		}
+		if err := os.Chtimes(path, at, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestRenameImages(t *testing.T) {
+	dir := renameTree(t)
+	tmpl, err := parseFileTemplate("-rename-pattern", defaultRenamePattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	summary, err := renameImages(scanFiles(t, dir), tmpl, true, nil, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Renamed != 3 || summary.Unchanged != 1 || strings.Count(out.String(), "Would rename: ") != 3 {
+		t.Errorf("dry run: got %+v, want 3 renamed and 1 unchanged; output:\n%s", summary, out.String())
+	}
+	before := []string{"20200101_000000.png", "DSC_0001.JPG", "WhatsApp Image 1.jpg", "image.jpg"}
+	if got := listFiles(t, dir); !slices.Equal(got, before) {
+		t.Fatalf("dry run: got %q, want nothing renamed", got)
+	}
+
+	if _, err := renameImages(scanFiles(t, dir), tmpl, false, nil, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	// The capture time wins over the modification time, and the extension is lowercased
+	after := []string{"20190704_102030.jpg", "20200101_000000.png", "20211224_180500 (1).jpg", "20211224_180500.jpg"}
+	if got := listFiles(t, dir); !slices.Equal(got, after) {
+		t.Errorf("got %q, want %q", got, after)
+	}
+
+	// Renaming again leaves every name alone, including the one with the counter
+	summary, err = renameImages(scanFiles(t, dir), tmpl, false, nil, io.Discard)
+	if err != nil || summary.Renamed != 0 || summary.Unchanged != 4 {
+		t.Errorf("second run: got %+v, %v, want all 4 unchanged", summary, err)
+	}
+}
+
+func TestRenamePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    []string
+		skipped int
+	}{
+		{`{{.Taken.Format "2006-01"}}`, []string{"2019-07.jpg", "2020-01.png", "2021-12 (1).jpg", "2021-12.jpg"}, 0},
+		{`{{.Width}}x{{.Height}}`, []string{"0x0 (1).jpg", "0x0.jpg", "0x0.png", "8x8.jpg"}, 0}, // only the JPEG decodes
+		{`{{.Taken.Year}}/{{.Taken.Month}}`, []string{"20200101_000000.png", "DSC_0001.JPG", "WhatsApp Image 1.jpg", "image.jpg"}, 4},
+		{` `, []string{"20200101_000000.png", "DSC_0001.JPG", "WhatsApp Image 1.jpg", "image.jpg"}, 4},
+	}
+	for _, tt := range tests {
+		dir := renameTree(t)
+		files := scanFiles(t, dir)
+		tmpl, err := parseFileTemplate("-rename-pattern", tt.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		summary, err := renameImages(files, tmpl, false, nil, io.Discard)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := listFiles(t, dir); !slices.Equal(got, tt.want) || summary.Skipped != tt.skipped {
+			t.Errorf("%q: got %q and %d skipped, want %q and %d", tt.pattern, got, summary.Skipped, tt.want, tt.skipped)
+		}
+	}
+}
+
+func TestRenameFlag(t *testing.T) {
+	dir := renameTree(t)
+	stdout, stderr, code := runCLI(t, "-yes", "-rename", "-rename-pattern", `photo_{{.Taken.Format "2006"}}`, dir)
+	if code != exitOK {
+		t.Fatalf("exit code %d, stderr:\n%s", code, stderr)
+	}
+	want := []string{"photo_2019.jpg", "photo_2020.png", "photo_2021 (1).jpg", "photo_2021.jpg"}
+	if got := listFiles(t, dir); !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if !strings.Contains(stdout, "Renamed 4 files (0 already named, 0 skipped)") {
+		t.Errorf("output lacks the summary:\n%s", stdout)
+	}
+}
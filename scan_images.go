@@ -1,135 +1,1462 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
+	"os/signal"
 	"path/filepath"
-	"sort"
+	"runtime"
+	"slices"
 	"strings"
+	"text/template"
+	"time"
+
+	"github.com/kwdowicz/image_sorter/scanner"
 )
 
-// List of common image file extensions, including mobile-specific and RAW formats
-var imageExtensions = map[string]bool{
-	".jpg":   true,
-	".jpeg":  true,
-	".png":   true,
-	".gif":   true,
-	".bmp":   true,
-	".tiff":  true,
-	".svg":   true,
-	".webp":  true,
-	".heic":  true, // High Efficiency Image Format used on iOS devices
-	".heif":  true, // Another High Efficiency Image Format extension
-	".raw":   true, // RAW image format
-	".cr2":   true, // Canon RAW format
-	".nef":   true, // Nikon RAW format
-	".orf":   true, // Olympus RAW format
-	".sr2":   true, // Sony RAW format
-	".arw":   true, // Sony RAW format
-	".dng":   true, // Adobe Digital Negative format
-	".rw2":   true, // Panasonic RAW format
-}
+// Types shared with the scanner package, aliased so the rest of the command can use them unqualified
+type (
+	ImageFile     = scanner.ImageFile
+	DirCount      = scanner.DirCount
+	DirSize       = scanner.DirSize
+	CategoryTotal = scanner.CategoryTotal
+	ExtTotal      = scanner.ExtTotal
+)
 
-// List of directories to ignore
-var ignoreDirs = []string{
-	"Windows",
-	"Program Files",
-	"System Volume Information",
-	"$Recycle.Bin",
-	"Users",
-	"SmartPSS",
-	"Python312",
-	"ProgramData",
+// normalizeExtensions turns a comma-separated list like "HEIC, dng" into lowercase extensions
+// with a leading dot, dropping empty entries
+func normalizeExtensions(list string) []string {
+	var exts []string
+	for _, ext := range strings.Split(list, ",") {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		exts = append(exts, ext)
+	}
+	return exts
 }
 
-// scanDirectory scans the specified directory, prints each image file's path and size, 
-// adds up the total size of all found images, and tracks image counts per directory.
-func scanDirectory(root string) (int64, map[string]int, error) {
-	var totalSize int64
-	dirFileCount := make(map[string]int)
-
-	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
+// selectExtensions returns the set of extensions to scan for. An empty include list selects
+// all built-in scanner.DefaultExtensions; otherwise only the listed ones are used, with a
+// warning on stderr for any that are not recognized. With video set, the
+// scanner.VideoExtensions are added. The extensions in the exclude list are then removed
+// from the set.
+func selectExtensions(include, exclude string, video bool) map[string]bool {
+	selected := make(map[string]bool)
+	if exts := normalizeExtensions(include); len(exts) == 0 {
+		for ext := range scanner.DefaultExtensions {
+			selected[ext] = true
 		}
-
-		// Skip ignored directories
-		for _, ignoreDir := range ignoreDirs {
-			if info.IsDir() && strings.Contains(path, ignoreDir) {
-				return filepath.SkipDir
+	} else {
+		for _, ext := range exts {
+			if !scanner.DefaultExtensions[ext] && !(video && scanner.VideoExtensions[ext]) {
+				logger.Warn("not a recognized image extension", "ext", ext)
 			}
+			selected[ext] = true
 		}
+	}
 
-		// Check if the file has an image extension and process it
-		if !info.IsDir() && imageExtensions[strings.ToLower(filepath.Ext(path))] {
-			fileSize := info.Size()
-			fmt.Printf("File: %s | Size: %d bytes\n", path, fileSize)
-			totalSize += fileSize // Add the file size to the total
-
-			// Track the count of images in each directory
-			dir := filepath.Dir(path)
-			dirFileCount[dir]++
+	if video {
+		for ext := range scanner.VideoExtensions {
+			selected[ext] = true
 		}
+	}
+	for _, ext := range normalizeExtensions(exclude) {
+		delete(selected, ext)
+	}
+	return selected
+}
+
+// RootTotal is the subtotal for one of several scanned root directories
+type RootTotal struct {
+	Root      string `json:"root"`
+	TotalSize int64  `json:"total_size"`
+	Images    int    `json:"images"`
+}
+
+// ScanResult is the machine-readable summary of a scan, emitted with -format json
+type ScanResult struct {
+	SchemaVersion int              `json:"schema_version"` // see resultSchemaVersion
+	TotalSize     int64            `json:"total_size"`
+	TotalImages   int              `json:"total_images"`
+	Roots         []RootTotal      `json:"roots,omitempty"`
+	SkippedPaths  []string         `json:"skipped_paths,omitempty"`
+	InvalidNames  []InvalidName    `json:"invalid_names,omitempty"`
+	ZeroByte      []string         `json:"zero_byte,omitempty"` // left out of the totals unless -include-zero-byte is set
+	Sampled       bool             `json:"sampled,omitempty"`   // the scan stopped at the -sample limit, so the totals are partial
+	Categories    []CategoryTotal  `json:"categories"`
+	Extensions    []ExtTotal       `json:"extensions"`
+	Directories   []DirCount       `json:"directories"`
+	DirSizes      []DirSize        `json:"directory_sizes"`
+	AcceptedDirs  []string         `json:"accepted_dirs"`
+	Months        []MonthCount     `json:"months,omitempty"`
+	Locations     []LocationCount  `json:"locations,omitempty"`
+	NoGPS         int              `json:"no_gps,omitempty"` // images without a GPS position, with -by-geo
+	Duplicates    []DuplicateGroup `json:"duplicates,omitempty"`
+	NameDupes     []NameGroup      `json:"name_duplicates,omitempty"`
+	EmptyDirs     []EmptyDir       `json:"empty_dirs,omitempty"`
+	Corrupt       []BadImage       `json:"corrupt,omitempty"`
+	Unverifiable  []string         `json:"unverifiable,omitempty"`
+	Mismatches    []TypeMismatch   `json:"type_mismatches,omitempty"`
+	Rotated       []RotatedImage   `json:"needs_rotation,omitempty"`
+	NoOrientation []string         `json:"orientation_unknown,omitempty"`
+	Screenshots   []Screenshot     `json:"screenshots,omitempty"`
+	LivePhotos    []LiveGroup      `json:"live_photos,omitempty"`
+	Similar       []SimilarGroup   `json:"similar,omitempty"`
+	Manifest      *ManifestCheck   `json:"manifest_check,omitempty"`
+	Compare       *CompareResult   `json:"compare,omitempty"`
+	Largest       []ImageFile      `json:"largest,omitempty"`
+	SortPreviews  []SortPreview    `json:"sort_preview,omitempty"` // what each -dry-run sort would have done
+	DurationMS    int64            `json:"duration_ms"`            // wall time of the whole run
+}
+
+// dirPaths returns the paths of the given directory counts
+func dirPaths(dirs []DirCount) []string {
+	paths := make([]string, len(dirs))
+	for i, dc := range dirs {
+		paths[i] = dc.Path
+	}
+	return paths
+}
+
+// printDirectoryFileCounts sorts and prints directory paths by file count in descending order,
+// listing only directories with more than minCount image files, and at most top of them
+// when top > 0. Nothing is printed if no directory qualifies. The printed directories'
+// paths are returned.
+func printDirectoryFileCounts(w io.Writer, dirFileCount map[string]int, minCount, top int) []string {
+	acceptedDirs := scanner.AcceptDirs(scanner.SortDirCounts(dirFileCount), minCount, top)
+	if len(acceptedDirs) == 0 {
 		return nil
-	})
+	}
 
-	return totalSize, dirFileCount, err
+	// Print the sorted directory counts
+	fmt.Fprintln(w, "\nDirectories sorted by number of image files:")
+	for _, dc := range acceptedDirs {
+		fmt.Fprintf(w, "Directory: %s | Image Files: %s\n", pathText(dc.Path), formatInt(int64(dc.Count)))
+	}
+	return dirPaths(acceptedDirs)
 }
 
-// printDirectoryFileCounts sorts and prints directory paths by file count in descending order
-func printDirectoryFileCounts(dirFileCount map[string]int) []string {
-	var acceptedDirs []string 
-	type dirCount struct {
-		path  string
-		count int
+// printDirectorySizes prints directory paths by total image size in descending order,
+// at most top of them when top > 0
+func printDirectorySizes(w io.Writer, dirFileSize map[string]int64, top int) {
+	sortedDirs := scanner.SortDirSizes(dirFileSize)
+	if top > 0 && len(sortedDirs) > top {
+		sortedDirs = sortedDirs[:top]
 	}
 
-	var sortedDirs []dirCount
-	for dir, count := range dirFileCount {
-		sortedDirs = append(sortedDirs, dirCount{path: dir, count: count})
+	fmt.Fprintln(w, "\nDirectories sorted by size of image files:")
+	for _, ds := range sortedDirs {
+		size := sizeText(ds.Size)
+		if ds.Size > largeDirSize {
+			size = paint(colorLarge, size)
+		}
+		fmt.Fprintf(w, "Directory: %s | Size: %s\n", pathText(ds.Path), size)
 	}
+}
 
-	// Sort by file count in descending order
-	sort.Slice(sortedDirs, func(i, j int) bool {
-		return sortedDirs[i].count > sortedDirs[j].count
-	})
+// printFiles prints one line per image with its path and size, plus its pixel
+// dimensions when dims is set, or formatted with tmpl if it isn't nil
+func printFiles(out io.Writer, files []ImageFile, dims, gps bool, tmpl *template.Template) {
+	if tmpl != nil {
+		printTemplateFiles(out, files, tmpl)
+		return
+	}
+	for _, file := range files {
+		path := pathText(file.Path)
+		if scanner.CategoryOf(file.Ext) == "RAW" {
+			path = paint(colorRAW, path)
+		}
+		line := fmt.Sprintf("File: %s | Size: %s", path, sizeText(file.Size))
+		if dims {
+			// Formats we can't read dimensions from (RAW, or WebP without -tags webp) are
+			// reported as unknown rather than failing
+			if width, height, err := imageDimensions(file.Path); err == nil {
+				line += fmt.Sprintf(" | Dims: %dx%d", width, height)
+			} else {
+				line += " | Dims: unknown"
+			}
+		}
+		if gps {
+			if lat, lon, ok := readGPS(file.Path); ok {
+				line += fmt.Sprintf(" | GPS: %.5f, %.5f", lat, lon)
+			} else {
+				line += " | GPS: none"
+			}
+		}
+		fmt.Fprintln(out, line)
+	}
+}
 
-	// Print the sorted directory counts
-	fmt.Println("\nDirectories sorted by number of image files:")
-	for _, dc := range sortedDirs {
-		if dc.count > 5 {
-			fmt.Printf("Directory: %s | Image Files: %d\n", dc.path, dc.count)
-			acceptedDirs = append(acceptedDirs, dc.path)
+// printDirList prints the directories for -list-dirs, one per line, or with nul set each
+// ended by a NUL byte and unescaped, so no name, however odd, can break the list apart
+func printDirList(w io.Writer, dirs []string, nul bool) {
+	for _, dir := range dirs {
+		if nul {
+			fmt.Fprint(w, relativePath(dir), "\x00")
+		} else {
+			fmt.Fprintln(w, pathText(dir))
 		}
 	}
-	return acceptedDirs
 }
 
-func main() {
-	// Get the directory to scan from the command line
-	if len(os.Args) < 2 {
-		fmt.Println("Usage: go run scan_images.go <directory>")
+// printZeroByte warns about the zero-byte images left out of the totals
+func printZeroByte(w io.Writer, paths []string) {
+	fmt.Fprintln(w, "\nZero-byte images, likely failed downloads (not counted; see -include-zero-byte):")
+	for _, path := range paths {
+		fmt.Fprintln(w, pathText(path))
+	}
+	fmt.Fprintf(w, "%s zero-byte images\n", formatInt(int64(len(paths))))
+}
+
+// printRootTotals prints the subtotal of each scanned root, if there were several
+func printRootTotals(w io.Writer, rootTotals []RootTotal) {
+	if len(rootTotals) == 0 {
 		return
 	}
+	fmt.Fprintln(w)
+	for _, rt := range rootTotals {
+		fmt.Fprintf(w, "Root: %s | Image Files: %s | Size: %s\n", rt.Root, formatInt(int64(rt.Images)), sizeText(rt.TotalSize))
+	}
+}
 
-	root := os.Args[1] // Use the provided directory
+// printScanTime prints how long the run took and the rate at which it got through the
+// images' bytes
+func printScanTime(w io.Writer, images int, bytes int64, elapsed time.Duration) {
+	rate := "-"
+	if elapsed > 0 {
+		rate = sizeText(int64(float64(bytes)/elapsed.Seconds())) + "/s"
+	}
+	fmt.Fprintf(w, "\nScanned %s images (%s) in %s — %s\n", formatInt(int64(images)), sizeText(bytes), elapsed.Round(time.Millisecond), rate)
+}
 
-	fmt.Println("Scanning for image files in:", root)
-	totalSize, dirFileCount, err := scanDirectory(root)
+// printJSON writes the scan result to stdout as a single JSON object
+func printJSON(w io.Writer, result ScanResult, pretty bool) error {
+	var data []byte
+	var err error
+	if pretty {
+		data, err = json.MarshalIndent(result, "", "  ")
+	} else {
+		data, err = json.Marshal(result)
+	}
 	if err != nil {
-		fmt.Println("Error scanning directory:", err)
-		return
+		return err
+	}
+	fmt.Fprintln(w, string(data))
+	return nil
+}
+
+// Exit codes returned by run
+const (
+	exitOK    = 0
+	exitError = 1 // the scan or a follow-up operation failed
+	exitUsage = 2 // the command line was invalid
+
+	exitInterrupted = 130 // the scan was cancelled with Ctrl-C; only a partial summary was printed
+)
+
+// Source name standing for the list of paths read with -from-stdin
+const stdinSource = "-"
+
+func main() {
+	os.Exit(run())
+}
+
+// run parses the command line, performs the scan and prints the report, returning the
+// process exit code
+func run() (code int) {
+	started := time.Now()
+	format := flag.String("format", "text", "output format: text, json, jsonl (one JSON object per image as it is found, then a summary), markdown (the summary as GitHub-flavored tables) or html (the summary as a self-contained page)")
+	pretty := flag.Bool("pretty", false, "indent JSON output with two spaces")
+	minCount := flag.Int("min-count", 5, "only report directories with more than this many image files")
+	listDirs := flag.Bool("list-dirs", false, "only print the accepted directories (see -min-count), one per line, for piping into other commands")
+	top := flag.Int("top", 0, "only report the N directories with the most image files (0 = no limit)")
+	byDate := flag.Bool("by-date", false, "count images per capture month (YYYY-MM) instead of per directory")
+	byGeo := flag.Bool("by-geo", false, "count images per 0.1° grid cell of their EXIF GPS position instead of per directory, and print each image's position")
+	sortInto := flag.String("sort-into", "", "copy found images into `dest`/<ext>/ subfolders")
+	sortByDate := flag.String("sort-by-date", "", "copy found images into `dest`/YYYY/MM/ folders by capture date")
+	consolidate := flag.String("consolidate", "", "copy all found images directly into `dest`, naming colliding files after their source folder")
+	rename := flag.Bool("rename", false, "rename each image in its folder after its capture time, or modification time if it has none; see -rename-pattern; honors -dry-run")
+	renamePattern := flag.String("rename-pattern", defaultRenamePattern, "Go text/template for the names -rename gives, with the fields of -template; the lowercase extension is appended")
+	move := flag.Bool("move", false, "with -sort-into, -sort-by-date or -consolidate, move images instead of copying them")
+	assumeYes := flag.Bool("yes", false, "don't ask before moving, copying or deleting files (required when stdin is not a terminal)")
+	var flatten optionalBool
+	flag.Var(&flatten, "flatten", "put sorted files directly in their bucket folder instead of recreating their folders below it (default true for -sort-into, false for -sort-by-date)")
+	pruneEmpty := flag.Bool("prune-empty", false, "with -move, remove the source folders the move left empty, never the scan roots themselves")
+	keepPairs := flag.Bool("keep-pairs", false, "with -sort-by-date or -consolidate, keep files differing only in extension in the same folder, like IMG_100.CR2 and IMG_100.JPG, together under the same name (-sort-into splits them into extension folders regardless)")
+	groupLive := flag.Bool("group-live", false, "report the iPhone Live Photos and bursts among JPEG and HEIC images, from their Apple metadata, and keep the stills of each in one folder with -sort-into, -sort-by-date or -consolidate")
+	dryRun := flag.Bool("dry-run", false, "log what would be done without touching the filesystem")
+	workers := flag.Int("workers", runtime.NumCPU(), "number of goroutines processing image files")
+	findDupes := flag.Bool("find-dupes", false, "report groups of images with identical content")
+	screenshots := flag.Bool("screenshots", false, "report images that look like screenshots: a screen resolution, a screenshot tool's file name, PNG, no camera tags")
+	screenshotThreshold := flag.Int("screenshot-threshold", 3, "with -screenshots, flag images whose signals score at least this; a resolution or name match scores 2, PNG and missing camera tags 1 each")
+	emptyDirs := flag.Bool("empty-dirs", false, "report directories with no images anywhere below them, telling empty ones apart from those holding only other files")
+	nameDupes := flag.Bool("name-dupes", false, "report image file names, ignoring case, that appear in more than one directory; a cheap first pass before -find-dupes")
+	deleteDupes := flag.Bool("delete-dupes", false, "delete all but the oldest file of each duplicate group (honors -dry-run)")
+	templateText := flag.String("template", "", "print each image with this Go text/template, e.g. '{{.Path}}\\t{{.Size}}' (fields Path, Size, Ext, Dir, ModTime, Width, Height, Date, Taken; \\t and \\n are unescaped)")
+	dims := flag.Bool("dims", false, "include pixel dimensions (WxH) in the per-file output")
+	minWidth := flag.Int("min-width", 0, "skip images narrower than this many pixels, like thumbnails; reads each image's header")
+	minHeight := flag.Int("min-height", 0, "skip images less tall than this many pixels; reads each image's header")
+	keepUndecodable := flag.Bool("keep-undecodable", true, "with -min-width or -min-height, count the images whose dimensions can't be read; set to false to skip them")
+	minSizeFlag := flag.String("min-size", "", "skip images smaller than this, e.g. 500KB (binary units: 1KB = 1024 bytes)")
+	maxSizeFlag := flag.String("max-size", "", "skip images larger than this, e.g. 20MB (binary units: 1KB = 1024 bytes)")
+	scanArchives := flag.Bool("scan-archives", false, "also count the images inside .zip files, by their uncompressed size; they are listed as archive.zip!entry but left out of hashing, verifying and sorting")
+	followSymlinks := flag.Bool("follow-symlinks", false, "follow symlinked directories and files, entering each real directory once")
+	showProgress := flag.Bool("progress", false, "periodically print scan progress to stderr (only when it is a terminal)")
+	eta := flag.Bool("eta", false, "count the images first, then show a progress bar with the time remaining on stderr (only when it is a terminal)")
+	ignoreList := flag.String("ignore", "", "comma-separated directory names to ignore in addition to the defaults")
+	ignoreOnly := flag.Bool("ignore-only", false, "ignore only the -ignore and "+ignoreFileName+" entries, not the defaults")
+	ignoreRulesFile := flag.String("ignore-file", "", "skip paths matching the gitignore-style patterns in this `file`, relative to each root (! re-includes, also directories ignored by default)")
+	maxDepth := flag.Int("max-depth", -1, "how many directory levels below the root to scan (0 = root only, -1 = unlimited)")
+	var quiet bool
+	flag.BoolVar(&quiet, "quiet", false, "only print the summary, not every image file")
+	flag.BoolVar(&quiet, "q", false, "shorthand for -quiet")
+	verify := flag.Bool("verify", false, "fully decode each image and report corrupt or truncated files")
+	findSimilarFlag := flag.Bool("find-similar", false, "report groups of visually similar images by perceptual hash (JPEG, PNG, GIF)")
+	threshold := flag.Int("threshold", 5, "with -find-similar, the most bits in which two image hashes may differ")
+	needsRotation := flag.Bool("needs-rotation", false, "list JPEG and HEIC images whose EXIF orientation makes viewers display them rotated or mirrored")
+	verifyType := flag.Bool("verify-type", false, "read the first bytes of each image and report files whose content doesn't match their extension")
+	dbFile := flag.String("db", "", "record path, size, modtime and hash of each image in this SQLite `file`, re-hashing only changed files")
+	reindex := flag.Bool("reindex", false, "with -db, rebuild the index from scratch")
+	skipHidden := flag.Bool("skip-hidden", false, "skip files and directories whose name starts with a dot, like ._IMG_1.jpg or .thumbnails")
+	largestCount := flag.Int("largest", 0, "also list the N largest images")
+	tree := flag.Bool("tree", false, "print a tree of directories with the total image size below each")
+	manifestFile := flag.String("manifest", "", "write the SHA-256 of every image to this `file` in sha256sum format, with paths relative to the root if there is one")
+	compareDir := flag.String("compare", "", "also scan the `directory` given, like a backup of the one scanned, and report the images found in only one of them or in both; takes a single root")
+	compareBy := flag.String("compare-by", "path", "how -compare matches images: path, by their path relative to each root, or hash, by content wherever they are")
+	verifyManifestFile := flag.String("verify-manifest", "", "re-hash the images listed in this -manifest `file` and report changed, missing and new ones")
+	csvFile := flag.String("csv", "", "also write one row per image to this CSV `file`")
+	newerThan := flag.String("newer-than", "", "only include images modified after this RFC3339 time or within this duration, e.g. 7d (uses filesystem modtime, not EXIF)")
+	sinceLastRun := flag.String("since-last-run", "", "only include images modified since the last successful run that named this state `file`, and record this run's start in it when it succeeds; a missing file takes in every image")
+	olderThan := flag.String("older-than", "", "only include images modified before this RFC3339 time or longer ago than this duration, e.g. 30d (uses filesystem modtime, not EXIF)")
+	fromStdin := flag.Bool("from-stdin", false, "also count the image paths read one per line from stdin; directories become optional")
+	outFile := flag.String("out", "", "write the report to this `file` instead of stdout")
+	cacheFile := flag.String("cache", "", "remember image sizes and modtimes in this `file` and skip re-stat'ing images in unchanged directories (an image rewritten in place keeps its cached size, so not with modes that delete, move or compare files)")
+	rawBytes := flag.Bool("bytes", false, "print sizes as exact byte counts instead of human-readable units")
+	countOnly := flag.Bool("count-only", false, "only count the images per directory from the directory listings, without reading any file's size; much faster on slow mounts, but sizes are reported as 0")
+	stream := flag.Bool("stream", false, "print each image as soon as it is found and keep only the totals, so memory use stays flat on huge trees; drops the per-directory rankings unless -top is set, and everything needing the full file list")
+	noSep := flag.Bool("no-sep", false, "print counts and byte sizes without thousands separators")
+	relative := flag.Bool("relative", false, "print paths in the text report relative to their scan root, prefixed with the root's number when there are several")
+	print0 := flag.Bool("print0", false, "with -list-dirs, end each directory with a NUL byte instead of a newline and print it exactly as named on disk, for xargs -0")
+	includeVideo := flag.Bool("include-video", false, "also count video files (mp4, mov, avi, m4v, mkv, 3gp, mts), in their own Video category")
+	extList := flag.String("ext", "", "comma-separated extensions to scan for, e.g. heic,dng (default all known image types)")
+	var includeGlobs, excludeGlobs stringList
+	flag.Var(&includeGlobs, "include-glob", "only count images whose path matches this glob, e.g. '*/DCIM/*' (repeatable; ** matches any number of directories)")
+	var skipDirGlobs stringList
+	flag.Var(&skipDirGlobs, "skip-dir-glob", "skip directories whose name matches this filepath.Match pattern, with everything below them, e.g. '*.app' or '*cache*' (repeatable)")
+	flag.Var(&excludeGlobs, "exclude-glob", "skip images whose path matches this glob, e.g. '*thumbnail*' (repeatable)")
+	excludeExtList := flag.String("exclude-ext", "", "comma-separated extensions to leave out of the -ext set, e.g. svg,gif")
+	ioRetries := flag.Int("io-retries", 3, "retry stat'ing and opening a file this many times when it fails with a transient error, like a timeout on a network mount")
+	ioBackoff := flag.Duration("io-backoff", 100*time.Millisecond, "wait this long before the first -io-retries retry, doubling the wait for each further one")
+	sortBy := flag.String("sort-by", "", "list the images ordered by path, size, date (modification time) or name once all roots are scanned, instead of as each root is done; the list is held until then")
+	reverse := flag.Bool("reverse", false, "with -sort-by, list the largest, newest or last in the alphabet first")
+	includeZeroByte := flag.Bool("include-zero-byte", false, "count zero-byte images like any other instead of listing them separately as likely failed downloads")
+	sample := flag.Int("sample", 0, "stop scanning each root after finding this many images, for a quick preview of a huge tree")
+	checkpointFile := flag.String("checkpoint", "", "record progress in this `file` so an interrupted scan resumes where it stopped when run again with the same flags; removed once the scan completes")
+	printSchemaFlag := flag.Bool("print-schema", false, "print the JSON Schema of the -format json result and exit")
+	colorMode := flag.String("color", "auto", "color the text report and the diagnostics: auto (only on a terminal, and unless NO_COLOR is set), always or never")
+	cpuProfile := flag.String("cpuprofile", "", "write a CPU profile of the run to this `file`, for go tool pprof")
+	memProfile := flag.String("memprofile", "", "write a heap profile to this `file` when the run ends, for go tool pprof")
+	logLevel := flag.String("log-level", "info", "write diagnostics at this level or above to stderr: debug, info, warn or error")
+	configFile := flag.String("config", "", "read extra extensions, categories, ignored directories and default flag values from this JSON `file`")
+	flag.Parse()
+	if err := applyEnv(flag.CommandLine); err != nil {
+		fmt.Fprintln(os.Stderr, "Error reading environment:", err)
+		return exitUsage
+	}
+	if *configFile != "" {
+		if err := loadConfig(*configFile, flag.CommandLine); err != nil {
+			fmt.Fprintln(os.Stderr, "Error reading config:", err)
+			return exitUsage
+		}
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(*logLevel)); err != nil {
+		fmt.Fprintf(os.Stderr, "Unknown log level %q: must be debug, info, warn or error\n", *logLevel)
+		return exitUsage
+	}
+	colorStderr, colorErr := colorEnabled(*colorMode, os.Stderr)
+	if colorErr != nil {
+		fmt.Fprintln(os.Stderr, "Invalid -color:", colorErr)
+		return exitUsage
+	}
+	logger = newLogger(level, colorStderr)
+	// The report is only colored on the terminal, never in a -out file or another format
+	useColor, _ = colorEnabled(*colorMode, os.Stdout)
+	useColor = useColor && *format == "text" && *outFile == ""
+	if *printSchemaFlag {
+		if err := printSchema(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, "Error encoding JSON:", err)
+			return exitError
+		}
+		return exitOK
+	}
+	rawSizes = *rawBytes
+	noSeparators = *noSep
+
+	if *format != "text" && *format != "json" && *format != "jsonl" && *format != "markdown" && *format != "html" {
+		fmt.Fprintf(os.Stderr, "Unknown format %q: must be text, json, jsonl, markdown or html\n", *format)
+		return exitUsage
+	}
+	if *listDirs && (*format != "text" || *byDate || *byGeo) {
+		fmt.Fprintln(os.Stderr, "-list-dirs can't be combined with -format json, jsonl, markdown or html, nor with -by-date or -by-geo")
+		return exitUsage
+	}
+	if *compareBy != "path" && *compareBy != "hash" {
+		fmt.Fprintf(os.Stderr, "Unknown -compare-by %q: must be path or hash\n", *compareBy)
+		return exitUsage
+	}
+	// The first sort would move the images away from under the next
+	sorts := 0
+	for _, dest := range []string{*sortInto, *sortByDate, *consolidate} {
+		if dest != "" {
+			sorts++
+		}
+	}
+	if *move && sorts > 1 {
+		fmt.Fprintln(os.Stderr, "-move can only be combined with one of -sort-into, -sort-by-date and -consolidate")
+		return exitUsage
+	}
+	if *print0 && !*listDirs {
+		fmt.Fprintln(os.Stderr, "-print0 only applies to -list-dirs")
+		return exitUsage
+	}
+	if *byDate && *byGeo {
+		fmt.Fprintln(os.Stderr, "-by-date and -by-geo can't be combined")
+		return exitUsage
+	}
+	if *sortBy != "" {
+		if err := checkSortKey(*sortBy); err != nil {
+			fmt.Fprintln(os.Stderr, "Invalid -sort-by:", err)
+			return exitUsage
+		}
+	} else if *reverse {
+		fmt.Fprintln(os.Stderr, "-reverse needs -sort-by")
+		return exitUsage
+	}
+	// Renaming runs after the sorts and dupe deletion, so their moves would leave it stale paths
+	if *rename && (*move || *deleteDupes) {
+		fmt.Fprintln(os.Stderr, "-rename can't be combined with -move or -delete-dupes")
+		return exitUsage
+	}
+	if *pruneEmpty && !*move {
+		fmt.Fprintln(os.Stderr, "-prune-empty needs -move")
+		return exitUsage
+	}
+	// Images found before a resume were printed by the earlier run
+	if *checkpointFile != "" && (*stream || *format == "jsonl") {
+		fmt.Fprintln(os.Stderr, "-checkpoint can't be combined with -stream or -format jsonl, which print images as they are found")
+		return exitUsage
+	}
+	// A checkpointed scan walks each root in parts, which would each get the whole sample
+	if *checkpointFile != "" && *sample > 0 {
+		fmt.Fprintln(os.Stderr, "-checkpoint can't be combined with -sample")
+		return exitUsage
+	}
+
+	// -stream keeps neither the file list nor the per-directory counts these work from, and
+	// -count-only doesn't even stat the images
+	type flagUse struct {
+		name string
+		set  bool
+	}
+	needFiles := []flagUse{
+		{"by-date", *byDate}, {"by-geo", *byGeo}, {"tree", *tree}, {"csv", *csvFile != ""}, {"manifest", *manifestFile != ""}, {"verify-manifest", *verifyManifestFile != ""}, {"compare", *compareDir != ""}, {"db", *dbFile != ""},
+		{"verify", *verify}, {"verify-type", *verifyType}, {"needs-rotation", *needsRotation}, {"find-dupes", *findDupes}, {"name-dupes", *nameDupes}, {"empty-dirs", *emptyDirs}, {"delete-dupes", *deleteDupes},
+		{"find-similar", *findSimilarFlag}, {"sort-into", *sortInto != ""}, {"sort-by-date", *sortByDate != ""}, {"consolidate", *consolidate != ""}, {"sort-by", *sortBy != ""},
+		{"rename", *rename}, {"screenshots", *screenshots}, {"group-live", *groupLive},
+	}
+	needStats := []flagUse{
+		{"stream", *stream}, {"largest", *largestCount > 0}, {"min-size", *minSizeFlag != ""}, {"max-size", *maxSizeFlag != ""},
+		{"newer-than", *newerThan != ""}, {"since-last-run", *sinceLastRun != ""}, {"older-than", *olderThan != ""}, {"min-width", *minWidth > 0}, {"min-height", *minHeight > 0},
+	}
+	// The stat cache is only checked against each directory's modification time, so these,
+	// which delete, move or match files by size, always stat them
+	needFresh := []flagUse{
+		{"find-dupes", *findDupes}, {"delete-dupes", *deleteDupes}, {"compare", *compareDir != ""}, {"sort-into", *sortInto != ""},
+		{"sort-by-date", *sortByDate != ""}, {"consolidate", *consolidate != ""}, {"rename", *rename},
+	}
+	modes := []struct {
+		name   string
+		set    bool
+		needs  []flagUse
+		reason string
+	}{
+		{"-stream", *stream, append(slices.Clone(needFiles), flagUse{"list-dirs", *listDirs}), "the full file list"},
+		{"-count-only", *countOnly, slices.Concat(needFiles, needStats), "the file list or the file sizes and modification times"},
+		{"-cache", *cacheFile != "", needFresh, "up-to-date file sizes, which -cache doesn't guarantee"},
+		{"-sample", *sample > 0, needFiles, "the complete file list"},
+	}
+	for _, mode := range modes {
+		for _, f := range mode.needs {
+			if mode.set && f.set {
+				fmt.Fprintf(os.Stderr, "%s can't be combined with -%s, which needs %s\n", mode.name, f.name, mode.reason)
+				return exitUsage
+			}
+		}
+	}
+
+	// Get the directories to scan from the command line, or else the environment. Paths read
+	// from stdin make them optional, and then only the command line counts.
+	args := flag.Args()
+	if !*fromStdin {
+		args = rootArgs(args)
+	}
+	if len(args) < 1 && !*fromStdin {
+		fmt.Fprintf(os.Stderr, "No directory to scan: give one on the command line or set %s\n", rootEnv)
+		fmt.Fprintln(os.Stderr, "Usage: go run . [flags] <directory> [directory...]")
+		flag.PrintDefaults()
+		return exitUsage
+	}
+
+	// Use the provided directories, made absolute and clean so the same directory always
+	// gets the same key whether it was given as photos, ./photos/ or ../x/photos. A directory
+	// given twice is scanned once, and one inside another is refused, as its images would be
+	// counted twice and -delete-dupes would see each as a copy of itself.
+	roots := make([]string, 0, len(args))
+	resolvedRoots := make([]string, 0, len(args))
+	for _, arg := range args {
+		root, err := filepath.Abs(arg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error resolving directory:", err)
+			return exitError
+		}
+		info, err := os.Stat(root)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error scanning directory:", err)
+			return exitError
+		}
+		if !info.IsDir() {
+			fmt.Fprintf(os.Stderr, "Not a directory: %s\n", arg)
+			return exitUsage
+		}
+		resolved, err := resolvePath(root)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error resolving directory:", err)
+			return exitError
+		}
+		if slices.Contains(resolvedRoots, resolved) {
+			logger.Warn("directory given more than once, scanning it once", "path", arg)
+			continue
+		}
+		for i, other := range resolvedRoots {
+			if isWithin(resolved, other) || isWithin(other, resolved) {
+				fmt.Fprintf(os.Stderr, "Can't scan both %s and %s: one is inside the other\n", roots[i], root)
+				return exitUsage
+			}
+		}
+		roots = append(roots, root)
+		resolvedRoots = append(resolvedRoots, resolved)
+	}
+	compareRoot := ""
+	if *compareDir != "" {
+		if len(roots) != 1 || *fromStdin {
+			fmt.Fprintln(os.Stderr, "-compare takes a single directory to scan, and can't be combined with -from-stdin")
+			return exitUsage
+		}
+		var err error
+		if compareRoot, err = filepath.Abs(*compareDir); err != nil {
+			fmt.Fprintln(os.Stderr, "Error resolving directory:", err)
+			return exitError
+		}
+		if info, err := os.Stat(compareRoot); err != nil {
+			fmt.Fprintln(os.Stderr, "Error scanning directory:", err)
+			return exitError
+		} else if !info.IsDir() {
+			fmt.Fprintf(os.Stderr, "Not a directory: %s\n", *compareDir)
+			return exitUsage
+		}
+		// Images in a tree nested in the other would be counted on both sides
+		resolved, err := resolvePath(compareRoot)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error resolving directory:", err)
+			return exitError
+		}
+		if isWithin(resolved, resolvedRoots[0]) || isWithin(resolvedRoots[0], resolved) {
+			fmt.Fprintf(os.Stderr, "Can't compare %s with %s: one is inside the other\n", roots[0], compareRoot)
+			return exitUsage
+		}
+	}
+	// Refuse to copy or move images into the tree they came from before anything is scanned
+	for _, dest := range []string{*sortInto, *sortByDate, *consolidate} {
+		if dest == "" {
+			continue
+		}
+		if err := checkDest(dest, roots); err != nil {
+			fmt.Fprintf(os.Stderr, "Can't sort into %s: %v\n", dest, err)
+			return exitUsage
+		}
+	}
+	if *relative {
+		relativeRoots = roots
+	}
+	sources := roots
+	if *fromStdin {
+		sources = append([]string{stdinSource}, roots...)
+	}
+
+	// The report goes to stdout or the -out file; a write error there surfaces when the
+	// buffered file is flushed at the end
+	var report io.Writer = os.Stdout
+	var buffered *bufio.Writer
+	if *outFile != "" {
+		f, err := os.Create(*outFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error creating report file:", err)
+			return exitError
+		}
+		buffered = bufio.NewWriter(f)
+		report = buffered
+		defer func() {
+			err := buffered.Flush()
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Error writing report file:", err)
+				code = exitError
+			}
+		}()
+	} else if *format == "jsonl" {
+		// Records are written one at a time, so batch them up rather than make a write per image
+		buffered = bufio.NewWriter(os.Stdout)
+		report = buffered
+		defer func() {
+			if err := buffered.Flush(); err != nil {
+				fmt.Fprintln(os.Stderr, "Error writing report:", err)
+				code = exitError
+			}
+		}()
+	}
+
+	// With -list-dirs the report is reserved for the directory list and the rest of it is
+	// dropped
+	dirList := report
+	if *listDirs {
+		report = io.Discard
+		quiet = true
+	}
+
+	// In JSON and Markdown mode the report is reserved for the result, so the progress goes
+	// to stderr
+	out := report
+	if *format != "text" || *listDirs {
+		out = os.Stderr
+	}
+
+	opts := scanner.Options{
+		Extensions:     selectExtensions(*extList, *excludeExtList, *includeVideo),
+		MinCount:       *minCount,
+		GroupBy:        filepath.Dir,
+		Workers:        *workers,
+		FollowSymlinks: *followSymlinks,
+		MaxDepth:       *maxDepth,
+		SkipHidden:     *skipHidden,
+		TotalsOnly:     *stream,
+		TopDirs:        *top,
+		ScanArchives:   *scanArchives,
+		RecordDirs:     *emptyDirs,
+		Limit:          *sample,
+		SkipZeroByte:   !*includeZeroByte,
+		MinWidth:       *minWidth,
+		MinHeight:      *minHeight,
+		SkipUnsized:    !*keepUndecodable,
+		IORetries:      *ioRetries,
+		IOBackoff:      *ioBackoff,
+		IncludeGlobs:   includeGlobs,
+		ExcludeGlobs:   excludeGlobs,
+		SkipDirGlobs:   skipDirGlobs,
+		OnSkip: func(path string, err error) {
+			logger.Warn("skipping unreadable path", "path", path, "err", err)
+		},
+		OnRetry: func(path string, attempt int, err error) {
+			logger.Debug("retrying after transient error", "path", path, "attempt", attempt, "err", err)
+		},
+		OnIgnore: func(path string) {
+			logger.Debug("skipping ignored directory", "path", path)
+		},
+	}
+	for _, pattern := range append(includeGlobs, excludeGlobs...) {
+		if err := scanner.CheckGlob(pattern); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid glob %q: %v\n", pattern, err)
+			return exitUsage
+		}
+	}
+	for _, pattern := range skipDirGlobs {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid -skip-dir-glob %q: %v\n", pattern, err)
+			return exitUsage
+		}
+	}
+	var err error
+	if *minSizeFlag != "" {
+		if opts.MinSize, err = parseSize(*minSizeFlag); err != nil {
+			fmt.Fprintln(os.Stderr, "Invalid -min-size:", err)
+			return exitUsage
+		}
+	}
+	if *maxSizeFlag != "" {
+		if opts.MaxSize, err = parseSize(*maxSizeFlag); err != nil {
+			fmt.Fprintln(os.Stderr, "Invalid -max-size:", err)
+			return exitUsage
+		}
+	}
+	now := time.Now()
+	if *newerThan != "" {
+		if opts.NewerThan, err = parseTimeBound(*newerThan, now); err != nil {
+			fmt.Fprintln(os.Stderr, "Invalid -newer-than:", err)
+			return exitUsage
+		}
+	}
+	if *sinceLastRun != "" {
+		if *newerThan != "" {
+			fmt.Fprintln(os.Stderr, "-since-last-run can't be combined with -newer-than")
+			return exitUsage
+		}
+		if opts.NewerThan, err = readLastRun(*sinceLastRun); err != nil {
+			fmt.Fprintln(os.Stderr, "Error reading -since-last-run state:", err)
+			return exitError
+		}
+		// The next run starts from when this one did, so images added while it runs
+		// aren't missed; a dry run leaves the state alone
+		if !*dryRun {
+			defer func() {
+				if code != exitOK {
+					return
+				}
+				if err := writeLastRun(*sinceLastRun, now); err != nil {
+					fmt.Fprintln(os.Stderr, "Error writing -since-last-run state:", err)
+					code = exitError
+				}
+			}()
+		}
+	}
+	if *olderThan != "" {
+		if opts.OlderThan, err = parseTimeBound(*olderThan, now); err != nil {
+			fmt.Fprintln(os.Stderr, "Invalid -older-than:", err)
+			return exitUsage
+		}
+	}
+	if *byDate {
+		opts.GroupBy = captureMonth
+	}
+	if *byGeo {
+		opts.GroupBy = geoCell
+	}
+
+	if *ignoreRulesFile != "" {
+		f, err := os.Open(*ignoreRulesFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error reading ignore file:", err)
+			return exitError
+		}
+		opts.IgnoreRules, err = scanner.ParseIgnoreRules(f)
+		f.Close()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error reading ignore file:", err)
+			return exitError
+		}
+	}
+
+	var fileTemplate *template.Template
+	if *templateText != "" {
+		if fileTemplate, err = parseFileTemplate("-template", *templateText); err != nil {
+			fmt.Fprintln(os.Stderr, "Invalid -template:", err)
+			return exitUsage
+		}
+	}
+	var renameTemplate *template.Template
+	if *rename {
+		if renameTemplate, err = parseFileTemplate("-rename-pattern", *renamePattern); err != nil {
+			fmt.Fprintln(os.Stderr, "Invalid -rename-pattern:", err)
+			return exitUsage
+		}
+	}
+
+	// Fed as the scan counts each image, so no extra pass or sort over every file is needed
+	largest := &largestFiles{n: *largestCount}
+	opts.OnImage = largest.add
+
+	if *cacheFile != "" {
+		if opts.Cache, err = scanner.LoadStatCache(*cacheFile); err != nil {
+			fmt.Fprintln(os.Stderr, "Error reading stat cache:", err)
+			return exitError
+		}
+	}
+
+	stopProfiles, err := startProfiles(*cpuProfile, *memProfile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error starting CPU profile:", err)
+		return exitError
+	}
+	defer stopProfiles()
+
+	// Ctrl-C stops the scan, keeping what was found so far for a partial summary
+	ctx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stopSignals()
+
+	// Progress lines would only clutter a log file or a JSON consumer
+	stopProgress := func() {}
+	if (*showProgress || *eta) && *format == "text" && isTerminal(os.Stderr) {
+		progress := &scanProgress{}
+		if *eta {
+			// A walk without stats is cheap next to the scan, but not free on slow mounts
+			fmt.Fprint(os.Stderr, "Counting image files...")
+			countOpts := opts
+			countOpts.OnSkip, countOpts.OnIgnore = nil, nil // the scan reports these
+			for _, root := range roots {
+				if countOpts.IgnoreDirs, err = selectIgnoreDirs(root, *ignoreList, *ignoreOnly); err != nil {
+					continue // the scan reports this too
+				}
+				count, _ := scanner.Count(ctx, root, countOpts)
+				progress.total += int64(count)
+			}
+			fmt.Fprint(os.Stderr, "\r\033[K")
+		}
+		opts.OnImage = func(file ImageFile) {
+			progress.add(file.Size)
+			largest.add(file)
+		}
+		stopProgress = progress.start(os.Stderr, time.Second)
+	}
+
+	// With -format jsonl each image is written out as soon as the scan counts it, through
+	// the same buffered writer the summary goes to
+	var records *jsonlWriter
+	if *format == "jsonl" {
+		records = &jsonlWriter{w: buffered, gps: *byGeo, live: *groupLive}
+		onImage := opts.OnImage
+		opts.OnImage = func(file ImageFile) {
+			onImage(file)
+			records.image(file)
+		}
+	}
+	if *stream && !quiet {
+		onImage := opts.OnImage
+		opts.OnImage = func(file ImageFile) {
+			onImage(file)
+			printFiles(out, []ImageFile{file}, *dims, *byGeo, fileTemplate)
+		}
+	}
+
+	// Scan each root in turn and merge the results; the per-directory counts are keyed
+	// on full paths, so counts from different roots never collide
+	var cp *checkpoint
+	if *checkpointFile != "" {
+		if cp, err = loadCheckpoint(*checkpointFile, roots); err != nil {
+			stopProgress()
+			fmt.Fprintln(os.Stderr, "Error reading checkpoint:", err)
+			return exitError
+		}
+		if done := cp.done(); done > 0 && !quiet {
+			fmt.Fprintf(out, "Resuming from %s, skipping %s directories scanned before\n", *checkpointFile, formatInt(int64(done)))
+		}
+	}
+	scanned := scanner.NewResult()
+	var rootTotals []RootTotal
+	interrupted := false
+	for _, root := range sources {
+		var rootSummary *scanner.Result
+		if root == stdinSource {
+			if !quiet {
+				fmt.Fprintln(out, "Reading image paths from stdin")
+			}
+			rootSummary, err = scanner.ScanPaths(ctx, os.Stdin, opts)
+		} else {
+			if opts.IgnoreDirs, err = selectIgnoreDirs(root, *ignoreList, *ignoreOnly); err != nil {
+				stopProgress()
+				fmt.Fprintln(os.Stderr, "Error reading ignore file:", err)
+				return exitError
+			}
+
+			if !quiet {
+				fmt.Fprintln(out, "Scanning for image files in:", root)
+			}
+			scan := scanner.ScanContext
+			if *countOnly {
+				scan = scanner.CountByDir
+			}
+			if cp != nil {
+				rootSummary, err = scanParts(ctx, root, opts, cp, scan)
+			} else {
+				rootSummary, err = scan(ctx, root, opts)
+			}
+		}
+		if errors.Is(err, context.Canceled) {
+			interrupted = true
+		} else if err != nil {
+			stopProgress()
+			fmt.Fprintln(os.Stderr, "Error scanning directory:", err)
+			return exitError
+		}
+		if !quiet && *sortBy == "" {
+			printFiles(out, rootSummary.Files, *dims, *byGeo, fileTemplate)
+		}
+
+		scanned.Merge(rootSummary)
+		rootTotals = append(rootTotals, RootTotal{Root: root, TotalSize: rootSummary.TotalSize, Images: rootSummary.TotalImages})
+		if interrupted {
+			break
+		}
+	}
+	stopProgress()
+	if !quiet && *sortBy != "" {
+		printFiles(out, sortedFiles(scanned.Files, *sortBy, *reverse), *dims, *byGeo, fileTemplate)
+	}
+	// From here on Ctrl-C kills the process as usual
+	stopSignals()
+
+	// A partial scan would drop the directories it didn't reach from the cache
+	if opts.Cache != nil && !interrupted {
+		if err := opts.Cache.Save(*cacheFile); err != nil {
+			fmt.Fprintln(os.Stderr, "Error saving stat cache:", err)
+			return exitError
+		}
+	}
+
+	status := exitOK
+	if interrupted {
+		// Sorting, deleting or indexing only part of the tree would be surprising, so
+		// everything but the summary is skipped
+		fmt.Fprintln(os.Stderr, "\nScan interrupted, printing a partial summary")
+		if cp != nil {
+			fmt.Fprintf(os.Stderr, "Run again with -checkpoint %s to resume the scan\n", *checkpointFile)
+		}
+		status = exitInterrupted
+	} else if cp != nil {
+		if err := os.Remove(*checkpointFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+			logger.Error("removing checkpoint failed", "path", *checkpointFile, "err", err)
+		}
+	}
+	if scanned.Truncated {
+		fmt.Fprintf(os.Stderr, "Stopped after the first %s images found below each root, so the totals are only a sample\n", formatInt(int64(*sample)))
+	}
+	totalSize, totalCount, dirFileCount, files := scanned.TotalSize, scanned.TotalImages, scanned.DirFileCount, scanned.Files
+
+	// Images inside archives are counted, but can't be opened, copied or deleted like files
+	diskFiles := files
+	if *scanArchives {
+		diskFiles = nil
+		for _, file := range files {
+			if file.Archive == "" {
+				diskFiles = append(diskFiles, file)
+			}
+		}
+	}
+	if len(scanned.Skipped) > 0 {
+		logger.Warn("skipped paths due to permission errors", "count", len(scanned.Skipped))
+	}
+	invalidNames := findInvalidNames(files)
+	if len(invalidNames) > 0 {
+		logger.Warn("image paths aren't valid UTF-8 and are shown with \\xNN escapes", "count", len(invalidNames))
+	}
+	categories := scanner.SortCategories(scanned.Categories)
+	extensions := scanner.SortExtensions(scanned.Extensions)
+
+	// Subtotals only add information when there is more than one root
+	if len(rootTotals) < 2 {
+		rootTotals = nil
+	}
+
+	if *csvFile != "" && !interrupted {
+		if err := writeCSV(*csvFile, files); err != nil {
+			fmt.Fprintln(os.Stderr, "Error writing CSV:", err)
+			return exitError
+		}
+	}
+
+	if *dbFile != "" && !interrupted {
+		summary, err := updateIndex(*dbFile, roots, diskFiles, *reindex)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error updating index:", err)
+			return exitError
+		}
+		fmt.Fprintf(out, "\nIndex %s: %d images, %s (%d hashed, %d unchanged, %d removed, %d failed)\n",
+			*dbFile, summary.Images, sizeText(summary.Size), summary.Hashed, summary.Unchanged, summary.Removed, summary.Failed)
+	}
+
+	// Manifest paths are relative to the root when there is a single one, so the tree can
+	// be checked again after it is copied elsewhere
+	manifestBase := ""
+	if len(roots) == 1 {
+		manifestBase = roots[0]
+	}
+	if *manifestFile != "" && !interrupted {
+		written, err := writeManifest(*manifestFile, manifestBase, diskFiles)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error writing manifest:", err)
+			return exitError
+		}
+		fmt.Fprintf(out, "\nWrote the SHA-256 of %d images to %s\n", written, *manifestFile)
+		if skipped := len(diskFiles) - written; skipped > 0 {
+			fmt.Fprintf(out, "Left out %d images that couldn't be read\n", skipped)
+		}
+	}
+
+	var compared *CompareResult
+	if compareRoot != "" && !interrupted {
+		compareOpts := opts
+		var err error
+		if compareOpts.IgnoreDirs, err = selectIgnoreDirs(compareRoot, *ignoreList, *ignoreOnly); err != nil {
+			fmt.Fprintln(os.Stderr, "Error reading ignore file:", err)
+			return exitError
+		}
+		if !quiet {
+			fmt.Fprintln(out, "\nScanning for image files in:", compareRoot)
+		}
+		result, err := compareTrees(context.Background(), roots[0], diskFiles, compareRoot, compareOpts, *compareBy == "hash", *workers)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error comparing directories:", err)
+			return exitError
+		}
+		compared = &result
+		if *format == "text" {
+			printCompare(report, roots[0], result)
+		}
+	}
+
+	var manifestCheck *ManifestCheck
+	if *verifyManifestFile != "" && !interrupted {
+		check, err := verifyManifest(*verifyManifestFile, manifestBase, diskFiles)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error verifying manifest:", err)
+			return exitError
+		}
+		manifestCheck = &check
+		if *format == "text" {
+			printManifestCheck(report, check)
+		}
+		// Fail like sha256sum -c does, so a backup script can stop before deleting anything
+		if len(check.Mismatched) > 0 || len(check.Missing) > 0 {
+			status = exitError
+		}
+	}
+
+	var corrupt []BadImage
+	var unverifiable []string
+	if *verify && !interrupted {
+		corrupt, unverifiable = verifyImages(diskFiles, *workers)
+		if *format == "text" {
+			printVerifyResults(report, corrupt, unverifiable)
+		}
+	}
+
+	var mismatches []TypeMismatch
+	if *verifyType && !interrupted {
+		mismatches = verifyTypes(diskFiles, *workers)
+		if *format == "text" {
+			printTypeMismatches(report, mismatches)
+		}
+	}
+
+	var rotated []RotatedImage
+	var noOrientation []string
+	if *needsRotation && !interrupted {
+		rotated, noOrientation = findRotated(diskFiles, *workers)
+		if *format == "text" {
+			printRotated(report, rotated, noOrientation)
+		}
+	}
+
+	var shots []Screenshot
+	if *screenshots && !interrupted {
+		shots = findScreenshots(diskFiles, *workers, *screenshotThreshold)
+		if *format == "text" {
+			printScreenshots(report, shots)
+		}
+	}
+
+	var liveGroups []LiveGroup
+	if *groupLive && !interrupted {
+		liveGroups = findLiveGroups(diskFiles, *workers)
+		if *format == "text" {
+			printLiveGroups(report, liveGroups)
+		}
+	}
+
+	var nameGroups []NameGroup
+	if *nameDupes && !interrupted {
+		nameGroups = findNameDuplicates(files)
+		if *format == "text" {
+			printNameDuplicates(report, nameGroups)
+		}
+	}
+
+	if len(scanned.ZeroByte) > 0 && *format == "text" {
+		printZeroByte(report, scanned.ZeroByte)
+	}
+
+	var emptyDirList []EmptyDir
+	if *emptyDirs && !interrupted {
+		emptyDirList = findEmptyDirs(scanned.DirEntries, files)
+		if *format == "text" {
+			printEmptyDirs(report, emptyDirList)
+		}
+	}
+
+	var duplicates []DuplicateGroup
+	if (*findDupes || *deleteDupes) && !interrupted {
+		duplicates = findDuplicates(diskFiles, *workers)
+		if *format == "text" {
+			printDuplicates(report, duplicates)
+		}
+		if *deleteDupes {
+			proceed := true
+			if !*dryRun {
+				removable, freeable := 0, int64(0)
+				for _, group := range duplicates {
+					removable += len(group.Files) - 1
+					freeable += group.Wasted
+				}
+				if removable > 0 {
+					err := confirm(fmt.Sprintf("About to delete %s duplicate files, freeing %s", formatInt(int64(removable)), sizeText(freeable)), *assumeYes)
+					if errors.Is(err, errDeclined) {
+						fmt.Fprintln(os.Stderr, "Not deleting duplicates")
+						proceed = false
+					} else if err != nil {
+						fmt.Fprintln(os.Stderr, "Error deleting duplicates:", err)
+						return exitError
+					}
+				}
+			}
+			if proceed {
+				deleted, freed := deleteDuplicates(duplicates, *dryRun, out)
+				if *dryRun {
+					fmt.Fprintf(out, "Would remove %d duplicate files, freeing %s\n", len(deleted), sizeText(freed))
+				} else {
+					fmt.Fprintf(out, "Removed %d duplicate files, freeing %s\n", len(deleted), sizeText(freed))
+				}
+				// Leave the removed copies out of the sorts below, also in a dry run so
+				// its plan matches a real one
+				gone := make(map[string]bool, len(deleted))
+				for _, path := range deleted {
+					gone[path] = true
+				}
+				diskFiles = slices.DeleteFunc(slices.Clone(diskFiles), func(file ImageFile) bool { return gone[file.Path] })
+			}
+		}
+	}
+
+	var similar []SimilarGroup
+	if *findSimilarFlag && !interrupted {
+		similar = findSimilar(diskFiles, *workers, *threshold)
+		if *format == "text" {
+			printSimilar(report, similar)
+		}
+	}
+
+	// Asks before sorting into dest changes anything
+	confirmSort := func(dest string) func(plan sortPlan) error {
+		return func(plan sortPlan) error {
+			verb := "copy"
+			if *move {
+				verb = "move"
+			}
+			return confirm(fmt.Sprintf("About to %s %s files into %s; %s collisions will be renamed",
+				verb, formatInt(int64(plan.Files)), dest, formatInt(int64(plan.Renamed))), *assumeYes)
+		}
+	}
+
+	// The planned moves of each -dry-run sort, listed per folder in the text report
+	var previews []SortPreview
+	previewSort := func(dest string, summary SortSummary) {
+		if !*dryRun {
+			return
+		}
+		preview := newSortPreview(dest, *move, summary.Planned)
+		previews = append(previews, preview)
+		printSortPreview(out, preview)
+	}
+
+	if *sortInto != "" && !interrupted {
+		sortOpts := sortOptions{bucket: extensionBucket, move: *move, dryRun: *dryRun, confirm: confirmSort(*sortInto),
+			flatten: flatten.or(true), roots: roots, prune: *pruneEmpty, live: liveGroups}
+		summary, err := sortImages(diskFiles, *sortInto, sortOpts, out)
+		switch {
+		case errors.Is(err, errDeclined):
+			fmt.Fprintln(os.Stderr, "Not sorting into", *sortInto)
+		case err != nil:
+			fmt.Fprintln(os.Stderr, "Error sorting images:", err)
+			return exitError
+		default:
+			fmt.Fprintf(out, "\nSorted %d files into %s (%d skipped)\n", summary.Moved, *sortInto, summary.Skipped)
+			if summary.Moved == 0 && summary.Skipped > 0 {
+				status = exitError
+			}
+			printPruned(out, sortOpts, summary)
+			previewSort(*sortInto, summary)
+		}
+	}
+
+	if *sortByDate != "" && !interrupted {
+		sortOpts := sortOptions{bucket: dateBucket, move: *move, dryRun: *dryRun, skipIdentical: true, confirm: confirmSort(*sortByDate),
+			flatten: flatten.or(false), roots: roots, keepPairs: *keepPairs, prune: *pruneEmpty, live: liveGroups}
+		summary, err := sortImages(diskFiles, *sortByDate, sortOpts, out)
+		switch {
+		case errors.Is(err, errDeclined):
+			fmt.Fprintln(os.Stderr, "Not sorting into", *sortByDate)
+		case err != nil:
+			fmt.Fprintln(os.Stderr, "Error sorting images:", err)
+			return exitError
+		default:
+			fmt.Fprintf(out, "\nSorted %d files into %s (%d skipped, %d identical copies already present)\n",
+				summary.Moved, *sortByDate, summary.Skipped, summary.Identical)
+			if summary.Moved == 0 && summary.Skipped > 0 {
+				status = exitError
+			}
+			printYearCounts(out, summary.Buckets)
+			printPruned(out, sortOpts, summary)
+			previewSort(*sortByDate, summary)
+		}
+	}
+
+	if *consolidate != "" && !interrupted {
+		sortOpts := sortOptions{bucket: flatBucket, move: *move, dryRun: *dryRun, confirm: confirmSort(*consolidate),
+			flatten: true, roots: roots, nameByDir: true, keepPairs: *keepPairs, prune: *pruneEmpty, live: liveGroups}
+		summary, err := sortImages(diskFiles, *consolidate, sortOpts, out)
+		switch {
+		case errors.Is(err, errDeclined):
+			fmt.Fprintln(os.Stderr, "Not consolidating into", *consolidate)
+		case err != nil:
+			fmt.Fprintln(os.Stderr, "Error consolidating images:", err)
+			return exitError
+		default:
+			fmt.Fprintf(out, "\nConsolidated %d files into %s (%d renamed to avoid collisions, %d skipped)\n",
+				summary.Moved, *consolidate, summary.Renamed, summary.Skipped)
+			if summary.Moved == 0 && summary.Skipped > 0 {
+				status = exitError
+			}
+			printPruned(out, sortOpts, summary)
+			previewSort(*consolidate, summary)
+		}
+	}
+
+	if *rename && !interrupted {
+		confirmRename := func(count int) error {
+			return confirm(fmt.Sprintf("About to rename %s files", formatInt(int64(count))), *assumeYes)
+		}
+		summary, err := renameImages(diskFiles, renameTemplate, *dryRun, confirmRename, out)
+		switch {
+		case errors.Is(err, errDeclined):
+			fmt.Fprintln(os.Stderr, "Not renaming")
+		case err != nil:
+			fmt.Fprintln(os.Stderr, "Error renaming images:", err)
+			return exitError
+		default:
+			fmt.Fprintf(out, "\nRenamed %d files (%d already named, %d skipped)\n", summary.Renamed, summary.Unchanged, summary.Skipped)
+		}
+	}
+
+	// An empty report would only be a row of zeros and empty headers
+	if totalCount == 0 && *format == "text" {
+		scanned := make([]string, len(sources))
+		for i, source := range sources {
+			scanned[i] = source
+			if source == stdinSource {
+				scanned[i] = "the paths read from stdin"
+			}
+		}
+		fmt.Fprintf(report, "\nNo image files found under %s\n", strings.Join(scanned, ", "))
+		return status
+	}
+
+	// Writes the result for -format json, jsonl, markdown or html
+	emitResult := func(result ScanResult) int {
+		result.SchemaVersion = resultSchemaVersion
+		result.DurationMS = time.Since(started).Milliseconds()
+		if *format == "markdown" {
+			printMarkdown(report, result, *top)
+			return status
+		}
+		if *format == "html" {
+			if err := printHTML(report, result, *top); err != nil {
+				fmt.Fprintln(os.Stderr, "Error writing HTML:", err)
+				return exitError
+			}
+			return status
+		}
+		if records != nil {
+			if err := records.summary(result, interrupted); err != nil {
+				fmt.Fprintln(os.Stderr, "Error writing JSON Lines:", err)
+				return exitError
+			}
+			return status
+		}
+		if err := printJSON(report, result, *pretty); err != nil {
+			fmt.Fprintln(os.Stderr, "Error encoding JSON:", err)
+			return exitError
+		}
+		return status
+	}
+
+	// Summed up in plain language at the end of the text report
+	found := findings{
+		files:        diskFiles,
+		totalSize:    totalSize,
+		keepPairs:    *keepPairs,
+		duplicates:   duplicates,
+		dupesDeleted: *deleteDupes,
+		zeroByte:     scanned.ZeroByte,
+		largest:      largest.sorted(),
+		corrupt:      len(corrupt),
+		mismatches:   len(mismatches),
+		rotated:      len(rotated),
+		screenshots:  len(shots),
+		emptyDirs:    len(emptyDirList),
+		similar:      len(similar),
+	}
+
+	if *byDate || *byGeo {
+		var sortedMonths []MonthCount
+		var locations []LocationCount
+		var noGPS int
+		if *byDate {
+			sortedMonths = sortMonthCounts(dirFileCount)
+		} else {
+			locations, noGPS = sortLocationCounts(dirFileCount)
+		}
+		if *format != "text" {
+			result := ScanResult{
+				TotalSize:     totalSize,
+				TotalImages:   totalCount,
+				Roots:         rootTotals,
+				SkippedPaths:  scanned.Skipped,
+				InvalidNames:  invalidNames,
+				SortPreviews:  previews,
+				ZeroByte:      scanned.ZeroByte,
+				Sampled:       scanned.Truncated,
+				Categories:    categories,
+				Extensions:    extensions,
+				Months:        sortedMonths,
+				Locations:     locations,
+				NoGPS:         noGPS,
+				Duplicates:    duplicates,
+				NameDupes:     nameGroups,
+				EmptyDirs:     emptyDirList,
+				Corrupt:       corrupt,
+				Unverifiable:  unverifiable,
+				Mismatches:    mismatches,
+				Rotated:       rotated,
+				NoOrientation: noOrientation,
+				Screenshots:   shots,
+				LivePhotos:    liveGroups,
+				Similar:       similar,
+				Manifest:      manifestCheck,
+				Compare:       compared,
+				Largest:       largest.sorted(),
+			}
+			return emitResult(result)
+		}
+
+		printRootTotals(report, rootTotals)
+		fmt.Fprintf(report, "\nTotal Images: %s\n", formatInt(int64(totalCount)))
+		fmt.Fprintf(report, "Total Size: %s\n", sizeText(totalSize))
+		printCategoryTotals(report, categories)
+		if *byDate {
+			fmt.Fprintln(report, "\nImage files by capture month:")
+			for _, mc := range sortedMonths {
+				fmt.Fprintf(report, "Month: %s | Image Files: %s\n", mc.Month, formatInt(int64(mc.Count)))
+			}
+		} else {
+			printLocationCounts(report, locations, noGPS)
+		}
+		if !quiet {
+			printRecommendations(report, found)
+		}
+		printScanTime(report, totalCount, totalSize, time.Since(started))
+		return status
+	}
+
+	if *format != "text" {
+		sortedDirs := scanner.SortDirCounts(dirFileCount)
+		result := ScanResult{
+			TotalSize:     totalSize,
+			TotalImages:   totalCount,
+			Roots:         rootTotals,
+			SkippedPaths:  scanned.Skipped,
+			InvalidNames:  invalidNames,
+			SortPreviews:  previews,
+			ZeroByte:      scanned.ZeroByte,
+			Sampled:       scanned.Truncated,
+			Categories:    categories,
+			Extensions:    extensions,
+			Directories:   sortedDirs,
+			DirSizes:      scanner.SortDirSizes(scanned.DirFileSize),
+			AcceptedDirs:  dirPaths(scanner.AcceptDirs(sortedDirs, *minCount, *top)),
+			Duplicates:    duplicates,
+			NameDupes:     nameGroups,
+			EmptyDirs:     emptyDirList,
+			Corrupt:       corrupt,
+			Unverifiable:  unverifiable,
+			Mismatches:    mismatches,
+			Rotated:       rotated,
+			NoOrientation: noOrientation,
+			Screenshots:   shots,
+			LivePhotos:    liveGroups,
+			Similar:       similar,
+			Manifest:      manifestCheck,
+			Compare:       compared,
+			Largest:       largest.sorted(),
+		}
+		return emitResult(result)
+	}
+
+	// Without sizes only the counts have anything to show
+	if *countOnly {
+		fmt.Fprintf(report, "\nTotal Images: %s\n", formatInt(int64(totalCount)))
+		acceptedDirs := printDirectoryFileCounts(report, dirFileCount, *minCount, *top)
+		if *listDirs {
+			printDirList(dirList, acceptedDirs, *print0)
+		}
+		fmt.Fprintf(report, "\nCounted %s images in %s\n", formatInt(int64(totalCount)), time.Since(started).Round(time.Millisecond))
+		return status
 	}
 
 	// Print the total size summary
-	fmt.Printf("\nTotal Size: %d bytes\n", totalSize)
-	fmt.Printf("Total Size: %.2f KB\n", float64(totalSize)/1024)
-	fmt.Printf("Total Size: %.2f MB\n", float64(totalSize)/(1024*1024))
-	fmt.Printf("Total Size: %.2f GB\n", float64(totalSize)/(1024*1024*1024))
+	printRootTotals(report, rootTotals)
+	fmt.Fprintf(report, "\nTotal Images: %s\n", formatInt(int64(totalCount)))
+	fmt.Fprintf(report, "Total Size: %s\n", sizeText(totalSize))
+
+	// Print the breakdown by category
+	printCategoryTotals(report, categories)
+	printExtensionTotals(report, extensions)
 
 	// Print directories sorted by the number of image files
-	acceptedDirs := printDirectoryFileCounts(dirFileCount)
-	for _, dir := range(acceptedDirs) {
-		fmt.Printf("%s\n", dir)
-	} 
+	acceptedDirs := printDirectoryFileCounts(report, dirFileCount, *minCount, *top)
+	if *listDirs {
+		printDirList(dirList, acceptedDirs, *print0)
+	}
+
+	// Print directories sorted by the bytes their images take up; -stream only counts them
+	// for the -top ranking
+	if !*stream || *top > 0 {
+		printDirectorySizes(report, scanned.DirFileSize, *top)
+	}
+
+	if *largestCount > 0 {
+		printLargest(report, largest.sorted())
+	}
+
+	if *tree {
+		fmt.Fprintln(report, "\nImage size by directory:")
+		for _, root := range roots {
+			printDirTree(report, buildDirTree(filepath.Clean(root), scanned.DirFileSize), *maxDepth)
+		}
+	}
+	if !quiet {
+		printRecommendations(report, found)
+	}
+	printScanTime(report, totalCount, totalSize, time.Since(started))
+	return status
 }
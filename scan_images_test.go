@@ -0,0 +1,552 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/kwdowicz/image_sorter/scanner"
+)
+
+// writeFile creates the file at path, with its folders, holding content
+func writeFile(t testing.TB, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// scanFiles returns the images below root, in walk order
+func scanFiles(t testing.TB, root string) []ImageFile {
+	t.Helper()
+	result, err := scanner.Scan(root, scanner.Options{MaxDepth: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result.Files
+}
+
+// runCLI runs the program with args as its command line on fresh flags, returning what it
+// wrote to stdout and stderr and its exit code
+func runCLI(t *testing.T, args ...string) (stdout, stderr string, code int) {
+	t.Helper()
+	dir := t.TempDir()
+	outFile, err := os.Create(filepath.Join(dir, "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	errFile, err := os.Create(filepath.Join(dir, "stderr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	savedArgs, savedFlags, savedOut, savedErr := os.Args, flag.CommandLine, os.Stdout, os.Stderr
+	savedLogger, savedRoots := logger, relativeRoots
+	defer func() {
+		os.Args, flag.CommandLine, os.Stdout, os.Stderr = savedArgs, savedFlags, savedOut, savedErr
+		logger, relativeRoots = savedLogger, savedRoots
+	}()
+	os.Args = append([]string{"image_sorter"}, args...)
+	flag.CommandLine = flag.NewFlagSet("image_sorter", flag.ContinueOnError)
+	os.Stdout, os.Stderr = outFile, errFile
+	relativeRoots = nil
+
+	code = run()
+	outFile.Close()
+	errFile.Close()
+	out, _ := os.ReadFile(outFile.Name())
+	errOut, _ := os.ReadFile(errFile.Name())
+	return string(out), string(errOut), code
+}
+
+// runJSON runs the program with -format json added to args and decodes its result
+func runJSON(t *testing.T, args ...string) ScanResult {
+	t.Helper()
+	stdout, stderr, code := runCLI(t, append([]string{"-format", "json"}, args...)...)
+	if code != exitOK {
+		t.Fatalf("exit code %d, stderr:\n%s", code, stderr)
+	}
+	var result ScanResult
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("decoding %q: %v", stdout, err)
+	}
+	return result
+}
+
+func TestScan(t *testing.T) {
+	tests := []struct {
+		name      string
+		files     map[string]string
+		images    int
+		size      int64
+		dirCounts map[string]int // relative to the root
+	}{
+		{
+			name:      "empty directory",
+			dirCounts: map[string]int{},
+		},
+		{
+			name:      "no images",
+			files:     map[string]string{"notes.txt": "hello", "sub/data.csv": "1,2", "noext": "x"},
+			dirCounts: map[string]int{},
+		},
+		{
+			name:      "mixed-case extensions",
+			files:     map[string]string{"a.JPG": "12", "b.Png": "345", "sub/c.jpeg": "6", "sub/d.HeIc": "7890", "e.txt": "skip"},
+			images:    4,
+			size:      10,
+			dirCounts: map[string]int{".": 2, "sub": 2},
+		},
+		{
+			name:      "ignored directory",
+			files:     map[string]string{"a.jpg": "1", "Windows/b.jpg": "22", "Windows/deeper/c.jpg": "333", "photos/d.png": "4444"},
+			images:    2,
+			size:      5,
+			dirCounts: map[string]int{".": 1, "photos": 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			for name, content := range tt.files {
+				writeFile(t, filepath.Join(root, name), content)
+			}
+			result, err := scanner.Scan(root, scanner.Options{MaxDepth: -1, IgnoreDirs: scanner.DefaultIgnoreDirs})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.TotalImages != tt.images || result.TotalSize != tt.size {
+				t.Errorf("got %d images of %d bytes, want %d of %d", result.TotalImages, result.TotalSize, tt.images, tt.size)
+			}
+			if len(result.Files) != tt.images {
+				t.Errorf("got %d files, want %d", len(result.Files), tt.images)
+			}
+			if len(result.DirFileCount) != len(tt.dirCounts) {
+				t.Errorf("got counts for %v, want %v", result.DirFileCount, tt.dirCounts)
+			}
+			for rel, count := range tt.dirCounts {
+				if got := result.DirFileCount[filepath.Join(root, rel)]; got != count {
+					t.Errorf("%s: got %d images, want %d", rel, got, count)
+				}
+			}
+
+			// The command line reports the same totals
+			report := runJSON(t, "-min-count", "0", root)
+			if report.TotalImages != tt.images || report.TotalSize != tt.size {
+				t.Errorf("JSON: got %d images of %d bytes, want %d of %d", report.TotalImages, report.TotalSize, tt.images, tt.size)
+			}
+		})
+	}
+}
+
+func TestScanFiles(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{"b.png": "22", "a.JPG": "1", "sub/c.jpeg": "333", "sub/deep/d.gif": "4444", "notes.txt": "skip"} {
+		writeFile(t, filepath.Join(root, name), content)
+	}
+
+	// Files come back in walk order, each directory's entries in lexical order
+	want := []ImageFile{
+		{Path: filepath.Join(root, "a.JPG"), Size: 1, Ext: ".jpg"},
+		{Path: filepath.Join(root, "b.png"), Size: 2, Ext: ".png"},
+		{Path: filepath.Join(root, "sub", "c.jpeg"), Size: 3, Ext: ".jpeg"},
+		{Path: filepath.Join(root, "sub", "deep", "d.gif"), Size: 4, Ext: ".gif"},
+	}
+	got := scanFiles(t, root)
+	if len(got) != len(want) {
+		t.Fatalf("got %d files, want %d: %v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i].Path != want[i].Path || got[i].Size != want[i].Size || got[i].Ext != want[i].Ext {
+			t.Errorf("file %d: got %+v, want %+v", i, got[i], want[i])
+		}
+		if got[i].ModTime.IsZero() {
+			t.Errorf("file %d: no modification time", i)
+		}
+	}
+
+	// The command line prints a line per file, in the same order
+	stdout, _, _ := runCLI(t, root)
+	var listed []string
+	for _, line := range strings.Split(stdout, "\n") {
+		if path, ok := strings.CutPrefix(line, "File: "); ok {
+			listed = append(listed, path)
+		}
+	}
+	for i, file := range want {
+		if i >= len(listed) || listed[i] != fmt.Sprintf("%s | Size: %d B", file.Path, file.Size) {
+			t.Errorf("listed files:\n%s", strings.Join(listed, "\n"))
+			break
+		}
+	}
+}
+
+func TestOverlappingRoots(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "sub", "x.jpg"), "image")
+
+	for _, args := range [][]string{
+		{root, root},
+		{root, root + string(filepath.Separator)},
+		{root, filepath.Join(root, "sub", "..")},
+	} {
+		if result := runJSON(t, args...); result.TotalImages != 1 || len(result.Roots) > 1 {
+			t.Errorf("%q: got %d images in %d roots, want 1 in 1", args, result.TotalImages, len(result.Roots))
+		}
+	}
+
+	for _, args := range [][]string{
+		{root, filepath.Join(root, "sub")},
+		{filepath.Join(root, "sub"), root},
+	} {
+		if _, _, code := runCLI(t, args...); code != exitUsage {
+			t.Errorf("%q: got exit code %d, want %d", args, code, exitUsage)
+		}
+	}
+}
+
+func TestCacheWithFileModes(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "a.jpg"), "image")
+	cache := filepath.Join(t.TempDir(), "cache.gz")
+
+	if result := runJSON(t, "-cache", cache, root); result.TotalImages != 1 {
+		t.Errorf("-cache: got %d images, want 1", result.TotalImages)
+	}
+	for _, mode := range [][]string{
+		{"-find-dupes"},
+		{"-delete-dupes", "-dry-run"},
+		{"-sort-into", filepath.Join(t.TempDir(), "dest")},
+	} {
+		args := append([]string{"-cache", cache}, append(mode, root)...)
+		if _, stderr, code := runCLI(t, args...); code != exitUsage || !strings.Contains(stderr, "-cache can't be combined") {
+			t.Errorf("%q: got exit code %d (%q), want %d", args, code, stderr, exitUsage)
+		}
+	}
+}
+
+func TestSample(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a.jpg", "b.jpg", "c.jpg"} {
+		writeFile(t, filepath.Join(root, name), "image")
+	}
+
+	for _, tt := range []struct {
+		sample      string
+		wantImages  int
+		wantSampled bool
+	}{
+		{"2", 2, true},
+		{"3", 3, false},
+		{"4", 3, false},
+	} {
+		result := runJSON(t, "-sample", tt.sample, root)
+		if result.TotalImages != tt.wantImages || result.Sampled != tt.wantSampled {
+			t.Errorf("-sample %s: got %d images, sampled %v; want %d, %v", tt.sample, result.TotalImages, result.Sampled, tt.wantImages, tt.wantSampled)
+		}
+	}
+
+	// Modes that compare, record or change the whole tree would act on the sample alone
+	for _, mode := range [][]string{
+		{"-verify-manifest", filepath.Join(t.TempDir(), "SHA256SUMS")},
+		{"-compare", t.TempDir()},
+		{"-delete-dupes", "-dry-run"},
+		{"-sort-into", filepath.Join(t.TempDir(), "dest")},
+		{"-rename", "-dry-run"},
+	} {
+		args := append([]string{"-sample", "2"}, append(mode, root)...)
+		if _, stderr, code := runCLI(t, args...); code != exitUsage || !strings.Contains(stderr, "-sample can't be combined") {
+			t.Errorf("%q: got exit code %d (%q), want %d", args, code, stderr, exitUsage)
+		}
+	}
+}
+
+func TestMinCount(t *testing.T) {
+	root := t.TempDir()
+	for dir, n := range map[string]int{"below": 1, "at": 2, "above": 3} {
+		for i := range n {
+			writeFile(t, filepath.Join(root, dir, fmt.Sprintf("%d.jpg", i)), "image")
+		}
+	}
+
+	// Directories need more than -min-count images, so one holding exactly that many is left out
+	result := runJSON(t, "-min-count", "2", root)
+	if want := []string{filepath.Join(root, "above")}; !slices.Equal(result.AcceptedDirs, want) {
+		t.Errorf("-min-count 2: got %q, want %q", result.AcceptedDirs, want)
+	}
+	result = runJSON(t, "-min-count", "0", root)
+	if want := []string{filepath.Join(root, "above"), filepath.Join(root, "at"), filepath.Join(root, "below")}; !slices.Equal(result.AcceptedDirs, want) {
+		t.Errorf("-min-count 0: got %q, want %q", result.AcceptedDirs, want)
+	}
+	if result.TotalImages != 6 {
+		t.Errorf("got %d images, want all 6 whatever the threshold", result.TotalImages)
+	}
+
+	stdout, _, code := runCLI(t, "-min-count", "2", root)
+	listed := func(dir string) bool {
+		return strings.Contains(stdout, "Directory: "+filepath.Join(root, dir)+" | Image Files:")
+	}
+	if code != exitOK || !listed("above") || listed("at") || listed("below") {
+		t.Errorf("-min-count 2 report (exit code %d):\n%s", code, stdout)
+	}
+}
+
+func TestTop(t *testing.T) {
+	root := t.TempDir()
+	for i := 1; i <= 10; i++ {
+		for j := range i {
+			writeFile(t, filepath.Join(root, fmt.Sprintf("dir%02d", i), fmt.Sprintf("%d.jpg", j)), "image")
+		}
+	}
+
+	result := runJSON(t, "-top", "3", "-min-count", "0", root)
+	want := []string{filepath.Join(root, "dir10"), filepath.Join(root, "dir09"), filepath.Join(root, "dir08")}
+	if !slices.Equal(result.AcceptedDirs, want) {
+		t.Errorf("-top 3: got %q, want %q", result.AcceptedDirs, want)
+	}
+	if result.TotalImages != 55 {
+		t.Errorf("-top 3: got %d images, want all 55 counted", result.TotalImages)
+	}
+}
+
+func TestExcludeExt(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a.jpg", "b.png", "c.svg", "d.GIF", "e.jpeg"} {
+		writeFile(t, filepath.Join(root, name), "image")
+	}
+
+	for _, args := range [][]string{
+		{"-exclude-ext", "svg,gif"},
+		{"-exclude-ext", ".SVG, gif"},
+		{"-ext", "jpg,jpeg,png,svg,gif", "-exclude-ext", "svg,gif"},
+	} {
+		result := runJSON(t, append(args, root)...)
+		var exts []string
+		for _, total := range result.Extensions {
+			exts = append(exts, total.Ext)
+		}
+		slices.Sort(exts)
+		if want := []string{".jpg", ".png"}; result.TotalImages != 3 || !slices.Equal(exts, want) {
+			t.Errorf("%q: got %d images with extensions %q, want 3 with %q", args, result.TotalImages, exts, want)
+		}
+	}
+}
+
+func TestRootSpellings(t *testing.T) {
+	parent := t.TempDir()
+	writeFile(t, filepath.Join(parent, "photos", "a.jpg"), "image")
+	writeFile(t, filepath.Join(parent, "photos", "sub", "b.jpg"), "image")
+	writeFile(t, filepath.Join(parent, "other", "c.jpg"), "image")
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(parent); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	want := []scanner.DirCount{{Path: filepath.Join(parent, "photos"), Count: 1}, {Path: filepath.Join(parent, "photos", "sub"), Count: 1}}
+	for _, root := range []string{"photos", "./photos/", "photos/.", "other/../photos", filepath.Join(parent, "photos")} {
+		if result := runJSON(t, "-min-count", "0", root); !slices.Equal(result.Directories, want) {
+			t.Errorf("%s: got directories %v, want %v", root, result.Directories, want)
+		}
+	}
+}
+
+func TestNoImages(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "notes.txt"), "not an image")
+
+	stdout, _, code := runCLI(t, root)
+	if code != exitOK {
+		t.Errorf("got exit code %d, want %d", code, exitOK)
+	}
+	if !strings.Contains(stdout, "No image files found under "+root+"\n") {
+		t.Errorf("no message about finding no images:\n%s", stdout)
+	}
+	for _, unwanted := range []string{"Total Size", "Directories sorted by"} {
+		if strings.Contains(stdout, unwanted) {
+			t.Errorf("got %q in a report of no images:\n%s", unwanted, stdout)
+		}
+	}
+
+	// Images in no directory passing the threshold leave out that ranking's header
+	writeFile(t, filepath.Join(root, "a.jpg"), "image")
+	stdout, _, _ = runCLI(t, "-min-count", "5", root)
+	if strings.Contains(stdout, "Directories sorted by number") || !strings.Contains(stdout, "Total Images: 1") {
+		t.Errorf("report with no directory above -min-count:\n%s", stdout)
+	}
+}
+
+func TestZeroByte(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "a.jpg"), "image")
+	writeFile(t, filepath.Join(root, "sub", "broken.png"), "")
+
+	result := runJSON(t, root)
+	if result.TotalImages != 1 || !slices.Equal(result.ZeroByte, []string{filepath.Join(root, "sub", "broken.png")}) {
+		t.Errorf("got %d images and zero-byte %q, want 1 and broken.png", result.TotalImages, result.ZeroByte)
+	}
+	for _, dc := range result.Directories {
+		if dc.Path == filepath.Join(root, "sub") {
+			t.Errorf("got %d images in sub, want it left out", dc.Count)
+		}
+	}
+
+	stdout, stderr, code := runCLI(t, "-min-count", "0", root)
+	if code != exitOK {
+		t.Fatalf("exit code %d, stderr:\n%s", code, stderr)
+	}
+	for _, want := range []string{"Zero-byte images", filepath.Join(root, "sub", "broken.png"), "1 zero-byte images", "Total Images: 1"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("output lacks %q:\n%s", want, stdout)
+		}
+	}
+
+	result = runJSON(t, "-include-zero-byte", root)
+	if result.TotalImages != 2 || result.ZeroByte != nil {
+		t.Errorf("-include-zero-byte: got %d images and zero-byte %q, want 2 and none", result.TotalImages, result.ZeroByte)
+	}
+}
+
+func TestMinDimensionFlags(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "thumb.jpg"), string(jpegBytes(t, 150, 150)))
+	writeFile(t, filepath.Join(root, "photo.jpg"), string(jpegBytes(t, 400, 300)))
+	writeFile(t, filepath.Join(root, "broken.jpg"), "not a jpeg")
+
+	for _, tt := range []struct {
+		args []string
+		want int
+	}{
+		{[]string{"-min-width", "200", "-min-height", "200"}, 2},
+		{[]string{"-min-width", "200", "-keep-undecodable=false"}, 1},
+		{[]string{"-min-height", "301"}, 1},
+	} {
+		if result := runJSON(t, append(tt.args, root)...); result.TotalImages != tt.want {
+			t.Errorf("%q: got %d images, want %d", tt.args, result.TotalImages, tt.want)
+		}
+	}
+}
+
+func TestExtensionsAddUp(t *testing.T) {
+	root := t.TempDir()
+	for i, name := range []string{"a.jpg", "b.jpeg", "c.png", "sub/d.gif", "sub/e.PNG"} {
+		writeFile(t, filepath.Join(root, name), strings.Repeat("x", 10*(i+1)))
+	}
+
+	result := runJSON(t, root)
+	count, size := 0, int64(0)
+	for i, et := range result.Extensions {
+		count += et.Count
+		size += et.Size
+		if i > 0 && et.Size > result.Extensions[i-1].Size {
+			t.Errorf("%s comes after the smaller %s", et.Ext, result.Extensions[i-1].Ext)
+		}
+	}
+	if count != result.TotalImages || size != result.TotalSize || count != 5 {
+		t.Errorf("extensions %+v add up to %d images of %d bytes, want the totals of %d and %d", result.Extensions, count, size, result.TotalImages, result.TotalSize)
+	}
+}
+
+func TestSkipDirGlobFlag(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a.jpg", "Photos.app/b.jpg", "node_modules/c.jpg", "keep/d.jpg"} {
+		writeFile(t, filepath.Join(root, name), "image")
+	}
+
+	if result := runJSON(t, "-skip-dir-glob", "*.app", "-skip-dir-glob", "node_modules", root); result.TotalImages != 2 {
+		t.Errorf("got %d images, want 2", result.TotalImages)
+	}
+	if _, stderr, code := runCLI(t, "-skip-dir-glob", "[", root); code != exitUsage || !strings.Contains(stderr, "Invalid -skip-dir-glob") {
+		t.Errorf("bad pattern: got exit code %d, stderr:\n%s", code, stderr)
+	}
+}
+
+func TestListDirsPrint0(t *testing.T) {
+	root := t.TempDir()
+	dirs := []string{"plain", "with space"}
+	// Not every filesystem allows control characters in names
+	for _, odd := range []string{"tab\there", "new\nline"} {
+		if err := os.Mkdir(filepath.Join(root, odd), 0o755); err == nil {
+			dirs = append(dirs, odd)
+		}
+	}
+	for _, dir := range dirs {
+		writeFile(t, filepath.Join(root, dir, "a.jpg"), "image")
+	}
+
+	stdout, stderr, code := runCLI(t, "-list-dirs", "-print0", "-min-count", "0", "-relative", root)
+	if code != exitOK {
+		t.Fatalf("exit code %d, stderr:\n%s", code, stderr)
+	}
+	if !strings.HasSuffix(stdout, "\x00") {
+		t.Fatalf("the list doesn't end with a NUL byte: %q", stdout)
+	}
+	got := strings.Split(strings.TrimSuffix(stdout, "\x00"), "\x00")
+	slices.Sort(got)
+	want := slices.Sorted(slices.Values(dirs))
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	// The only newline is the one in a directory's name, kept as is
+	if strings.Count(stdout, "\n") != strings.Count(strings.Join(dirs, ""), "\n") {
+		t.Errorf("stray newlines in %q", stdout)
+	}
+
+	stdout, _, _ = runCLI(t, "-list-dirs", "-min-count", "0", "-relative", root)
+	if !strings.Contains(stdout, "plain\n") || strings.Contains(stdout, "\x00") {
+		t.Errorf("without -print0: got %q, want a line per directory", stdout)
+	}
+
+	if _, _, code := runCLI(t, "-print0", root); code != exitUsage {
+		t.Errorf("-print0 without -list-dirs: got exit code %d, want %d", code, exitUsage)
+	}
+}
+
+func TestIncludeVideo(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "a.jpg"), strings.Repeat("x", 10))
+	writeFile(t, filepath.Join(root, "clips", "b.mp4"), strings.Repeat("x", 100))
+	writeFile(t, filepath.Join(root, "clips", "c.MOV"), strings.Repeat("x", 200))
+	writeFile(t, filepath.Join(root, "d.avi"), strings.Repeat("x", 400))
+
+	categories := func(result ScanResult) map[string]int {
+		counts := make(map[string]int)
+		for _, ct := range result.Categories {
+			counts[ct.Category] = ct.Count
+		}
+		return counts
+	}
+
+	result := runJSON(t, root)
+	if got := categories(result); result.TotalImages != 1 || result.TotalSize != 10 || got["Video"] != 0 {
+		t.Errorf("by default: got %d files of %d bytes in %v, want only the JPEG", result.TotalImages, result.TotalSize, got)
+	}
+
+	result = runJSON(t, "-include-video", root)
+	if got := categories(result); result.TotalImages != 4 || result.TotalSize != 710 || got["Video"] != 3 || got["Photo"] != 1 {
+		t.Errorf("-include-video: got %d files of %d bytes in %v, want 3 videos and a photo", result.TotalImages, result.TotalSize, got)
+	}
+	for _, ct := range result.Categories {
+		if ct.Category == "Video" && ct.Size != 700 {
+			t.Errorf("got %d bytes of video, want 700", ct.Size)
+		}
+	}
+
+	result = runJSON(t, "-include-video", "-exclude-ext", "avi", root)
+	if got := categories(result); got["Video"] != 2 {
+		t.Errorf("-include-video -exclude-ext avi: got %v, want 2 videos", got)
+	}
+	if _, stderr, _ := runCLI(t, "-ext", "jpg,mp4", root); !strings.Contains(stderr, "not a recognized image extension") {
+		t.Errorf("-ext mp4 without -include-video isn't warned about:\n%s", stderr)
+	}
+}
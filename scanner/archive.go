@@ -0,0 +1,47 @@
+package scanner
+
+import (
+	"archive/zip"
+	"context"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ArchiveSeparator joins the path of an archive and the name of an entry in it, as in
+// backup.zip!DCIM/IMG_0001.jpg
+const ArchiveSeparator = "!"
+
+// isArchive reports whether the file at path is a ZIP archive that Options.ScanArchives looks into
+func isArchive(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".zip")
+}
+
+// walkArchive calls found for every entry of the ZIP archive at archive that has an image
+// extension and passes the globs, with the entry's uncompressed size and modification time
+// as its info. Archives that can't be read are reported to opts.OnSkip and left out.
+func walkArchive(ctx context.Context, archive string, opts Options, found func(path, archive string, info fs.FileInfo)) {
+	r, err := retry(ctx, opts, archive, func() (*zip.ReadCloser, error) {
+		return zip.OpenReader(longPath(archive))
+	})
+	if err != nil {
+		if opts.OnSkip != nil {
+			opts.OnSkip(archive, err)
+		}
+		return
+	}
+	defer r.Close()
+
+	for _, entry := range r.File {
+		if entry.FileInfo().IsDir() || !opts.Extensions[strings.ToLower(path.Ext(entry.Name))] {
+			continue
+		}
+		virtual := archive + ArchiveSeparator + entry.Name
+		if opts.SkipHidden && strings.HasPrefix(path.Base(entry.Name), ".") ||
+			!globsAllow(virtual, opts.IncludeGlobs, opts.ExcludeGlobs) {
+			continue
+		}
+		found(virtual, archive, entry.FileInfo())
+	}
+}
@@ -0,0 +1,160 @@
+package scanner
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// StatCache remembers the size and modification time of the images in each directory
+// between runs, so a directory whose own modification time hasn't changed since the last
+// run doesn't have its images stat'ed again. Adding, removing or renaming a file changes
+// its directory's modification time; editing a file in place doesn't, so a rewritten file
+// keeps its cached size until something else in its directory changes.
+type StatCache struct {
+	mu    sync.Mutex
+	old   map[string]cachedDir // as loaded from disk
+	new   map[string]cachedDir // what this run saw, written by Save
+	valid map[string]bool      // directories whose entries in old can be trusted
+}
+
+type cachedDir struct {
+	ModTime time.Time             `json:"mod_time"`
+	Files   map[string]cachedFile `json:"files"`
+}
+
+type cachedFile struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// Version of the cache file layout; files with another version are ignored
+const statCacheVersion = 1
+
+type statCacheFile struct {
+	Version int                  `json:"version"`
+	Dirs    map[string]cachedDir `json:"dirs"`
+}
+
+// LoadStatCache reads the gzipped JSON stat cache at path. A missing file, or one written
+// by another version, yields an empty cache.
+func LoadStatCache(path string) (*StatCache, error) {
+	c := &StatCache{
+		old:   make(map[string]cachedDir),
+		new:   make(map[string]cachedDir),
+		valid: make(map[string]bool),
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	var file statCacheFile
+	if err := json.NewDecoder(gz).Decode(&file); err != nil {
+		return nil, err
+	}
+	if file.Version == statCacheVersion && file.Dirs != nil {
+		c.old = file.Dirs
+	}
+	return c, nil
+}
+
+// Save writes what this run saw to path, replacing the file atomically. Directories the run
+// didn't enter are dropped.
+func (c *StatCache) Save(path string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // fails harmlessly once renamed
+
+	gz := gzip.NewWriter(tmp)
+	err = json.NewEncoder(gz).Encode(statCacheFile{Version: statCacheVersion, Dirs: c.new})
+	if closeErr := gz.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// enterDir records that the walk entered dir, last modified at modTime, and whether the
+// cached file entries of dir are still valid. A directory entered again, like a root scanned
+// in parts with Options.TopLevel, keeps the files stored for it so far.
+func (c *StatCache) enterDir(dir string, modTime time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	dir = filepath.Clean(dir) // the root of a followed symlink has a trailing separator
+	old, ok := c.old[dir]
+	c.valid[dir] = ok && old.ModTime.Equal(modTime)
+	if seen, ok := c.new[dir]; ok && seen.ModTime.Equal(modTime) {
+		return
+	}
+	c.new[dir] = cachedDir{ModTime: modTime, Files: make(map[string]cachedFile)}
+}
+
+// lookup returns the cached file info of path if its directory is unchanged
+func (c *StatCache) lookup(path string) (os.FileInfo, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	dir, name := filepath.Dir(path), filepath.Base(path)
+	if !c.valid[dir] {
+		return nil, false
+	}
+	f, ok := c.old[dir].Files[name]
+	if !ok {
+		return nil, false
+	}
+	return cachedInfo{name: name, file: f}, true
+}
+
+// store records the size and modification time of the file at path for the next run
+func (c *StatCache) store(path string, info os.FileInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	dir := filepath.Dir(path)
+	entry, ok := c.new[dir]
+	if !ok {
+		// Not entered by the walk, e.g. a path read by ScanPaths; there is no directory
+		// modification time to validate it against next time, so don't cache it
+		return
+	}
+	entry.Files[filepath.Base(path)] = cachedFile{Size: info.Size(), ModTime: info.ModTime()}
+}
+
+// cachedInfo is a cached file presented as the os.FileInfo a stat would have returned
+type cachedInfo struct {
+	name string
+	file cachedFile
+}
+
+func (i cachedInfo) Name() string       { return i.name }
+func (i cachedInfo) Size() int64        { return i.file.Size }
+func (i cachedInfo) Mode() fs.FileMode  { return 0 }
+func (i cachedInfo) ModTime() time.Time { return i.file.ModTime }
+func (i cachedInfo) IsDir() bool        { return false }
+func (i cachedInfo) Sys() any           { return nil }
@@ -0,0 +1,84 @@
+package scanner
+
+import (
+	"cmp"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// scanCached scans root with a stat cache loaded from, and saved back to, path
+func scanCached(t *testing.T, root, path string) *Result {
+	t.Helper()
+	cache, err := LoadStatCache(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := Scan(root, Options{MaxDepth: -1, Cache: cache})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestStatCacheRoundTrip(t *testing.T) {
+	root := t.TempDir()
+	cachePath := filepath.Join(t.TempDir(), "cache.gz")
+	for name, content := range map[string]string{"a.jpg": "a", "sub/b.png": "bb", "sub/deep/c.gif": "ccc"} {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	first := scanCached(t, root, cachePath)
+	second := scanCached(t, root, cachePath)
+	if first.TotalImages != 3 || first.TotalSize != 6 {
+		t.Fatalf("first scan: %d images, %d bytes; want 3, 6", first.TotalImages, first.TotalSize)
+	}
+	if second.TotalImages != first.TotalImages || second.TotalSize != first.TotalSize {
+		t.Errorf("cached scan: %d images, %d bytes; want %d, %d", second.TotalImages, second.TotalSize, first.TotalImages, first.TotalSize)
+	}
+	if !slices.EqualFunc(byPath(first.Files), byPath(second.Files), func(a, b ImageFile) bool {
+		return a.Path == b.Path && a.Size == b.Size && a.ModTime.Equal(b.ModTime)
+	}) {
+		t.Errorf("cached files differ:\n%v\n%v", first.Files, second.Files)
+	}
+
+	// A new file changes its directory's modification time, so that directory is stat'ed again
+	if err := os.WriteFile(filepath.Join(root, "sub", "d.jpg"), []byte("dddd"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(root, "sub"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	third := scanCached(t, root, cachePath)
+	if third.TotalImages != 4 || third.TotalSize != 10 {
+		t.Errorf("scan after adding a file: %d images, %d bytes; want 4, 10", third.TotalImages, third.TotalSize)
+	}
+}
+
+func TestStatCacheMissingFile(t *testing.T) {
+	cache, err := LoadStatCache(filepath.Join(t.TempDir(), "missing.gz"))
+	if err != nil {
+		t.Fatalf("LoadStatCache of a missing file: %v", err)
+	}
+	if len(cache.old) != 0 {
+		t.Errorf("missing cache file loaded %d directories", len(cache.old))
+	}
+}
+
+func byPath(files []ImageFile) []ImageFile {
+	files = slices.Clone(files)
+	slices.SortFunc(files, func(a, b ImageFile) int { return cmp.Compare(a.Path, b.Path) })
+	return files
+}
@@ -0,0 +1,125 @@
+package scanner
+
+import "sort"
+
+// Logical category of each image extension, used for the category totals.
+// Extensions missing from this map are reported as "Other".
+var imageCategories = map[string]string{
+	".raw":  "RAW",
+	".cr2":  "RAW",
+	".nef":  "RAW",
+	".orf":  "RAW",
+	".sr2":  "RAW",
+	".arw":  "RAW",
+	".dng":  "RAW",
+	".rw2":  "RAW",
+	".jpg":  "Photo",
+	".jpeg": "Photo",
+	".png":  "Photo",
+	".heic": "Photo",
+	".heif": "Photo",
+	".svg":  "Graphic",
+	".gif":  "Graphic",
+	".bmp":  "Graphic",
+	".tiff": "Graphic",
+	".webp": "Graphic",
+	".mp4":  "Video",
+	".mov":  "Video",
+	".avi":  "Video",
+	".m4v":  "Video",
+	".mkv":  "Video",
+	".3gp":  "Video",
+	".mts":  "Video",
+}
+
+// Canonical extension of each alias extension, so images grouped by extension, like the
+// -sort-into folders, end up in one group whichever spelling the camera used. The
+// per-file output keeps the real extension.
+var extensionAliases = map[string]string{
+	".jpeg": ".jpg",
+	".tif":  ".tiff",
+	".heif": ".heic",
+}
+
+// CanonicalExt returns the canonical form of an image extension, which is ext itself
+// unless it is an alias
+func CanonicalExt(ext string) string {
+	if canonical, ok := extensionAliases[ext]; ok {
+		return canonical
+	}
+	return ext
+}
+
+// SetAlias makes extension ext group with canonical, replacing any built-in alias. Making
+// ext its own canonical form stops it being an alias. It is not safe to call while a scan
+// is running.
+func SetAlias(ext, canonical string) {
+	if ext == canonical {
+		delete(extensionAliases, ext)
+		return
+	}
+	extensionAliases[ext] = canonical
+}
+
+// CategoryOf returns the category of an image extension, or of its canonical form when
+// the extension itself has none
+func CategoryOf(ext string) string {
+	if category, ok := imageCategories[ext]; ok {
+		return category
+	}
+	if category, ok := imageCategories[CanonicalExt(ext)]; ok {
+		return category
+	}
+	return "Other"
+}
+
+// CategoryTotal holds the number and total size of the images in one category
+type CategoryTotal struct {
+	Category string `json:"category"`
+	Count    int    `json:"count"`
+	Size     int64  `json:"size"`
+}
+
+// SortCategories returns the category totals sorted by size in descending order
+func SortCategories(categories map[string]CategoryTotal) []CategoryTotal {
+	sorted := make([]CategoryTotal, 0, len(categories))
+	for _, ct := range categories {
+		sorted = append(sorted, ct)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Size != sorted[j].Size {
+			return sorted[i].Size > sorted[j].Size
+		}
+		return sorted[i].Category < sorted[j].Category
+	})
+	return sorted
+}
+
+// ExtTotal holds the number and total size of the images with one extension, in its
+// canonical form
+type ExtTotal struct {
+	Ext   string `json:"ext"`
+	Count int    `json:"count"`
+	Size  int64  `json:"size"`
+}
+
+// SortExtensions returns the extension totals sorted by size in descending order
+func SortExtensions(extensions map[string]ExtTotal) []ExtTotal {
+	sorted := make([]ExtTotal, 0, len(extensions))
+	for _, et := range extensions {
+		sorted = append(sorted, et)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Size != sorted[j].Size {
+			return sorted[i].Size > sorted[j].Size
+		}
+		return sorted[i].Ext < sorted[j].Ext
+	})
+	return sorted
+}
+
+// SetCategory makes images with extension ext count towards category, replacing any
+// built-in category. It is not safe to call while a scan is running.
+func SetCategory(ext, category string) {
+	imageCategories[ext] = category
+}
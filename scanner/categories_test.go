@@ -0,0 +1,85 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCanonicalExt(t *testing.T) {
+	tests := map[string]string{
+		".jpg":  ".jpg",
+		".jpeg": ".jpg",
+		".tif":  ".tiff",
+		".tiff": ".tiff",
+		".heif": ".heic",
+		".png":  ".png",
+	}
+	for ext, want := range tests {
+		if got := CanonicalExt(ext); got != want {
+			t.Errorf("CanonicalExt(%q) = %q, want %q", ext, got, want)
+		}
+	}
+}
+
+func TestCategoryOfAlias(t *testing.T) {
+	if got := CategoryOf(".tif"); got != "Graphic" {
+		t.Errorf("CategoryOf(.tif) = %q, want Graphic like .tiff", got)
+	}
+	if got := CategoryOf(".xyz"); got != "Other" {
+		t.Errorf("CategoryOf(.xyz) = %q, want Other", got)
+	}
+}
+
+func TestSetAlias(t *testing.T) {
+	defer SetAlias(".jpeg", ".jpg")
+	SetAlias(".jpeg", ".jpeg")
+	if got := CanonicalExt(".jpeg"); got != ".jpeg" {
+		t.Errorf("after dropping the alias, CanonicalExt(.jpeg) = %q", got)
+	}
+}
+
+func TestAliasesAreScanned(t *testing.T) {
+	for alias := range extensionAliases {
+		if !DefaultExtensions[alias] {
+			t.Errorf("alias %s is not a scanned extension", alias)
+		}
+	}
+}
+
+func TestExtensionTotals(t *testing.T) {
+	root := t.TempDir()
+	sizes := map[string]int{"a.jpg": 10, "b.JPEG": 20, "c.png": 300, "sub/d.tif": 5, "sub/e.TIFF": 7, "sub/f.gif": 1}
+	for name, size := range sizes {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(strings.Repeat("x", size)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := Scan(root, Options{MaxDepth: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sorted := SortExtensions(result.Extensions)
+	want := []ExtTotal{{".png", 1, 300}, {".jpg", 2, 30}, {".tiff", 2, 12}, {".gif", 1, 1}}
+	if !slices.Equal(sorted, want) {
+		t.Errorf("got %+v, want %+v", sorted, want)
+	}
+	count, size := 0, int64(0)
+	for ext, et := range result.Extensions {
+		if ext != et.Ext {
+			t.Errorf("%s: keyed as %q", et.Ext, ext)
+		}
+		count += et.Count
+		size += et.Size
+	}
+	if count != result.TotalImages || size != result.TotalSize {
+		t.Errorf("extensions add up to %d images of %d bytes, want the totals of %d and %d", count, size, result.TotalImages, result.TotalSize)
+	}
+}
@@ -0,0 +1,82 @@
+package scanner_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kwdowicz/image_sorter/scanner"
+)
+
+// xpfFormat reads a made-up camera format: "XPF1", then the width and height as 16-bit and
+// the capture time as 32-bit Unix seconds, all big-endian, then the pixels
+type xpfFormat struct{}
+
+// header reads the fixed-size header of the XPF file at path
+func (xpfFormat) header(path string) (width, height uint16, taken time.Time, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, time.Time{}, err
+	}
+	defer f.Close()
+	head := make([]byte, 12)
+	if _, err := io.ReadFull(f, head); err != nil || string(head[:4]) != "XPF1" {
+		return 0, 0, time.Time{}, errors.New("not an XPF file")
+	}
+	width, height = binary.BigEndian.Uint16(head[4:]), binary.BigEndian.Uint16(head[6:])
+	return width, height, time.Unix(int64(binary.BigEndian.Uint32(head[8:])), 0).UTC(), nil
+}
+
+func (x xpfFormat) Validate(path string) error {
+	_, _, _, err := x.header(path)
+	return err
+}
+
+func (x xpfFormat) Dimensions(path string) (width, height int, err error) {
+	w, h, _, err := x.header(path)
+	return int(w), int(h), err
+}
+
+func (x xpfFormat) CaptureTime(path string) (time.Time, error) {
+	_, _, taken, err := x.header(path)
+	return taken, err
+}
+
+func ExampleRegisterFormat() {
+	scanner.RegisterFormat(".xpf", xpfFormat{})
+
+	dir, err := os.MkdirTemp("", "example")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+	head := []byte("XPF1\x0f\xc0\x0b\xd0") // 4032×3024
+	head = binary.BigEndian.AppendUint32(head, uint32(time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC).Unix()))
+	os.WriteFile(filepath.Join(dir, "camera.XPF"), append(head, "pixels"...), 0o644)
+	os.WriteFile(filepath.Join(dir, "renamed.xpf"), []byte("\x89PNG\r\n\x1a\n"), 0o644)
+
+	result, err := scanner.Scan(dir, scanner.Options{})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(result.TotalImages, "images")
+	handler, _ := scanner.LookupFormat(".xpf")
+	for _, file := range result.Files {
+		name := filepath.Base(file.Path)
+		if err := handler.Validate(file.Path); err != nil {
+			fmt.Printf("%s: %v\n", name, err)
+			continue
+		}
+		w, h, _ := handler.Dimensions(file.Path)
+		taken, _ := handler.CaptureTime(file.Path)
+		fmt.Printf("%s: %d×%d, taken %s\n", name, w, h, taken.Format(time.DateOnly))
+	}
+	// Output:
+	// 2 images
+	// camera.XPF: 4032×3024, taken 2024-06-01
+	// renamed.xpf: not an XPF file
+}
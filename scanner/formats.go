@@ -0,0 +1,129 @@
+package scanner
+
+import (
+	"errors"
+	"image"
+	"os"
+	"strings"
+	"time"
+)
+
+// FormatHandler knows how to read one image format. Handlers are looked up by file
+// extension, see RegisterFormat.
+type FormatHandler interface {
+	// Validate returns an error if the file's content isn't actually in this format
+	Validate(path string) error
+	// Dimensions returns the image's size in pixels
+	Dimensions(path string) (width, height int, err error)
+	// CaptureTime returns when the image was taken, from metadata in the file
+	CaptureTime(path string) (time.Time, error)
+}
+
+// ErrNotSupported is returned by FormatHandler methods that the format can't answer,
+// like the capture time of a format without metadata
+var ErrNotSupported = errors.New("not supported for this format")
+
+// Registered handlers by lowercase extension, including the leading dot
+var formats = make(map[string]FormatHandler)
+
+// Built-in image formats, registered with a HeaderFormat handler
+var builtinExtensions = []string{
+	".jpg",
+	".jpeg",
+	".png",
+	".gif",
+	".bmp",
+	".tiff",
+	".tif",
+	".svg",
+	".webp",
+	".heic", // High Efficiency Image Format used on iOS devices
+	".heif", // Another High Efficiency Image Format extension
+	".raw",  // RAW image format
+	".cr2",  // Canon RAW format
+	".nef",  // Nikon RAW format
+	".orf",  // Olympus RAW format
+	".sr2",  // Sony RAW format
+	".arw",  // Sony RAW format
+	".dng",  // Adobe Digital Negative format
+	".rw2",  // Panasonic RAW format
+}
+
+func init() {
+	for _, ext := range builtinExtensions {
+		RegisterFormat(ext, HeaderFormat{})
+	}
+}
+
+// RegisterFormat makes files with extension ext count as images, read by handler. The
+// extension is matched case-insensitively, with or without its leading dot, and replaces
+// any handler registered for it before, including a built-in one. It is not safe to call
+// while a scan is running. A handler for a proprietary format might look like:
+//
+//	type xpfFormat struct{ scanner.HeaderFormat }
+//
+//	func (xpfFormat) Validate(path string) error {
+//		head := make([]byte, 4)
+//		f, err := os.Open(path)
+//		if err != nil {
+//			return err
+//		}
+//		defer f.Close()
+//		if _, err := io.ReadFull(f, head); err != nil || string(head) != "XPF1" {
+//			return errors.New("not an XPF file")
+//		}
+//		return nil
+//	}
+//
+//	func init() {
+//		scanner.RegisterFormat(".xpf", xpfFormat{})
+//	}
+func RegisterFormat(ext string, handler FormatHandler) {
+	if handler == nil {
+		panic("scanner: RegisterFormat handler is nil")
+	}
+	ext = strings.ToLower(ext)
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	formats[ext] = handler
+	DefaultExtensions[ext] = true
+}
+
+// LookupFormat returns the handler registered for the extension ext, which must be
+// lowercase and include the leading dot like ImageFile.Ext
+func LookupFormat(ext string) (FormatHandler, bool) {
+	handler, ok := formats[ext]
+	return handler, ok
+}
+
+// HeaderFormat is a FormatHandler for formats without readable metadata. It accepts any
+// content and reads dimensions with image.DecodeConfig, so only for formats whose
+// decoder the program registered with the image package. It can be embedded to
+// implement only some of the methods.
+type HeaderFormat struct{}
+
+// Validate accepts any content
+func (HeaderFormat) Validate(path string) error {
+	return nil
+}
+
+// Dimensions reads the width and height from the image header
+func (HeaderFormat) Dimensions(path string) (width, height int, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	config, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return 0, 0, err
+	}
+	return config.Width, config.Height, nil
+}
+
+// CaptureTime always returns ErrNotSupported
+func (HeaderFormat) CaptureTime(path string) (time.Time, error) {
+	return time.Time{}, ErrNotSupported
+}
@@ -0,0 +1,69 @@
+package scanner
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// stampFormat is a handler telling a fixed capture time, to see which one is looked up
+type stampFormat struct {
+	HeaderFormat
+	at time.Time
+}
+
+func (f stampFormat) CaptureTime(path string) (time.Time, error) { return f.at, nil }
+
+func TestRegisterFormat(t *testing.T) {
+	t.Cleanup(func() {
+		delete(formats, ".abc")
+		delete(DefaultExtensions, ".abc")
+		RegisterFormat(".png", HeaderFormat{})
+	})
+	at := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	// Matched case-insensitively, with or without the dot
+	RegisterFormat("ABC", stampFormat{at: at})
+	if !DefaultExtensions[".abc"] {
+		t.Error(".abc isn't a default extension after registering it")
+	}
+	handler, ok := LookupFormat(".abc")
+	if !ok {
+		t.Fatal("no handler for .abc")
+	}
+	if got, err := handler.CaptureTime("x.abc"); err != nil || !got.Equal(at) {
+		t.Errorf("CaptureTime = %v, %v; want the registered handler's %v", got, err, at)
+	}
+
+	// A built-in format can be replaced
+	RegisterFormat(".png", stampFormat{at: at})
+	if handler, _ := LookupFormat(".png"); handler == (HeaderFormat{}) {
+		t.Error("the built-in .png handler wasn't replaced")
+	}
+
+	if _, ok := LookupFormat(".nope"); ok {
+		t.Error("got a handler for an unregistered extension")
+	}
+}
+
+func TestRegisterFormatNil(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a nil handler didn't panic")
+		}
+	}()
+	RegisterFormat(".abc", nil)
+}
+
+func TestHeaderFormat(t *testing.T) {
+	var h HeaderFormat
+	if err := h.Validate("anything"); err != nil {
+		t.Errorf("Validate = %v, want any content accepted", err)
+	}
+	if _, err := h.CaptureTime("anything"); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("CaptureTime error = %v, want ErrNotSupported", err)
+	}
+	if _, _, err := h.Dimensions("missing.png"); err == nil {
+		t.Error("Dimensions of a missing file didn't fail")
+	}
+}
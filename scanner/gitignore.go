@@ -0,0 +1,113 @@
+package scanner
+
+import (
+	"bufio"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// IgnoreRules is a list of gitignore-style patterns, matched against paths relative to the
+// scan root. As in .gitignore files:
+//
+//   - blank lines and lines starting with # are skipped; \# and \! escape a leading # or !
+//   - a pattern starting with ! re-includes what an earlier pattern excluded
+//   - a pattern ending with / only matches directories
+//   - a pattern with a / anywhere else is anchored at the root; otherwise it matches a
+//     file or directory name at any depth
+//   - * and ? don't match /, and a ** element matches any number of directories
+//
+// The last matching pattern wins. A file below an excluded directory can't be
+// re-included, since the scan never enters that directory.
+type IgnoreRules struct {
+	rules []ignoreRule
+}
+
+// ignoreRule is a single parsed pattern
+type ignoreRule struct {
+	elems    []string // pattern elements; a single name for unanchored patterns
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// ParseIgnoreRules reads gitignore-style patterns from r, one per line
+func ParseIgnoreRules(r io.Reader) (*IgnoreRules, error) {
+	rules := &IgnoreRules{}
+	lines := bufio.NewScanner(r)
+	for lines.Scan() {
+		line := strings.TrimRight(lines.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var rule ignoreRule
+		if rest, ok := strings.CutPrefix(line, "!"); ok {
+			rule.negate, line = true, rest
+		} else if strings.HasPrefix(line, `\#`) || strings.HasPrefix(line, `\!`) {
+			line = line[1:]
+		}
+		if rest, ok := strings.CutSuffix(line, "/"); ok {
+			rule.dirOnly, line = true, rest
+		}
+		rule.anchored = strings.Contains(line, "/")
+		rule.elems = splitElements(line)
+		if len(rule.elems) == 0 {
+			continue
+		}
+		if err := CheckGlob(line); err != nil {
+			return nil, err
+		}
+		rules.rules = append(rules.rules, rule)
+	}
+	return rules, lines.Err()
+}
+
+// Match reports whether the path rel, relative to the scan root, is ignored. decided is false
+// if no pattern matches it, leaving the decision to other options like Options.IgnoreDirs.
+func (r *IgnoreRules) Match(rel string, isDir bool) (ignored, decided bool) {
+	if r == nil {
+		return false, false
+	}
+	elems := splitElements(filepath.ToSlash(rel))
+	if len(elems) == 0 {
+		return false, false
+	}
+	for _, rule := range r.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		var matched bool
+		if rule.anchored {
+			matched = matchAllElements(rule.elems, elems)
+		} else {
+			matched, _ = filepath.Match(rule.elems[0], elems[len(elems)-1])
+		}
+		if matched {
+			ignored, decided = !rule.negate, true
+		}
+	}
+	return ignored, decided
+}
+
+// matchAllElements reports whether pattern matches all of path, element by element
+func matchAllElements(pattern, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+	if pattern[0] == "**" {
+		for skip := 0; skip <= len(path); skip++ {
+			if matchAllElements(pattern[1:], path[skip:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(path) == 0 {
+		return false
+	}
+	if ok, _ := filepath.Match(pattern[0], path[0]); !ok {
+		return false
+	}
+	return matchAllElements(pattern[1:], path[1:])
+}
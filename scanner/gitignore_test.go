@@ -0,0 +1,93 @@
+package scanner
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func parseRules(t *testing.T, patterns string) *IgnoreRules {
+	t.Helper()
+	rules, err := ParseIgnoreRules(strings.NewReader(patterns))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rules
+}
+
+func TestIgnoreRulesMatch(t *testing.T) {
+	rules := parseRules(t, `
+# comment, and a blank line above
+*.tmp.jpg
+cache/
+!keep.tmp.jpg
+/top-only
+raw/**/drafts
+\#hash.jpg
+`)
+	tests := []struct {
+		rel              string
+		isDir            bool
+		ignored, decided bool
+	}{
+		{"a.tmp.jpg", false, true, true},
+		{"sub/b.tmp.jpg", false, true, true},
+		{"sub/keep.tmp.jpg", false, false, true}, // negated, and decided so IgnoreDirs doesn't apply
+		{"cache", true, true, true},
+		{"sub/cache", true, true, true},
+		{"cache", false, false, false}, // directory-only patterns skip files
+		{"top-only", true, true, true},
+		{"sub/top-only", true, false, false}, // anchored at the root
+		{"raw/drafts", true, true, true},
+		{"raw/2020/01/drafts", true, true, true},
+		{"#hash.jpg", false, true, true},
+		{"photo.jpg", false, false, false},
+	}
+	for _, tt := range tests {
+		ignored, decided := rules.Match(filepath.FromSlash(tt.rel), tt.isDir)
+		if ignored != tt.ignored || decided != tt.decided {
+			t.Errorf("Match(%q, dir %v) = %v, %v; want %v, %v", tt.rel, tt.isDir, ignored, decided, tt.ignored, tt.decided)
+		}
+	}
+}
+
+func TestIgnoreRulesLastMatchWins(t *testing.T) {
+	rules := parseRules(t, "*.jpg\n!*.jpg\nfinal.jpg\n")
+	if ignored, _ := rules.Match("other.jpg", false); ignored {
+		t.Error("other.jpg is ignored, but the negation came later")
+	}
+	if ignored, _ := rules.Match("final.jpg", false); !ignored {
+		t.Error("final.jpg isn't ignored, but its pattern came last")
+	}
+}
+
+func TestScanIgnoreRules(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"photos", "cache", "photos/cache", "Users", "skipped"} {
+		writeImages(t, filepath.Join(root, dir), 1)
+	}
+	writeImages(t, filepath.Join(root, "skipped", "kept"), 1)
+	// A directory-only pattern, a negation re-including a directory ignored by default,
+	// and a negation that can't reach below an excluded directory
+	rules := parseRules(t, "cache/\n!Users\nskipped/\n!skipped/kept/\n")
+
+	result, err := Scan(root, Options{MaxDepth: -1, IgnoreDirs: DefaultIgnoreDirs, IgnoreRules: rules})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for dir := range result.DirFileCount {
+		got = append(got, filepath.ToSlash(relPath(root, dir)))
+	}
+	slices.Sort(got)
+	if want := []string{"Users", "photos"}; !slices.Equal(got, want) {
+		t.Errorf("got images in %q, want %q", got, want)
+	}
+}
+
+func TestParseIgnoreRulesBadPattern(t *testing.T) {
+	if _, err := ParseIgnoreRules(strings.NewReader("[unclosed\n")); err == nil {
+		t.Error("got no error for a malformed pattern")
+	}
+}
@@ -0,0 +1,91 @@
+package scanner
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// matchGlob reports whether the glob pattern matches path. Patterns are matched element by
+// element with filepath.Match semantics, so * and ? stop at separators, and a ** element
+// matches any number of elements. A pattern matches if it matches any run of consecutive
+// elements of path, like *thumbnail* matching a file or a directory above it, or
+// */DCIM/* matching every file below a DCIM folder; a leading / anchors it at the start.
+// Both are compared with forward slashes.
+func matchGlob(pattern, path string) bool {
+	pattern, path = filepath.ToSlash(pattern), filepath.ToSlash(path)
+	anchored := strings.HasPrefix(pattern, "/")
+	patternElems := splitElements(pattern)
+	pathElems := splitElements(path)
+
+	for start := 0; start <= len(pathElems); start++ {
+		if matchElements(patternElems, pathElems[start:]) {
+			return true
+		}
+		if anchored {
+			break
+		}
+	}
+	return false
+}
+
+// splitElements splits a slash-separated path into its non-empty elements
+func splitElements(path string) []string {
+	var elems []string
+	for _, elem := range strings.Split(path, "/") {
+		if elem != "" {
+			elems = append(elems, elem)
+		}
+	}
+	return elems
+}
+
+// matchElements reports whether pattern matches a prefix of path, element by element
+func matchElements(pattern, path []string) bool {
+	if len(pattern) == 0 {
+		return true
+	}
+	if pattern[0] == "**" {
+		for skip := 0; skip <= len(path); skip++ {
+			if matchElements(pattern[1:], path[skip:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(path) == 0 {
+		return false
+	}
+	if ok, _ := filepath.Match(pattern[0], path[0]); !ok {
+		return false
+	}
+	return matchElements(pattern[1:], path[1:])
+}
+
+// CheckGlob returns filepath.ErrBadPattern if pattern is malformed
+func CheckGlob(pattern string) error {
+	for _, elem := range splitElements(filepath.ToSlash(pattern)) {
+		if _, err := filepath.Match(elem, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// globsAllow reports whether path passes the include and exclude globs: it must match at
+// least one include glob, if there are any, and no exclude glob
+func globsAllow(path string, include, exclude []string) bool {
+	for _, pattern := range exclude {
+		if matchGlob(pattern, path) {
+			return false
+		}
+	}
+	if len(include) == 0 {
+		return true
+	}
+	for _, pattern := range include {
+		if matchGlob(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
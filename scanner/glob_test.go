@@ -0,0 +1,108 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"*/DCIM/*", "/media/card/DCIM/100APPLE/IMG_1.JPG", true},
+		{"*/DCIM/*", "/media/card/Pictures/IMG_1.JPG", false},
+		{"*thumbnail*", "/photos/thumbnails/a.jpg", true},
+		{"*thumbnail*", "/photos/a_thumbnail.jpg", true},
+		{"*thumbnail*", "/photos/a.jpg", false},
+		{"*.jpg", "/photos/sub/a.jpg", true}, // * doesn't cross separators, but any last element may match
+		{"photos/**/a.jpg", "/home/photos/2020/01/a.jpg", true},
+		{"photos/**/a.jpg", "/home/photos/a.jpg", true},
+		{"/home/*/a.jpg", "/home/photos/a.jpg", true},
+		{"/photos/*", "/home/photos/a.jpg", false}, // anchored at the start
+	}
+	for _, tt := range tests {
+		if got := matchGlob(tt.pattern, filepath.FromSlash(tt.path)); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestScanGlobs(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"DCIM/100APPLE", "DCIM/thumbnails", "Pictures", "Pictures/thumbnails"} {
+		writeImages(t, filepath.Join(root, dir), 1)
+	}
+
+	tests := []struct {
+		name             string
+		include, exclude []string
+		want             []string // directories with images, relative to root
+	}{
+		{"no globs", nil, nil, []string{"DCIM/100APPLE", "DCIM/thumbnails", "Pictures", "Pictures/thumbnails"}},
+		{"include only", []string{"*/DCIM/*"}, nil, []string{"DCIM/100APPLE", "DCIM/thumbnails"}},
+		{"exclude only", nil, []string{"*thumbnail*"}, []string{"DCIM/100APPLE", "Pictures"}},
+		{"include and exclude", []string{"*/DCIM/*"}, []string{"*thumbnail*"}, []string{"DCIM/100APPLE"}},
+		{"several includes", []string{"*/DCIM/100*", "*/Pictures/*.jpg"}, nil, []string{"DCIM/100APPLE", "Pictures"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Scan(root, Options{MaxDepth: -1, IncludeGlobs: tt.include, ExcludeGlobs: tt.exclude})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for dir := range result.DirFileCount {
+				got = append(got, filepath.ToSlash(relPath(root, dir)))
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got images in %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSkipDirGlobs(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"", "Photos.app/Contents/Resources", "node_modules/pkg", "web/node_modules", "thumbcache", "Pictures/2020", "Pictures/apps"} {
+		writeImages(t, filepath.Join(root, dir), 1)
+	}
+	// The patterns match directories only, so an image named like one is still counted
+	if err := os.WriteFile(filepath.Join(root, "Pictures", "my_cache.jpg"), []byte("image"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var ignored []string
+	result, err := Scan(root, Options{
+		MaxDepth:     -1,
+		SkipDirGlobs: []string{"*.app", "node_modules", "*cache*"},
+		OnIgnore:     func(path string) { ignored = append(ignored, relPath(root, path)) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dirs []string
+	for dir := range result.DirFileCount {
+		dirs = append(dirs, relPath(root, dir))
+	}
+	slices.Sort(dirs)
+	if want := []string{".", "Pictures", filepath.Join("Pictures", "2020"), filepath.Join("Pictures", "apps")}; !slices.Equal(dirs, want) {
+		t.Errorf("got directories %q, want %q", dirs, want)
+	}
+	if result.TotalImages != 4 {
+		t.Errorf("got %d images, want 4", result.TotalImages)
+	}
+	slices.Sort(ignored)
+	if want := []string{"Photos.app", "node_modules", "thumbcache", filepath.Join("web", "node_modules")}; !slices.Equal(ignored, want) {
+		t.Errorf("got skipped %q, want %q", ignored, want)
+	}
+
+	// The root itself is scanned whatever its name
+	result, err = Scan(filepath.Join(root, "thumbcache"), Options{MaxDepth: -1, SkipDirGlobs: []string{"*cache*"}})
+	if err != nil || result.TotalImages != 1 {
+		t.Errorf("scanning a matching root: got %d images, %v, want 1", result.TotalImages, err)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package scanner
+
+// longPath is only needed on Windows, where it lifts the MAX_PATH limit
+func longPath(path string) string {
+	return path
+}
+
+// shortPath is only needed on Windows, where it undoes longPath
+func shortPath(path string) string {
+	return path
+}
@@ -0,0 +1,34 @@
+//go:build windows
+
+package scanner
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// longPath returns the extended-length form of an absolute path, \\?\C:\dir or
+// \\?\UNC\server\share\dir, which the Windows API accepts beyond the 260 character MAX_PATH
+// limit. Relative and already extended paths are returned unchanged. A trailing separator,
+// which makes WalkDir resolve a symlinked directory, is kept.
+func longPath(path string) string {
+	if strings.HasPrefix(path, `\\?\`) || !filepath.IsAbs(path) {
+		return path
+	}
+	if strings.HasSuffix(path, `\`) && len(path) > 3 {
+		return longPath(strings.TrimRight(path, `\`)) + `\`
+	}
+	path = filepath.Clean(path)
+	if rest, ok := strings.CutPrefix(path, `\\`); ok {
+		return `\\?\UNC\` + rest
+	}
+	return `\\?\` + path
+}
+
+// shortPath turns an extended-length path back into its usual form for reporting
+func shortPath(path string) string {
+	if rest, ok := strings.CutPrefix(path, `\\?\UNC\`); ok {
+		return `\\` + rest
+	}
+	return strings.TrimPrefix(path, `\\?\`)
+}
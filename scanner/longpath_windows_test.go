@@ -0,0 +1,58 @@
+//go:build windows
+
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLongPath(t *testing.T) {
+	tests := []struct {
+		path, long string
+	}{
+		{`C:\photos\a.jpg`, `\\?\C:\photos\a.jpg`},
+		{`C:\photos\..\other`, `\\?\C:\other`},
+		{`C:\photos\linked\`, `\\?\C:\photos\linked\`}, // keeps the separator resolving a symlink
+		{`C:\`, `\\?\C:\`},
+		{`\\server\share\photos`, `\\?\UNC\server\share\photos`},
+		{`\\?\C:\already`, `\\?\C:\already`},
+		{`relative\a.jpg`, `relative\a.jpg`},
+	}
+	for _, tt := range tests {
+		if got := longPath(tt.path); got != tt.long {
+			t.Errorf("longPath(%q) = %q, want %q", tt.path, got, tt.long)
+		}
+	}
+	for _, path := range []string{`C:\photos\a.jpg`, `\\server\share\photos`} {
+		if got := shortPath(longPath(path)); got != path {
+			t.Errorf("shortPath(longPath(%q)) = %q", path, got)
+		}
+	}
+}
+
+func TestScanBeyondMaxPath(t *testing.T) {
+	root := t.TempDir()
+	// Nest folders until the image's path is well past the 260 character MAX_PATH limit
+	dir := root
+	for len(dir) < 300 {
+		dir = filepath.Join(dir, strings.Repeat("d", 50))
+	}
+	if err := os.MkdirAll(longPath(dir), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	image := filepath.Join(dir, "a.jpg")
+	if err := os.WriteFile(longPath(image), []byte("image"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := Scan(root, Options{MaxDepth: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Files) != 1 || result.Files[0].Path != image || result.Files[0].Size != 5 {
+		t.Errorf("got %+v, want %s of 5 bytes reported without the \\\\?\\ prefix", result.Files, image)
+	}
+}
@@ -0,0 +1,66 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// ScanPaths counts the images among the newline-separated paths read from r, applying the
+// same extension, glob, size and modification time filters as Scan. Paths that can't be stat'ed
+// are reported to opts.OnSkip and left out, and directories are ignored; nothing is walked.
+// When ctx is cancelled the images found so far are returned along with ctx's error.
+func ScanPaths(ctx context.Context, r io.Reader, opts Options) (*Result, error) {
+	opts = opts.withDefaults()
+	result := newResult(opts)
+
+	lines := bufio.NewScanner(r)
+	for lines.Scan() {
+		if err := ctx.Err(); err != nil {
+			result.finishTopDirs()
+			return result, err
+		}
+
+		path := strings.TrimSpace(lines.Text())
+		if path == "" || !opts.Extensions[strings.ToLower(filepath.Ext(path))] ||
+			!globsAllow(path, opts.IncludeGlobs, opts.ExcludeGlobs) {
+			continue
+		}
+
+		info, err := stat(ctx, opts, path)
+		if err != nil {
+			if opts.OnSkip != nil {
+				opts.OnSkip(path, err)
+			}
+			continue
+		}
+		if info.IsDir() || !opts.accepts(info) {
+			continue
+		}
+		if opts.SkipZeroByte && info.Size() == 0 {
+			result.ZeroByte = append(result.ZeroByte, path)
+			continue
+		}
+
+		file := ImageFile{
+			Path:    path,
+			Size:    info.Size(),
+			Ext:     strings.ToLower(filepath.Ext(path)),
+			ModTime: info.ModTime(),
+		}
+		if !opts.acceptsDims(file) {
+			continue
+		}
+		result.add(file, opts.GroupBy(path))
+		if !opts.TotalsOnly {
+			result.Files = append(result.Files, file)
+		}
+		if opts.OnImage != nil {
+			opts.OnImage(file)
+		}
+	}
+	result.finishTopDirs()
+	return result, lines.Err()
+}
@@ -0,0 +1,217 @@
+package scanner
+
+import (
+	"path/filepath"
+	"sort"
+)
+
+// Result holds the totals and files found by a scan
+type Result struct {
+	TotalSize    int64
+	TotalImages  int
+	DirFileCount map[string]int           // image count per group, by default per directory
+	DirFileSize  map[string]int64         // size of the images directly inside each directory
+	Categories   map[string]CategoryTotal // image count and size per category
+	Extensions   map[string]ExtTotal      // image count and size per canonical extension, see CanonicalExt
+	Files        []ImageFile              // all found images in walk order
+	Skipped      []string                 // paths skipped because of permission errors
+	ZeroByte     []string                 // zero-byte images left out with Options.SkipZeroByte
+	Truncated    bool                     // the walk stopped at Options.Limit, so the totals are partial
+	DirEntries   map[string]int           // files of any kind directly inside each directory entered, with Options.RecordDirs
+
+	minCount   int
+	totalsOnly bool
+	topCounts  *topN[DirCount] // with Options.TopDirs, the directories with the most images finished so far
+	topSizes   *topN[DirSize]  // and those with the largest images
+}
+
+// NewResult returns an empty Result, ready to Merge other results into
+func NewResult() *Result {
+	return &Result{
+		DirFileCount: make(map[string]int),
+		DirFileSize:  make(map[string]int64),
+		Categories:   make(map[string]CategoryTotal),
+		Extensions:   make(map[string]ExtTotal),
+		DirEntries:   make(map[string]int),
+	}
+}
+
+// newResult returns an empty Result for a scan with the given options
+func newResult(opts Options) *Result {
+	result := NewResult()
+	result.minCount = opts.MinCount
+	result.totalsOnly = opts.TotalsOnly
+	if !opts.RecordDirs {
+		result.DirEntries = nil
+	}
+	if opts.TotalsOnly && opts.TopDirs > 0 {
+		result.topCounts = &topN[DirCount]{n: opts.TopDirs, before: func(a, b DirCount) bool {
+			return a.Count > b.Count || (a.Count == b.Count && a.Path < b.Path)
+		}}
+		result.topSizes = &topN[DirSize]{n: opts.TopDirs, before: func(a, b DirSize) bool {
+			return a.Size > b.Size || (a.Size == b.Size && a.Path < b.Path)
+		}}
+	}
+	return result
+}
+
+// flushDir moves the counts of the finished directory dir out of the maps into the
+// Options.TopDirs rankings, where they are kept only if they make the cut
+func (r *Result) flushDir(dir string) {
+	count, counted := r.DirFileCount[dir]
+	size, sized := r.DirFileSize[dir]
+	if counted {
+		r.topCounts.offer(DirCount{Path: dir, Count: count})
+		delete(r.DirFileCount, dir)
+	}
+	if sized {
+		r.topSizes.offer(DirSize{Path: dir, Size: size})
+		delete(r.DirFileSize, dir)
+	}
+}
+
+// finishTopDirs flushes the directories still in the maps, then fills the maps with the
+// Options.TopDirs rankings. It does nothing without TopDirs.
+func (r *Result) finishTopDirs() {
+	if r.topCounts == nil {
+		return
+	}
+	for dir := range r.DirFileCount {
+		r.flushDir(dir)
+	}
+	for dir := range r.DirFileSize {
+		r.flushDir(dir)
+	}
+	for _, dc := range r.topCounts.items {
+		r.DirFileCount[dc.Path] = dc.Count
+	}
+	for _, ds := range r.topSizes.items {
+		r.DirFileSize[ds.Path] = ds.Size
+	}
+	r.topCounts, r.topSizes = nil, nil
+}
+
+// add counts a found image belonging to group
+func (r *Result) add(file ImageFile, group string) {
+	r.TotalSize += file.Size // Add the file size to the total
+	r.TotalImages++
+
+	// Track the count of images in each group
+	if !r.totalsOnly || r.topCounts != nil {
+		r.DirFileCount[group]++
+		r.DirFileSize[filepath.Dir(file.Path)] += file.Size
+	}
+
+	category := CategoryOf(file.Ext)
+	ct := r.Categories[category]
+	ct.Category = category
+	ct.Count++
+	ct.Size += file.Size
+	r.Categories[category] = ct
+
+	ext := CanonicalExt(file.Ext)
+	et := r.Extensions[ext]
+	et.Ext = ext
+	et.Count++
+	et.Size += file.Size
+	r.Extensions[ext] = et
+}
+
+// Merge adds the totals and files of other to r
+func (r *Result) Merge(other *Result) {
+	r.TotalSize += other.TotalSize
+	r.TotalImages += other.TotalImages
+	for group, count := range other.DirFileCount {
+		r.DirFileCount[group] += count
+	}
+	for dir, size := range other.DirFileSize {
+		r.DirFileSize[dir] += size
+	}
+	for category, ct := range other.Categories {
+		merged := r.Categories[category]
+		merged.Category = category
+		merged.Count += ct.Count
+		merged.Size += ct.Size
+		r.Categories[category] = merged
+	}
+	for ext, et := range other.Extensions {
+		merged := r.Extensions[ext]
+		merged.Ext = ext
+		merged.Count += et.Count
+		merged.Size += et.Size
+		r.Extensions[ext] = merged
+	}
+	for dir, count := range other.DirEntries {
+		r.DirEntries[dir] += count
+	}
+	r.Truncated = r.Truncated || other.Truncated
+	r.Files = append(r.Files, other.Files...)
+	r.Skipped = append(r.Skipped, other.Skipped...)
+	r.ZeroByte = append(r.ZeroByte, other.ZeroByte...)
+}
+
+// AcceptedDirs returns the directories holding more than Options.MinCount images, with the
+// most images first
+func (r *Result) AcceptedDirs() []DirCount {
+	return AcceptDirs(SortDirCounts(r.DirFileCount), r.minCount, 0)
+}
+
+// DirCount holds the number of image files found in a single directory
+type DirCount struct {
+	Path  string `json:"path"`
+	Count int    `json:"count"`
+}
+
+// SortDirCounts returns the directory counts sorted by file count in descending order,
+// breaking ties by path so the order is stable across runs
+func SortDirCounts(dirFileCount map[string]int) []DirCount {
+	sortedDirs := make([]DirCount, 0, len(dirFileCount))
+	for dir, count := range dirFileCount {
+		sortedDirs = append(sortedDirs, DirCount{Path: dir, Count: count})
+	}
+
+	// Sort by file count in descending order
+	sort.Slice(sortedDirs, func(i, j int) bool {
+		if sortedDirs[i].Count != sortedDirs[j].Count {
+			return sortedDirs[i].Count > sortedDirs[j].Count
+		}
+		return sortedDirs[i].Path < sortedDirs[j].Path
+	})
+	return sortedDirs
+}
+
+// AcceptDirs returns the sorted directories holding strictly more than minCount image
+// files, so a directory with exactly minCount images is not accepted. With top > 0 only the
+// first top of those are kept. Since sortedDirs is in descending order, the result is a prefix of it.
+func AcceptDirs(sortedDirs []DirCount, minCount, top int) []DirCount {
+	accepted := 0
+	for accepted < len(sortedDirs) && sortedDirs[accepted].Count > minCount {
+		accepted++
+	}
+	if top > 0 && accepted > top {
+		accepted = top
+	}
+	return sortedDirs[:accepted]
+}
+
+// DirSize holds the total size of the image files directly inside a directory
+type DirSize struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// SortDirSizes returns the directory sizes sorted by bytes in descending order,
+// breaking ties by path so the order is stable across runs
+func SortDirSizes(dirFileSize map[string]int64) []DirSize {
+	sortedDirs := make([]DirSize, 0, len(dirFileSize))
+	for dir, size := range dirFileSize {
+		sortedDirs = append(sortedDirs, DirSize{Path: dir, Size: size})
+	}
+	sort.Slice(sortedDirs, func(i, j int) bool {
+		if sortedDirs[i].Size != sortedDirs[j].Size {
+			return sortedDirs[i].Size > sortedDirs[j].Size
+		}
+		return sortedDirs[i].Path < sortedDirs[j].Path
+	})
+	return sortedDirs
+}
@@ -0,0 +1,31 @@
+package scanner
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestAcceptDirsTop(t *testing.T) {
+	// Ten directories holding 1 to 10 images, but dir07 ties with dir08 at 8, ranking first by path
+	counts := make(map[string]int)
+	for i := 1; i <= 10; i++ {
+		counts[fmt.Sprintf("dir%02d", i)] = i
+	}
+	counts["dir07"] = 8
+
+	var got []string
+	for _, dc := range AcceptDirs(SortDirCounts(counts), 0, 3) {
+		got = append(got, dc.Path)
+	}
+	if want := []string{"dir10", "dir09", "dir07"}; !slices.Equal(got, want) {
+		t.Errorf("top 3: got %q, want %q", got, want)
+	}
+
+	if got := AcceptDirs(SortDirCounts(counts), 8, 3); len(got) != 2 {
+		t.Errorf("top 3 of those with more than 8 images: got %v, want dir10 and dir09", got)
+	}
+	if got := AcceptDirs(SortDirCounts(counts), 0, 0); len(got) != 10 {
+		t.Errorf("no top: got %d directories, want all 10", len(got))
+	}
+}
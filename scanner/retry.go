@@ -0,0 +1,57 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"syscall"
+	"time"
+)
+
+// isTransient reports whether err may go away when the operation is tried again, like a
+// timeout or a resource that is temporarily unavailable on a network mount. Missing files
+// and permission errors never do.
+func isTransient(err error) bool {
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) {
+		return false
+	}
+	var timeout interface{ Timeout() bool }
+	if errors.As(err, &timeout) && timeout.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EINTR) || errors.Is(err, syscall.ETIMEDOUT)
+}
+
+// retry calls op for the file at path until it succeeds, fails with an error that isn't
+// transient, or was retried opts.IORetries times, waiting opts.IOBackoff before the first
+// retry and twice as long before each further one. opts.OnRetry is told about each retry.
+// When ctx is cancelled while waiting, the last error is returned.
+func retry[T any](ctx context.Context, opts Options, path string, op func() (T, error)) (T, error) {
+	wait := opts.IOBackoff
+	for attempt := 1; ; attempt++ {
+		v, err := op()
+		if err == nil || attempt > opts.IORetries || !isTransient(err) {
+			return v, err
+		}
+		if opts.OnRetry != nil {
+			opts.OnRetry(path, attempt, err)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return v, err
+		case <-timer.C:
+		}
+		wait *= 2
+	}
+}
+
+// stat is os.Stat retried on transient errors
+func stat(ctx context.Context, opts Options, path string) (fs.FileInfo, error) {
+	return retry(ctx, opts, path, func() (fs.FileInfo, error) {
+		return os.Stat(path)
+	})
+}
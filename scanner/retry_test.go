@@ -0,0 +1,91 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// flaky returns an op failing with the errors in turn, then succeeding, and counts its calls
+func flaky(errs ...error) (op func() (string, error), calls *int) {
+	calls = new(int)
+	return func() (string, error) {
+		*calls++
+		if *calls <= len(errs) {
+			return "", errs[*calls-1]
+		}
+		return "ok", nil
+	}, calls
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&fs.PathError{Op: "stat", Path: "x", Err: syscall.EAGAIN}, true},
+		{fmt.Errorf("reading: %w", syscall.ETIMEDOUT), true},
+		{os.ErrDeadlineExceeded, true},
+		{&fs.PathError{Op: "stat", Path: "x", Err: fs.ErrNotExist}, false},
+		{&fs.PathError{Op: "open", Path: "x", Err: fs.ErrPermission}, false},
+		{errors.New("bad data"), false},
+	}
+	for _, tt := range tests {
+		if got := isTransient(tt.err); got != tt.want {
+			t.Errorf("isTransient(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	var retried []int
+	opts := Options{IORetries: 3, IOBackoff: time.Millisecond, OnRetry: func(path string, attempt int, err error) {
+		retried = append(retried, attempt)
+	}}
+
+	op, calls := flaky(&fs.PathError{Op: "stat", Path: "x", Err: syscall.EAGAIN})
+	v, err := retry(context.Background(), opts, "x", op)
+	if v != "ok" || err != nil || *calls != 2 || len(retried) != 1 {
+		t.Errorf("failing once: got %q, %v after %d calls and retries %v, want ok after 2 calls and 1 retry", v, err, *calls, retried)
+	}
+
+	retried = nil
+	op, calls = flaky(fs.ErrPermission)
+	if _, err := retry(context.Background(), opts, "x", op); !errors.Is(err, fs.ErrPermission) || *calls != 1 || retried != nil {
+		t.Errorf("permission error: got %v after %d calls and retries %v, want it without retrying", err, *calls, retried)
+	}
+
+	retried = nil
+	op, calls = flaky(syscall.EAGAIN, syscall.EAGAIN, syscall.EAGAIN, syscall.EAGAIN)
+	if _, err := retry(context.Background(), opts, "x", op); !errors.Is(err, syscall.EAGAIN) || *calls != 4 || len(retried) != 3 {
+		t.Errorf("always failing: got %v after %d calls and retries %v, want EAGAIN after 4 calls", err, *calls, retried)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	// Waits of 20ms and 40ms before the two retries
+	opts := Options{IORetries: 2, IOBackoff: 20 * time.Millisecond}
+	op, _ := flaky(syscall.EAGAIN, syscall.EAGAIN)
+	start := time.Now()
+	if _, err := retry(context.Background(), opts, "x", op); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("took %v, want at least 60ms", elapsed)
+	}
+}
+
+func TestRetryCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	opts := Options{IORetries: 5, IOBackoff: time.Hour}
+	op, calls := flaky(syscall.EAGAIN)
+	if _, err := retry(ctx, opts, "x", op); !errors.Is(err, syscall.EAGAIN) || *calls != 1 {
+		t.Errorf("got %v after %d calls, want the first EAGAIN without waiting", err, *calls)
+	}
+}
@@ -0,0 +1,535 @@
+// Package scanner finds image files below a directory and totals their count and size per
+// directory and per category. It is the library behind the image_sorter command.
+package scanner
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultExtensions lists the extensions of all registered formats, see RegisterFormat
+var DefaultExtensions = make(map[string]bool)
+
+// VideoExtensions lists common video extensions. They aren't scanned by default, but can
+// be added to Options.Extensions to count videos along with the images.
+var VideoExtensions = map[string]bool{
+	".mp4": true,
+	".mov": true,
+	".avi": true,
+	".m4v": true,
+	".mkv": true,
+	".3gp": true,
+	".mts": true,
+}
+
+// DefaultIgnoreDirs lists system and application directories that rarely hold photos
+var DefaultIgnoreDirs = []string{
+	"Windows",
+	"Program Files",
+	"System Volume Information",
+	"$Recycle.Bin",
+	"Users",
+	"SmartPSS",
+	"Python312",
+	"ProgramData",
+}
+
+// isIgnoredDir reports whether any element of path matches an entry in ignore.
+// Elements are compared whole and case-insensitively, so "users_backup" does not match "Users".
+func isIgnoredDir(path string, ignore []string) bool {
+	for _, element := range strings.Split(filepath.ToSlash(path), "/") {
+		for _, ignoreDir := range ignore {
+			if strings.EqualFold(element, ignoreDir) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchesName reports whether name matches any of the filepath.Match patterns
+func matchesName(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ImageFile describes a single image file found by a scan
+type ImageFile struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	Ext     string    `json:"ext"` // lowercase, including the leading dot
+	ModTime time.Time `json:"mod_time"`
+	Archive string    `json:"archive,omitempty"` // the ZIP file holding the image, see Options.ScanArchives
+}
+
+// Options controls which files Scan picks up and how it processes them. The zero value
+// scans for DefaultExtensions in the root directory only; set MaxDepth to -1 to recurse.
+type Options struct {
+	Extensions     map[string]bool          // extensions counted as images; nil means DefaultExtensions
+	IgnoreDirs     []string                 // directory names to skip, e.g. DefaultIgnoreDirs
+	SkipDirGlobs   []string                 // skip directories whose name matches any of these filepath.Match patterns, with everything below them
+	IgnoreRules    *IgnoreRules             // gitignore-style patterns to skip; where one matches, it overrides IgnoreDirs
+	MinCount       int                      // directories need more than this many images to be in Result.AcceptedDirs
+	GroupBy        func(path string) string // maps an image path to its group; nil counts per directory
+	Workers        int                      // number of goroutines processing image files; at least 1 is used
+	MinSize        int64                    // skip images smaller than this many bytes
+	MaxSize        int64                    // skip images larger than this many bytes; 0 means no limit
+	FollowSymlinks bool                     // descend into symlinked directories and count symlinked files by their target
+	MaxDepth       int                      // deepest directory level to enter below root (0 = root only); -1 means unlimited
+	SkipHidden     bool                     // skip files and directories whose name starts with a dot
+	NewerThan      time.Time                // skip images last modified before this; zero means no limit
+	OlderThan      time.Time                // skip images last modified after this; zero means no limit
+	IncludeGlobs   []string                 // if any are given, only count images whose path matches one of them
+	ExcludeGlobs   []string                 // skip images whose path matches any of these
+	Cache          *StatCache               // sizes and modification times from earlier runs; nil stats every image
+	ScanArchives   bool                     // count the images inside ZIP files too, by their uncompressed size
+	IORetries      int                      // retry stat'ing and opening files this many times on transient errors, like timeouts on a network mount
+	IOBackoff      time.Duration            // wait before the first retry, doubled before each further one
+	SkipZeroByte   bool                     // leave zero-byte images, like failed downloads, out of the counts and list them in Result.ZeroByte instead
+	MinWidth       int                      // skip images narrower than this many pixels, going by their headers; 0 means no limit
+	MinHeight      int                      // skip images less tall than this many pixels; 0 means no limit
+	SkipUnsized    bool                     // with MinWidth or MinHeight, skip the images whose dimensions can't be read instead of counting them
+	Limit          int                      // stop the walk after it found this many images, before the size and time filters; 0 means no limit
+	RecordDirs     bool                     // record every directory entered in Result.DirEntries, images or not
+	TotalsOnly     bool                     // keep only the totals and category counts, leaving Result.Files and the per-directory counts empty
+	TopDirs        int                      // with TotalsOnly, still keep the per-directory counts of this many directories with the most and the largest images, see ScanContext
+
+	TopLevel func(name string, isDir bool) bool        // if set, only the entries directly below root it returns true for are scanned, with everything below them
+	OnImage  func(file ImageFile)                      // called for each found image as it is counted; may be nil
+	OnSkip   func(path string, err error)              // called for each path skipped because it couldn't be read; may be nil
+	OnRetry  func(path string, attempt int, err error) // called before each retry of an operation on path that failed with err; may be nil
+	OnIgnore func(path string)                         // called for each directory left out by IgnoreDirs or IgnoreRules; may be nil
+}
+
+// withDefaults fills in the defaults for unset options
+func (o Options) withDefaults() Options {
+	if o.Extensions == nil {
+		o.Extensions = DefaultExtensions
+	}
+	if o.GroupBy == nil {
+		o.GroupBy = filepath.Dir
+	}
+	if o.Workers < 1 {
+		o.Workers = 1
+	}
+	return o
+}
+
+// accepts reports whether an image with the given file info passes the size and
+// modification time filters
+func (o Options) accepts(info os.FileInfo) bool {
+	if info.Size() < o.MinSize || (o.MaxSize > 0 && info.Size() > o.MaxSize) {
+		return false
+	}
+	if !o.NewerThan.IsZero() && info.ModTime().Before(o.NewerThan) {
+		return false
+	}
+	return o.OlderThan.IsZero() || !info.ModTime().After(o.OlderThan)
+}
+
+// acceptsDims reports whether file passes the MinWidth and MinHeight filters. Its header is
+// only read when one of them is set. Images inside archives can't be opened by path, so
+// like those in formats without a decoder they count as having no readable dimensions.
+func (o Options) acceptsDims(file ImageFile) bool {
+	if o.MinWidth <= 0 && o.MinHeight <= 0 {
+		return true
+	}
+	if file.Archive != "" {
+		return !o.SkipUnsized
+	}
+	handler, ok := LookupFormat(file.Ext)
+	if !ok {
+		handler = HeaderFormat{}
+	}
+	width, height, err := handler.Dimensions(file.Path)
+	if err != nil {
+		return !o.SkipUnsized
+	}
+	return width >= o.MinWidth && height >= o.MinHeight
+}
+
+// relPath returns path relative to root, or path itself if it isn't below root
+func relPath(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return path
+	}
+	return rel
+}
+
+// pathDepth returns how many directory levels path lies below root, 0 for root itself
+func pathDepth(root, path string) int {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." {
+		return 0
+	}
+	return strings.Count(rel, string(filepath.Separator)) + 1
+}
+
+// scanJob is an image file found by the walk, waiting to be processed by a worker
+type scanJob struct {
+	index   int // position in walk order
+	path    string
+	archive string // the ZIP file holding the image, if any
+	entry   fs.DirEntry
+	info    os.FileInfo // target of a followed symlink, cached info or archive entry; nil to stat the entry itself
+}
+
+// scanFileResult is what a worker learned about a single image file
+type scanFileResult struct {
+	index    int
+	file     ImageFile
+	group    string
+	zeroByte bool // left out with Options.SkipZeroByte
+	dropped  bool // left out by the size, time or dimension filters
+	err      error
+}
+
+// skipPermissionError reports whether err is a permission error that the scan should skip
+// past rather than abort on. Skipped paths are reported to opts.OnSkip and recorded in skipped.
+func skipPermissionError(opts Options, path string, err error, skipped *[]string) bool {
+	if !errors.Is(err, os.ErrPermission) {
+		return false
+	}
+	if opts.OnSkip != nil {
+		opts.OnSkip(path, err)
+	}
+	*skipped = append(*skipped, path)
+	return true
+}
+
+// Scan scans the specified directory, counts and adds up the total size of all found
+// images, and tracks image counts per group and per category
+func Scan(root string, opts Options) (*Result, error) {
+	return ScanContext(context.Background(), root, opts)
+}
+
+// ScanContext is Scan with cancellation. The directory walk feeds image paths to
+// opts.Workers worker goroutines, which stat the files and compute their groups; the
+// results are merged here, so the totals are the same for any worker count. All found
+// images are returned in walk order. When ctx is cancelled the walk stops early and the
+// images found so far are returned along with ctx's error. With opts.TotalsOnly the memory
+// used no longer grows with the number of images, as only the totals are kept; opts.OnImage
+// then sees each image once, but not in walk order. Setting opts.TopDirs as well keeps the
+// counts of each directory only until the walk has left it and all its images are merged,
+// and then only if it ranks among the TopDirs directories with the most images or with the
+// largest, so memory grows with the depth of the tree rather than its number of directories.
+// Those counts are per directory, so opts.GroupBy must group by directory, as it does by default.
+func ScanContext(ctx context.Context, root string, opts Options) (*Result, error) {
+	opts = opts.withDefaults()
+
+	jobs := make(chan scanJob)
+	results := make(chan scanFileResult)
+
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				info, err := job.info, error(nil)
+				if info == nil {
+					info, err = retry(ctx, opts, job.path, job.entry.Info)
+				}
+				if err != nil {
+					results <- scanFileResult{index: job.index, file: ImageFile{Path: job.path}, err: err}
+					continue
+				}
+				if opts.Cache != nil && job.archive == "" {
+					opts.Cache.store(job.path, info)
+				}
+				// Images outside the size range or modification time window are left out
+				// of the results entirely
+				if !opts.accepts(info) {
+					results <- scanFileResult{index: job.index, file: ImageFile{Path: job.path}, dropped: true}
+					continue
+				}
+				file := ImageFile{
+					Path:    job.path,
+					Size:    info.Size(),
+					Ext:     strings.ToLower(filepath.Ext(job.path)),
+					ModTime: info.ModTime(),
+					Archive: job.archive,
+				}
+				if opts.SkipZeroByte && file.Size == 0 {
+					results <- scanFileResult{index: job.index, file: file, zeroByte: true}
+					continue
+				}
+				if !opts.acceptsDims(file) {
+					results <- scanFileResult{index: job.index, file: file, dropped: true}
+					continue
+				}
+				results <- scanFileResult{index: job.index, file: file, group: opts.GroupBy(job.path)}
+			}
+		}()
+	}
+
+	result := newResult(opts)
+	var tracker *dirTracker
+	if result.topCounts != nil {
+		tracker = newDirTracker()
+	}
+	// Only touched by the walk goroutine until results is closed, like result.DirEntries
+	var walkSkipped []string
+	var walkTruncated bool
+	var walkErr error
+	index := 0
+	go func() {
+		walkSkipped, walkTruncated, walkErr = walk(ctx, root, opts, result.DirEntries, func(path, archive string, d fs.DirEntry, info os.FileInfo) {
+			if tracker != nil {
+				tracker.enter(filepath.Dir(path))
+			}
+			jobs <- scanJob{index: index, path: path, archive: archive, entry: d, info: info}
+			index++
+		})
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	var firstErr error
+	var found []scanFileResult
+
+	collect := func(r scanFileResult) {
+		switch {
+		case r.err != nil:
+			if !skipPermissionError(opts, r.file.Path, r.err, &result.Skipped) && firstErr == nil {
+				firstErr = r.err
+			}
+		case r.zeroByte:
+			result.ZeroByte = append(result.ZeroByte, r.file.Path)
+		case r.dropped:
+		default:
+			result.add(r.file, r.group)
+			if opts.OnImage != nil {
+				opts.OnImage(r.file)
+			}
+			if !opts.TotalsOnly {
+				found = append(found, r)
+			}
+		}
+	}
+	for r := range results {
+		collect(r)
+		if tracker != nil {
+			tracker.merged(filepath.Dir(r.file.Path))
+			for _, dir := range tracker.take() {
+				result.flushDir(dir)
+			}
+		}
+	}
+	result.finishTopDirs()
+
+	// Workers finish in any order, so restore the walk order for the returned files
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].index < found[j].index
+	})
+	result.Skipped = append(walkSkipped, result.Skipped...)
+	sort.Strings(result.ZeroByte)
+	result.Truncated = walkTruncated
+	if !opts.TotalsOnly {
+		result.Files = make([]ImageFile, len(found))
+		for i, r := range found {
+			result.Files[i] = r.file
+		}
+	}
+
+	if walkErr != nil {
+		return result, walkErr
+	}
+	return result, firstErr
+}
+
+// walk walks the tree below root, honoring the ignore, hidden, depth and symlink options,
+// and calls found in walk order for every file with an image extension that passes the
+// globs. info is the file's followed symlink target or cached stat, or nil if it has none.
+// With opts.ScanArchives the images inside ZIP files are reported too, with the archive's
+// path, a nil d and the entry's info. Paths skipped because of permission errors are returned.
+// If dirs isn't nil, every directory entered is added to it with its number of files of any
+// kind, hidden and ignored ones aside. The walk ends early once found was called opts.Limit
+// times and another image turns up, which is then reported as truncated; a tree with exactly
+// opts.Limit images is walked to the end and isn't. Walking a directory always lists its entries in lexical order, so the same tree
+// yields the same images up to the limit every time.
+//
+// On Windows the tree is walked through extended-length \\?\ paths, so directories nested
+// beyond the 260 character MAX_PATH limit are reachable, also below UNC \\server\share roots.
+// Paths are reported without the prefix.
+func walk(ctx context.Context, root string, opts Options, dirs map[string]int, found func(path, archive string, d fs.DirEntry, info os.FileInfo)) (skipped []string, truncated bool, err error) {
+	root = shortPath(root)
+	visited := newDirSet()
+	count := 0
+	report := func(path, archive string, d fs.DirEntry, info os.FileInfo) {
+		if opts.Limit > 0 && count >= opts.Limit {
+			truncated = true
+			return
+		}
+		count++
+		found(path, archive, d, info)
+	}
+	var walkTree func(dir string) error
+	walkTree = func(dir string) error {
+		return filepath.WalkDir(longPath(dir), func(path string, d fs.DirEntry, err error) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if truncated {
+				return filepath.SkipAll
+			}
+			path = shortPath(path)
+			if err != nil {
+				// An unreadable directory is skipped; anything else, like a missing root, is fatal
+				if skipPermissionError(opts, path, err, &skipped) {
+					return nil
+				}
+				return err
+			}
+
+			// Leave out the top-level entries the caller isn't interested in
+			if opts.TopLevel != nil && path != root && pathDepth(root, path) == 1 && !opts.TopLevel(d.Name(), d.IsDir()) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			// Skip ignored paths. Only the part below root is matched, so scanning
+			// C:\Users\me\Pictures isn't ruled out by the "Users" entry. The directories
+			// above path were checked on the way down, so only its own name is compared
+			// with IgnoreDirs, and a rule re-including "Users" lets the scan into it.
+			// SkipDirGlobs apply regardless of the rules.
+			if path != root {
+				ignored, decided := opts.IgnoreRules.Match(relPath(root, path), d.IsDir())
+				if !decided {
+					ignored = d.IsDir() && isIgnoredDir(filepath.Base(path), opts.IgnoreDirs)
+				}
+				if d.IsDir() && matchesName(d.Name(), opts.SkipDirGlobs) {
+					ignored = true
+				}
+				if ignored && d.IsDir() {
+					if opts.OnIgnore != nil {
+						opts.OnIgnore(path)
+					}
+					return filepath.SkipDir
+				}
+				if ignored {
+					return nil
+				}
+			}
+
+			// Skip hidden files and directories, but never the root itself (which may be ".")
+			if opts.SkipHidden && path != root && strings.HasPrefix(d.Name(), ".") {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			// Skip directories deeper than the depth limit
+			if d.IsDir() && opts.MaxDepth >= 0 && pathDepth(root, path) > opts.MaxDepth {
+				return filepath.SkipDir
+			}
+
+			if d.IsDir() && opts.Cache != nil {
+				info, err := retry(ctx, opts, path, d.Info)
+				if err != nil {
+					return err
+				}
+				opts.Cache.enterDir(path, info.ModTime())
+			}
+
+			var target os.FileInfo
+			if opts.FollowSymlinks {
+				if d.IsDir() {
+					// Also catches directories already entered through a symlink
+					info, err := retry(ctx, opts, path, d.Info)
+					if err != nil {
+						return err
+					}
+					if !visited.add(info) {
+						return filepath.SkipDir
+					}
+				}
+
+				if d.Type()&fs.ModeSymlink != 0 {
+					target, err = stat(ctx, opts, path)
+					if err != nil {
+						return nil // Dangling symlinks are not images
+					}
+					if target.IsDir() {
+						// The trailing separator makes WalkDir resolve the link instead of
+						// treating it as a file; the visited check happens on its first callback
+						return walkTree(path + string(filepath.Separator))
+					}
+				}
+			}
+
+			if d.IsDir() {
+				// The root of a followed symlink has a trailing separator
+				if _, ok := dirs[filepath.Clean(path)]; !ok && dirs != nil {
+					dirs[filepath.Clean(path)] = 0
+				}
+				return nil
+			}
+			if dirs != nil {
+				dirs[filepath.Dir(path)]++
+			}
+
+			if opts.ScanArchives && !d.IsDir() && isArchive(path) {
+				walkArchive(ctx, path, opts, func(path, archive string, info fs.FileInfo) {
+					report(path, archive, nil, info)
+				})
+				return nil
+			}
+
+			// Report files with an image extension that pass the globs
+			if !d.IsDir() && opts.Extensions[strings.ToLower(filepath.Ext(path))] &&
+				globsAllow(path, opts.IncludeGlobs, opts.ExcludeGlobs) {
+				if target == nil && opts.Cache != nil && d.Type().IsRegular() {
+					target, _ = opts.Cache.lookup(path)
+				}
+				report(path, "", d, target)
+			}
+			return nil
+		})
+	}
+
+	err = walkTree(root)
+	return skipped, truncated, err
+}
+
+// CountByDir counts the images below root per group and per category like Scan, but from
+// the directory listings alone. No image is stat'ed, so it is much faster on slow mounts;
+// in turn the result has no sizes or files, the size and modification time filters don't
+// apply, and opts.OnImage isn't called.
+func CountByDir(ctx context.Context, root string, opts Options) (*Result, error) {
+	opts = opts.withDefaults()
+	opts.Cache = nil // there are no stats to remember
+	result := newResult(opts)
+	skipped, truncated, err := walk(ctx, root, opts, result.DirEntries, func(path, archive string, d fs.DirEntry, info os.FileInfo) {
+		result.add(ImageFile{Path: path, Ext: strings.ToLower(filepath.Ext(path)), Archive: archive}, opts.GroupBy(path))
+	})
+	result.Skipped = skipped
+	result.Truncated = truncated
+	return result, err
+}
+
+// Count returns how many image files Scan would look at below root, without stat'ing any
+// of them. The size and modification time filters aren't applied, so Scan may count fewer.
+func Count(ctx context.Context, root string, opts Options) (int, error) {
+	opts = opts.withDefaults()
+	opts.Cache = nil // counting isn't a scan the cache should remember
+	count := 0
+	_, _, err := walk(ctx, root, opts, nil, func(string, string, fs.DirEntry, os.FileInfo) { count++ })
+	return count, err
+}
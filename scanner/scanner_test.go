@@ -0,0 +1,346 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"maps"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+)
+
+// writeImages creates n small images named img0.jpg, img1.jpg... in dir
+func writeImages(t testing.TB, dir string, n int) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for i := range n {
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("img%d.jpg", i)), []byte("image"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLimit(t *testing.T) {
+	tests := []struct {
+		name          string
+		images, limit int
+		wantImages    int
+		wantTruncated bool
+	}{
+		{"no limit", 5, 0, 5, false},
+		{"below the limit", 4, 5, 4, false},
+		{"exactly the limit", 5, 5, 5, false},
+		{"one past the limit", 6, 5, 5, true},
+		{"far past the limit", 20, 5, 5, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			// Split over two directories, so the limit can be hit at the end of the first
+			first := min(tt.images, tt.limit)
+			writeImages(t, filepath.Join(root, "a"), first)
+			writeImages(t, filepath.Join(root, "b"), tt.images-first)
+			opts := Options{MaxDepth: -1, Limit: tt.limit}
+
+			result, err := Scan(root, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.TotalImages != tt.wantImages || len(result.Files) != tt.wantImages || result.Truncated != tt.wantTruncated {
+				t.Errorf("Scan: got %d images (%d files), truncated %v; want %d, %v",
+					result.TotalImages, len(result.Files), result.Truncated, tt.wantImages, tt.wantTruncated)
+			}
+
+			counted, err := CountByDir(context.Background(), root, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if counted.TotalImages != tt.wantImages || counted.Truncated != tt.wantTruncated {
+				t.Errorf("CountByDir: got %d images, truncated %v; want %d, %v", counted.TotalImages, counted.Truncated, tt.wantImages, tt.wantTruncated)
+			}
+		})
+	}
+}
+
+func TestIsIgnoredDir(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"Users", true},
+		{"users", true},
+		{"photos/USERS/me", true},
+		{"users_backup", false},
+		{"photos/users_backup/2020", false},
+		{"windows-photos", false},
+		{"My Windows", false},
+		{"Program Files", true},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := isIgnoredDir(tt.path, DefaultIgnoreDirs); got != tt.want {
+			t.Errorf("isIgnoredDir(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestScanIgnoreDirs(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"users_backup", "windows-photos", "users", "WINDOWS"} {
+		writeImages(t, filepath.Join(root, dir), 1)
+	}
+
+	result, err := Scan(root, Options{MaxDepth: -1, IgnoreDirs: DefaultIgnoreDirs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for dir, want := range map[string]int{"users_backup": 1, "windows-photos": 1, "users": 0, "WINDOWS": 0} {
+		if got := result.DirFileCount[filepath.Join(root, dir)]; got != want {
+			t.Errorf("%s: got %d images, want %d", dir, got, want)
+		}
+	}
+}
+
+// writeTree creates dirs directories of perDir images each below root, with sizes varying
+// per image so the totals depend on every file
+func writeTree(t testing.TB, root string, dirs, perDir int) {
+	t.Helper()
+	for d := range dirs {
+		dir := filepath.Join(root, fmt.Sprintf("dir%d", d), fmt.Sprintf("sub%d", d%3))
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		for i := range perDir {
+			content := make([]byte, 1+(d*perDir+i)%97)
+			if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("img%d.jpg", i)), content, 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestScanWorkersMatchSequential(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, 20, 15)
+
+	sequential, err := Scan(root, Options{MaxDepth: -1, Workers: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, workers := range []int{2, 8, 32} {
+		concurrent, err := Scan(root, Options{MaxDepth: -1, Workers: workers})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if concurrent.TotalSize != sequential.TotalSize || concurrent.TotalImages != sequential.TotalImages {
+			t.Errorf("%d workers: got %d images, %d bytes; want %d, %d", workers,
+				concurrent.TotalImages, concurrent.TotalSize, sequential.TotalImages, sequential.TotalSize)
+		}
+		if !maps.Equal(concurrent.DirFileCount, sequential.DirFileCount) || !maps.Equal(concurrent.DirFileSize, sequential.DirFileSize) {
+			t.Errorf("%d workers: per-directory totals differ from one worker's", workers)
+		}
+		if !slices.Equal(concurrent.Files, sequential.Files) {
+			t.Errorf("%d workers: files differ from one worker's, or aren't in walk order", workers)
+		}
+	}
+}
+
+// BenchmarkScanWorkers scans a local tree, and the same tree with each image taking 100µs
+// more to process, like a stat over a network mount, where the workers pay off most
+func BenchmarkScanWorkers(b *testing.B) {
+	root := b.TempDir()
+	writeTree(b, root, 20, 25)
+	slowGroup := func(path string) string {
+		time.Sleep(100 * time.Microsecond)
+		return filepath.Dir(path)
+	}
+
+	workerCounts := slices.Compact(slices.Sorted(slices.Values([]int{1, 4, 16, runtime.NumCPU()})))
+	for _, slow := range []bool{false, true} {
+		for _, workers := range workerCounts {
+			opts := Options{MaxDepth: -1, Workers: workers}
+			if slow {
+				opts.GroupBy = slowGroup
+			}
+			b.Run(fmt.Sprintf("slow=%v/workers=%d", slow, workers), func(b *testing.B) {
+				for range b.N {
+					if _, err := Scan(root, opts); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	root := t.TempDir()
+	// One image at each level: root, root/l1, root/l1/l2 and root/l1/l2/l3
+	dir := root
+	for _, level := range []string{"", "l1", "l2", "l3"} {
+		dir = filepath.Join(dir, level)
+		writeImages(t, dir, 1)
+	}
+
+	for depth, want := range map[int]int{-1: 4, 0: 1, 1: 2, 2: 3, 3: 4, 10: 4} {
+		result, err := Scan(root, Options{MaxDepth: depth})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.TotalImages != want {
+			t.Errorf("MaxDepth %d: got %d images, want %d", depth, result.TotalImages, want)
+		}
+		if deepest := filepath.Join(root, "l1", "l2"); (result.DirFileCount[deepest] == 1) != (depth < 0 || depth >= 2) {
+			t.Errorf("MaxDepth %d: got %d images in l1/l2", depth, result.DirFileCount[deepest])
+		}
+	}
+}
+
+func TestUnreadableDirectory(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits don't make directories unreadable on Windows")
+	}
+	if os.Geteuid() == 0 {
+		t.Skip("root can read any directory")
+	}
+	root := t.TempDir()
+	writeImages(t, filepath.Join(root, "readable"), 2)
+	locked := filepath.Join(root, "locked")
+	writeImages(t, locked, 3)
+	if err := os.Chmod(locked, 0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(locked, 0o755) }) // so TempDir can remove it
+
+	var reported []string
+	result, err := Scan(root, Options{MaxDepth: -1, OnSkip: func(path string, err error) { reported = append(reported, path) }})
+	if err != nil {
+		t.Fatalf("the scan stopped at the unreadable directory: %v", err)
+	}
+	if result.TotalImages != 2 {
+		t.Errorf("got %d images, want the 2 readable ones", result.TotalImages)
+	}
+	if want := []string{locked}; !slices.Equal(result.Skipped, want) || !slices.Equal(reported, want) {
+		t.Errorf("skipped %q, reported %q; want %q", result.Skipped, reported, want)
+	}
+}
+
+// retainedBytes returns how much more heap is in use while the value returned by f is
+// still reachable than before f ran, after a garbage collection each time
+func retainedBytes(f func() any) uint64 {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	v := f()
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	runtime.KeepAlive(v)
+	if after.HeapAlloc < before.HeapAlloc {
+		return 0
+	}
+	return after.HeapAlloc - before.HeapAlloc
+}
+
+// BenchmarkScanTotalsOnly scans trees of growing size in 10 directories, keeping every file
+// and only the totals. The retained-B/op metric, the memory the result holds on to, grows with
+// the number of files in the first case and stays flat with TotalsOnly.
+func BenchmarkScanTotalsOnly(b *testing.B) {
+	for _, files := range []int{1000, 4000, 16000} {
+		root := b.TempDir()
+		writeTree(b, root, 10, files/10)
+		for _, totalsOnly := range []bool{false, true} {
+			b.Run(fmt.Sprintf("files=%d/totals-only=%v", files, totalsOnly), func(b *testing.B) {
+				opts := Options{MaxDepth: -1, Workers: 4, TotalsOnly: totalsOnly}
+				var retained uint64
+				for range b.N {
+					retained += retainedBytes(func() any {
+						result, err := Scan(root, opts)
+						if err != nil {
+							b.Fatal(err)
+						}
+						return result
+					})
+				}
+				b.ReportMetric(float64(retained)/float64(b.N), "retained-B/op")
+			})
+		}
+	}
+}
+
+func TestTotalsOnly(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, 6, 5)
+	full, err := Scan(root, Options{MaxDepth: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	totals, err := Scan(root, Options{MaxDepth: -1, TotalsOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if totals.TotalImages != full.TotalImages || totals.TotalSize != full.TotalSize || !maps.Equal(totals.Categories, full.Categories) {
+		t.Errorf("totals only: got %d images, %d bytes, %v; want %d, %d, %v",
+			totals.TotalImages, totals.TotalSize, totals.Categories, full.TotalImages, full.TotalSize, full.Categories)
+	}
+	if len(totals.Files) != 0 {
+		t.Errorf("totals only kept %d files", len(totals.Files))
+	}
+}
+
+// writePNG creates a blank w×h PNG at path
+func writePNG(t testing.TB, path string, w, h int) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMinDimensions(t *testing.T) {
+	root := t.TempDir()
+	writePNG(t, filepath.Join(root, "avatar.png"), 150, 150)
+	writePNG(t, filepath.Join(root, "photo.png"), 1200, 800)
+	writePNG(t, filepath.Join(root, "banner.png"), 1200, 100)
+	writePNG(t, filepath.Join(root, "exact.png"), 200, 200)
+	if err := os.WriteFile(filepath.Join(root, "broken.png"), []byte("not a png"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		opts Options
+		want []string
+	}{
+		{Options{}, []string{"avatar.png", "banner.png", "broken.png", "exact.png", "photo.png"}},
+		{Options{MinWidth: 200, MinHeight: 200}, []string{"broken.png", "exact.png", "photo.png"}},
+		{Options{MinWidth: 200}, []string{"banner.png", "broken.png", "exact.png", "photo.png"}},
+		{Options{MinHeight: 201, SkipUnsized: true}, []string{"photo.png"}},
+		{Options{SkipUnsized: true}, []string{"avatar.png", "banner.png", "broken.png", "exact.png", "photo.png"}}, // only applies with a minimum
+	}
+	for _, tt := range tests {
+		result, err := Scan(root, tt.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		var size int64
+		for _, file := range result.Files {
+			got = append(got, filepath.Base(file.Path))
+			size += file.Size
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) || result.TotalImages != len(tt.want) || result.TotalSize != size {
+			t.Errorf("%+v: got %q, %d images of %d bytes, want %q and totals to match", tt.opts, got, result.TotalImages, result.TotalSize, tt.want)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package scanner
+
+import "context"
+
+// ScanStream is ScanContext for callers that handle each image as it is found, like a
+// progress display. Every image that passes the filters is sent on the first channel,
+// which is closed once the scan is done; the second channel then receives the error
+// ScanContext returned, if any, and is closed as well. Images arrive in the order the
+// workers finish them, not in walk order, and the full file list isn't kept, as with
+// opts.TotalsOnly. opts.OnImage, if set, is still called before each image is sent.
+//
+// The caller must drain the image channel or cancel ctx; after ctx is cancelled, images
+// still in flight are dropped and ctx's error is delivered.
+func ScanStream(ctx context.Context, root string, opts Options) (<-chan ImageFile, <-chan error) {
+	images := make(chan ImageFile)
+	errs := make(chan error, 1)
+	onImage := opts.OnImage
+	opts.OnImage = func(file ImageFile) {
+		if onImage != nil {
+			onImage(file)
+		}
+		select {
+		case images <- file:
+		case <-ctx.Done():
+		}
+	}
+	opts.TotalsOnly = true
+
+	go func() {
+		defer close(errs)
+		_, err := ScanContext(ctx, root, opts)
+		close(images)
+		if err != nil {
+			errs <- err
+		}
+	}()
+	return images, errs
+}
@@ -0,0 +1,78 @@
+package scanner
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// drain collects every image sent on images, then the error sent on errs
+func drain(images <-chan ImageFile, errs <-chan error) ([]ImageFile, error) {
+	var files []ImageFile
+	for file := range images {
+		files = append(files, file)
+	}
+	return files, <-errs
+}
+
+func byFilePath(a, b ImageFile) int { return cmp.Compare(a.Path, b.Path) }
+
+func TestScanStreamMatchesScan(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, 12, 9)
+	writeImages(t, root, 3)
+
+	for _, workers := range []int{1, 4} {
+		opts := Options{MaxDepth: -1, Workers: workers}
+		batch, err := Scan(root, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		streamed, err := drain(ScanStream(context.Background(), root, opts))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := slices.SortedFunc(slices.Values(batch.Files), byFilePath)
+		if got := slices.SortedFunc(slices.Values(streamed), byFilePath); !slices.Equal(got, want) {
+			t.Errorf("%d workers: got %d images streamed, want the %d of Scan", workers, len(got), len(want))
+		}
+	}
+}
+
+func TestScanStreamOnImage(t *testing.T) {
+	root := t.TempDir()
+	writeImages(t, root, 5)
+	seen := 0
+	files, err := drain(ScanStream(context.Background(), root, Options{OnImage: func(ImageFile) { seen++ }}))
+	if err != nil || len(files) != 5 || seen != 5 {
+		t.Errorf("got %d images, %v, OnImage called %d times, want 5 each", len(files), err, seen)
+	}
+}
+
+func TestScanStreamCancel(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, 10, 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	images, errs := ScanStream(ctx, root, Options{MaxDepth: -1, Workers: 4})
+	<-images
+	cancel()
+	rest, err := drain(images, errs)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+	if len(rest) >= 99 {
+		t.Errorf("got all %d remaining images after cancelling", len(rest))
+	}
+}
+
+func TestScanStreamError(t *testing.T) {
+	files, err := drain(ScanStream(context.Background(), filepath.Join(t.TempDir(), "missing"), Options{}))
+	if err == nil || len(files) != 0 {
+		t.Errorf("missing root: got %d images, %v, want an error", len(files), err)
+	}
+}
@@ -0,0 +1,33 @@
+package scanner
+
+import "os"
+
+// dirSet records the real directories a scan has entered, so that following symlinks
+// never enters the same directory twice and symlink cycles terminate
+type dirSet struct {
+	keys  map[fileKey]bool
+	infos []os.FileInfo // fallback for platforms without file identifiers
+}
+
+func newDirSet() *dirSet {
+	return &dirSet{keys: make(map[fileKey]bool)}
+}
+
+// add records the directory described by info and reports whether it was new
+func (s *dirSet) add(info os.FileInfo) bool {
+	if key, ok := fileKeyOf(info); ok {
+		if s.keys[key] {
+			return false
+		}
+		s.keys[key] = true
+		return true
+	}
+
+	for _, seen := range s.infos {
+		if os.SameFile(seen, info) {
+			return false
+		}
+	}
+	s.infos = append(s.infos, info)
+	return true
+}
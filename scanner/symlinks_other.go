@@ -0,0 +1,12 @@
+//go:build !unix
+
+package scanner
+
+import "os"
+
+// fileKey is unused on platforms without inode numbers; dirSet falls back to os.SameFile
+type fileKey struct{}
+
+func fileKeyOf(info os.FileInfo) (fileKey, bool) {
+	return fileKey{}, false
+}
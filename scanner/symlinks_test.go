@@ -0,0 +1,55 @@
+package scanner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// symlink creates a symbolic link at link pointing to target, skipping the test where the
+// platform or user can't create one
+func symlink(t *testing.T, target, link string) {
+	t.Helper()
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("can't create symlinks: %v", err)
+	}
+}
+
+func TestSymlinkLoop(t *testing.T) {
+	root := t.TempDir()
+	writeImages(t, filepath.Join(root, "a", "b"), 1)
+	symlink(t, root, filepath.Join(root, "a", "b", "back-to-root"))
+	symlink(t, filepath.Join(root, "a"), filepath.Join(root, "a", "b", "back-to-a"))
+	symlink(t, filepath.Join(root, "self"), filepath.Join(root, "self")) // a link to itself
+
+	for _, follow := range []bool{false, true} {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		result, err := ScanContext(ctx, root, Options{MaxDepth: -1, FollowSymlinks: follow})
+		cancel()
+		if err != nil {
+			t.Fatalf("follow symlinks %v: %v", follow, err)
+		}
+		if result.TotalImages != 1 {
+			t.Errorf("follow symlinks %v: got %d images, want the single one counted once", follow, result.TotalImages)
+		}
+	}
+}
+
+func TestSymlinkedDirectory(t *testing.T) {
+	root, elsewhere := t.TempDir(), t.TempDir()
+	writeImages(t, root, 1)
+	writeImages(t, elsewhere, 2)
+	symlink(t, elsewhere, filepath.Join(root, "linked"))
+
+	for follow, want := range map[bool]int{false: 1, true: 3} {
+		result, err := Scan(root, Options{MaxDepth: -1, FollowSymlinks: follow})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.TotalImages != want {
+			t.Errorf("follow symlinks %v: got %d images, want %d", follow, result.TotalImages, want)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+//go:build unix
+
+package scanner
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileKey identifies a file by its device and inode numbers
+type fileKey struct {
+	dev uint64
+	ino uint64
+}
+
+// fileKeyOf returns the device and inode of the file described by info
+func fileKeyOf(info os.FileInfo) (fileKey, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileKey{}, false
+	}
+	return fileKey{dev: uint64(stat.Dev), ino: uint64(stat.Ino)}, true
+}
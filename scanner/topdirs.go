@@ -0,0 +1,102 @@
+package scanner
+
+import (
+	"container/heap"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// topN keeps the n best of the values offered to it, before ranking a value ahead of
+// another. The worst kept value is at the root of the heap, ready to be replaced.
+type topN[T any] struct {
+	n      int
+	before func(a, b T) bool
+	items  []T
+}
+
+func (t *topN[T]) Len() int           { return len(t.items) }
+func (t *topN[T]) Less(i, j int) bool { return t.before(t.items[j], t.items[i]) }
+func (t *topN[T]) Swap(i, j int)      { t.items[i], t.items[j] = t.items[j], t.items[i] }
+func (t *topN[T]) Push(x any)         { t.items = append(t.items, x.(T)) }
+func (t *topN[T]) Pop() any {
+	last := t.items[len(t.items)-1]
+	t.items = t.items[:len(t.items)-1]
+	return last
+}
+
+// offer keeps v if it is among the n best so far
+func (t *topN[T]) offer(v T) {
+	switch {
+	case len(t.items) < t.n:
+		heap.Push(t, v)
+	case t.before(v, t.items[0]):
+		t.items[0] = v
+		heap.Fix(t, 0)
+	}
+}
+
+// dirTracker tells when the walk is done with a directory, for Options.TopDirs. The walk
+// is depth-first, so once it reports an image outside a directory, no more images directly
+// inside it come; the directory is finished when the workers have returned all of its
+// images too. It is shared by the walk goroutine and the one merging the results.
+type dirTracker struct {
+	mu       sync.Mutex
+	open     []string       // directories the walk is inside of, outermost first
+	pending  map[string]int // images sent to the workers and not yet merged, per directory
+	finished []string       // directories left by the walk with nothing pending, not yet taken
+}
+
+func newDirTracker() *dirTracker {
+	return &dirTracker{pending: make(map[string]int)}
+}
+
+// isWithinDir reports whether path is dir or lies below it
+func isWithinDir(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
+
+// enter records that the walk found an image directly inside dir, leaving the open
+// directories dir isn't inside of
+func (t *dirTracker) enter(dir string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for len(t.open) > 0 && !isWithinDir(dir, t.open[len(t.open)-1]) {
+		t.leave(t.open[len(t.open)-1])
+		t.open = t.open[:len(t.open)-1]
+	}
+	if len(t.open) == 0 || t.open[len(t.open)-1] != dir {
+		t.open = append(t.open, dir)
+	}
+	t.pending[dir]++
+}
+
+// leave marks dir as finished if none of its images are pending; t.mu must be held
+func (t *dirTracker) leave(dir string) {
+	if t.pending[dir] == 0 {
+		delete(t.pending, dir)
+		t.finished = append(t.finished, dir)
+	}
+}
+
+// merged records that an image directly inside dir was merged into the result, or dropped
+func (t *dirTracker) merged(dir string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending[dir]--
+	for _, open := range t.open {
+		if open == dir {
+			return
+		}
+	}
+	t.leave(dir)
+}
+
+// take returns the directories finished since the last call
+func (t *dirTracker) take() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	finished := t.finished
+	t.finished = nil
+	return finished
+}
@@ -0,0 +1,166 @@
+package scanner
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/metrics"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTopN(t *testing.T) {
+	top := &topN[int]{n: 3, before: func(a, b int) bool { return a > b }}
+	for _, v := range []int{5, 1, 9, 3, 9, 7, 2} {
+		top.offer(v)
+	}
+	got := slices.Sorted(slices.Values(top.items))
+	if want := []int{7, 9, 9}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestDirTracker(t *testing.T) {
+	a, ab, c := filepath.FromSlash("/r/a"), filepath.FromSlash("/r/a/b"), filepath.FromSlash("/r/c")
+	tracker := newDirTracker()
+	tracker.enter(a)
+	tracker.enter(ab)
+	tracker.merged(a)
+	if got := tracker.take(); got != nil {
+		t.Errorf("inside a/b: got %q finished, want none while the walk is below a", got)
+	}
+
+	// Leaving a for c finishes a/b and a, but a/b still has an image with the workers
+	tracker.enter(c)
+	if got := tracker.take(); !slices.Equal(got, []string{a}) {
+		t.Errorf("entering c: got %q finished, want a", got)
+	}
+	tracker.merged(ab)
+	if got := tracker.take(); !slices.Equal(got, []string{ab}) {
+		t.Errorf("after merging a/b: got %q finished, want a/b", got)
+	}
+	tracker.merged(c)
+	if got := tracker.take(); got != nil {
+		t.Errorf("c is still open: got %q finished", got)
+	}
+}
+
+// writeRankedTree creates dirs directories below root, nested a few levels deep, holding
+// from 1 to 7 images of varying size, so the rankings have ties and clear winners
+func writeRankedTree(t testing.TB, root string, dirs int) {
+	t.Helper()
+	for d := range dirs {
+		dir := filepath.Join(root, fmt.Sprintf("dir%d", d%10), fmt.Sprintf("sub%d", d))
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		for i := range 1 + d%7 {
+			content := make([]byte, 1+(d*31+i*7)%101)
+			if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("img%d.jpg", i)), content, 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestTopDirsMatchFullRanking(t *testing.T) {
+	root := t.TempDir()
+	writeRankedTree(t, root, 60)
+	writeImages(t, root, 3)
+
+	full, err := Scan(root, Options{MaxDepth: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantCounts := SortDirCounts(full.DirFileCount)[:5]
+	wantSizes := SortDirSizes(full.DirFileSize)[:5]
+	for _, workers := range []int{1, 4} {
+		top, err := Scan(root, Options{MaxDepth: -1, Workers: workers, TotalsOnly: true, TopDirs: 5})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := SortDirCounts(top.DirFileCount); !slices.Equal(got, wantCounts) {
+			t.Errorf("%d workers: got counts %v, want %v", workers, got, wantCounts)
+		}
+		if got := SortDirSizes(top.DirFileSize); !slices.Equal(got, wantSizes) {
+			t.Errorf("%d workers: got sizes %v, want %v", workers, got, wantSizes)
+		}
+		if top.TotalImages != full.TotalImages || top.TotalSize != full.TotalSize || !maps.Equal(top.Categories, full.Categories) {
+			t.Errorf("%d workers: totals differ from the full scan's", workers)
+		}
+	}
+}
+
+// peakHeapBytes returns the most heap in use while f runs, sampled every millisecond,
+// beyond what was in use before it, after a garbage collection
+func peakHeapBytes(f func() any) uint64 {
+	const heapObjects = "/memory/classes/heap/objects:bytes"
+	read := func(sample []metrics.Sample) uint64 {
+		metrics.Read(sample)
+		return sample[0].Value.Uint64()
+	}
+	runtime.GC()
+	base := read([]metrics.Sample{{Name: heapObjects}})
+
+	var peak uint64
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		sample := []metrics.Sample{{Name: heapObjects}}
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+		for {
+			peak = max(peak, read(sample))
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	v := f()
+	close(done)
+	wg.Wait()
+	runtime.KeepAlive(v)
+	if peak < base {
+		return 0
+	}
+	return peak - base
+}
+
+// BenchmarkScanTopDirs scans a wide tree of one image per directory, keeping every
+// directory's counts and only the TopDirs ranking. The peak-heap-B/op metric, the most memory
+// in use during the scan, counts the walk's garbage as well, so TopDirs saves the gap between
+// the two cases; retained-B/op, the memory the result holds on to, grows with the number of
+// directories in the first case and stays flat with TopDirs.
+func BenchmarkScanTopDirs(b *testing.B) {
+	for _, dirs := range []int{1000, 4000} {
+		root := b.TempDir()
+		writeTree(b, root, dirs, 1)
+		for _, top := range []int{0, 10} {
+			b.Run(fmt.Sprintf("dirs=%d/top=%d", dirs, top), func(b *testing.B) {
+				opts := Options{MaxDepth: -1, Workers: 4, TotalsOnly: top > 0, TopDirs: top}
+				scan := func() any {
+					result, err := Scan(root, opts)
+					if err != nil {
+						b.Fatal(err)
+					}
+					return result
+				}
+				var peak, retained uint64
+				for range b.N {
+					peak += peakHeapBytes(scan)
+					retained += retainedBytes(scan)
+				}
+				b.ReportMetric(float64(peak)/float64(b.N), "peak-heap-B/op")
+				b.ReportMetric(float64(retained)/float64(b.N), "retained-B/op")
+			})
+		}
+	}
+}
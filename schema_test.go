@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// topLevelSchema returns the properties and required keys of the schema printed by -print-schema
+func topLevelSchema(t *testing.T) (properties map[string]json.RawMessage, required []string) {
+	t.Helper()
+	stdout, stderr, code := runCLI(t, "-print-schema")
+	if code != exitOK {
+		t.Fatalf("exit code %d, stderr:\n%s", code, stderr)
+	}
+	var schema struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+		Required   []string                   `json:"required"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &schema); err != nil {
+		t.Fatalf("decoding %q: %v", stdout, err)
+	}
+	return schema.Properties, schema.Required
+}
+
+func TestSchemaVersion(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "a.jpg"), "image")
+
+	stdout, stderr, code := runCLI(t, "-format", "json", root)
+	if code != exitOK {
+		t.Fatalf("exit code %d, stderr:\n%s", code, stderr)
+	}
+	var result map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("decoding %q: %v", stdout, err)
+	}
+	if got := string(result["schema_version"]); got != "1" {
+		t.Errorf("schema_version: got %q, want 1", got)
+	}
+
+	properties, required := topLevelSchema(t)
+	for key := range result {
+		if _, ok := properties[key]; !ok {
+			t.Errorf("key %q missing from the schema", key)
+		}
+	}
+	for _, key := range required {
+		if _, ok := result[key]; !ok {
+			t.Errorf("required key %q missing from the result", key)
+		}
+	}
+	var version struct{ Const int }
+	if err := json.Unmarshal(properties["schema_version"], &version); err != nil || version.Const != resultSchemaVersion {
+		t.Errorf("schema_version schema: got %s, want a const of %d", properties["schema_version"], resultSchemaVersion)
+	}
+}
+
+func TestSchemaMatchesResult(t *testing.T) {
+	// Every field of ScanResult is in the schema under its JSON name, and nothing else is
+	data, err := json.Marshal(ScanResult{
+		Roots: []RootTotal{{}}, SkippedPaths: []string{""}, InvalidNames: []InvalidName{{}}, ZeroByte: []string{""},
+		Sampled: true, Months: []MonthCount{{}}, Locations: []LocationCount{{}}, NoGPS: 1,
+		Duplicates: []DuplicateGroup{{}}, NameDupes: []NameGroup{{}}, EmptyDirs: []EmptyDir{{}},
+		Corrupt: []BadImage{{}}, Unverifiable: []string{""}, Mismatches: []TypeMismatch{{}},
+		Rotated: []RotatedImage{{}}, NoOrientation: []string{""}, Screenshots: []Screenshot{{}},
+		LivePhotos: []LiveGroup{{}}, Similar: []SimilarGroup{{}}, Manifest: &ManifestCheck{},
+		Compare: &CompareResult{}, Largest: []ImageFile{{}}, SortPreviews: []SortPreview{{}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var full map[string]json.RawMessage
+	if err := json.Unmarshal(data, &full); err != nil {
+		t.Fatal(err)
+	}
+	properties, _ := topLevelSchema(t)
+	got, want := slices.Sorted(maps.Keys(properties)), slices.Sorted(maps.Keys(full))
+	if !slices.Equal(got, want) {
+		t.Errorf("schema properties: got %q, want %q", got, want)
+	}
+}
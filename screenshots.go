@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// Common phone, tablet and monitor resolutions, shorter side first, so a screenshot taken
+// in either orientation matches
+var screenResolutions = [][2]int{
+	{750, 1334}, {828, 1792}, {1125, 2436}, {1170, 2532}, {1179, 2556}, {1242, 2208}, // iPhone
+	{1242, 2688}, {1284, 2778}, {1290, 2796},
+	{720, 1280}, {1080, 1920}, {1080, 2340}, {1080, 2400}, {1440, 2560}, {1440, 3120}, // Android
+	{1440, 3200},
+	{1536, 2048}, {1668, 2388}, {2048, 2732}, // iPad
+	{768, 1366}, {800, 1280}, {900, 1440}, {1050, 1680}, {1200, 1920}, {1600, 2560}, // monitors
+	{1800, 2880}, {2160, 3840},
+}
+
+// File name patterns screenshot tools use, matched against the lowercase name
+var screenshotNames = []string{"screenshot*", "screen shot *", "screen_shot*", "screen-shot*"}
+
+// Score each screenshot signal adds; -screenshot-threshold is compared with their sum
+const (
+	screenResolutionScore = 2
+	screenshotNameScore   = 2
+	pngScore              = 1
+	noCameraScore         = 1
+)
+
+// Screenshot is an image the -screenshots heuristics flag, with the signals that added up
+// to its score
+type Screenshot struct {
+	Path    string   `json:"path"`
+	Score   int      `json:"score"`
+	Reasons []string `json:"reasons"`
+}
+
+// hasCameraTags reports whether the image at path has EXIF tags naming the camera that
+// took it, which screenshots lack
+func hasCameraTags(path string) bool {
+	if !exifExtensions[strings.ToLower(filepath.Ext(path))] {
+		return false
+	}
+	x, _ := readExif(path)
+	if x == nil {
+		return false
+	}
+	_, makeErr := x.Get(exif.Make)
+	_, modelErr := x.Get(exif.Model)
+	return makeErr == nil || modelErr == nil
+}
+
+// screenshotScore adds up the screenshot signals of file: a screen resolution, a
+// screenshot tool's file name, the PNG format and the lack of camera tags
+func screenshotScore(file ImageFile) Screenshot {
+	shot := Screenshot{Path: file.Path}
+	if width, height, err := imageDimensions(file.Path); err == nil {
+		if slices.Contains(screenResolutions, [2]int{min(width, height), max(width, height)}) {
+			shot.Score += screenResolutionScore
+			shot.Reasons = append(shot.Reasons, fmt.Sprintf("screen resolution %dx%d", width, height))
+		}
+	}
+	name := strings.ToLower(filepath.Base(file.Path))
+	for _, pattern := range screenshotNames {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			shot.Score += screenshotNameScore
+			shot.Reasons = append(shot.Reasons, "screenshot name")
+			break
+		}
+	}
+	if file.Ext == ".png" {
+		shot.Score += pngScore
+		shot.Reasons = append(shot.Reasons, "PNG")
+	}
+	if !hasCameraTags(file.Path) {
+		shot.Score += noCameraScore
+		shot.Reasons = append(shot.Reasons, "no camera tags")
+	}
+	return shot
+}
+
+// findScreenshots scores every image with screenshotScore, using the given number of worker
+// goroutines, and returns those scoring at least threshold in the order of files
+func findScreenshots(files []ImageFile, workers, threshold int) []Screenshot {
+	if workers < 1 {
+		workers = 1
+	}
+
+	// Each worker writes only the entries for the indexes it receives
+	shots := make([]Screenshot, len(files))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				shots[i] = screenshotScore(files[i])
+			}
+		}()
+	}
+	for i := range files {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	var flagged []Screenshot
+	for _, shot := range shots {
+		if shot.Score >= threshold {
+			flagged = append(flagged, shot)
+		}
+	}
+	return flagged
+}
+
+// printScreenshots prints the images flagged as likely screenshots and why
+func printScreenshots(w io.Writer, shots []Screenshot) {
+	fmt.Fprintln(w, "\nLikely screenshots:")
+	for _, shot := range shots {
+		fmt.Fprintf(w, "File: %s | Score: %d | Signals: %s\n", pathText(shot.Path), shot.Score, strings.Join(shot.Reasons, ", "))
+	}
+	fmt.Fprintf(w, "%d likely screenshots\n", len(shots))
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// pngBytes returns a blank w×h PNG
+func pngBytes(t testing.TB, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// screenshotTree writes images hitting each of the screenshot heuristics, or none of them
+func screenshotTree(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	camera := []exifEntry{asciiEntry(0x10f, "Canon"), asciiEntry(0x110, "EOS 5D")} // Make, Model
+	files := map[string][]byte{
+		"Screenshot_20230101.png":          pngBytes(t, 8, 8),
+		"phone.png":                        pngBytes(t, 1170, 2532),
+		"landscape.png":                    pngBytes(t, 2560, 1440),
+		"Screen Shot 2020-01-01 at 10.jpg": exifJPEG(t, 8, 8, camera, nil),
+		"camera.jpg":                       exifJPEG(t, 8, 8, camera, nil),
+		"plain.jpg":                        jpegBytes(t, 8, 8),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestScreenshotScore(t *testing.T) {
+	dir := screenshotTree(t)
+	tests := map[string]Screenshot{
+		"Screenshot_20230101.png":          {Score: 4, Reasons: []string{"screenshot name", "PNG", "no camera tags"}},
+		"phone.png":                        {Score: 4, Reasons: []string{"screen resolution 1170x2532", "PNG", "no camera tags"}},
+		"landscape.png":                    {Score: 4, Reasons: []string{"screen resolution 2560x1440", "PNG", "no camera tags"}},
+		"Screen Shot 2020-01-01 at 10.jpg": {Score: 2, Reasons: []string{"screenshot name"}},
+		"camera.jpg":                       {Score: 0},
+		"plain.jpg":                        {Score: 1, Reasons: []string{"no camera tags"}},
+	}
+	for _, file := range scanFiles(t, dir) {
+		want := tests[filepath.Base(file.Path)]
+		got := screenshotScore(file)
+		if got.Path != file.Path || got.Score != want.Score || !slices.Equal(got.Reasons, want.Reasons) {
+			t.Errorf("%s: got %d for %q, want %d for %q", filepath.Base(file.Path), got.Score, got.Reasons, want.Score, want.Reasons)
+		}
+	}
+}
+
+func TestFindScreenshots(t *testing.T) {
+	dir := screenshotTree(t)
+	files := scanFiles(t, dir)
+	for threshold, want := range map[int][]string{
+		3: {"Screenshot_20230101.png", "landscape.png", "phone.png"},
+		2: {"Screen Shot 2020-01-01 at 10.jpg", "Screenshot_20230101.png", "landscape.png", "phone.png"},
+		5: nil,
+	} {
+		var got []string
+		for _, shot := range findScreenshots(files, 4, threshold) {
+			got = append(got, filepath.Base(shot.Path))
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, want) {
+			t.Errorf("threshold %d: got %q, want %q", threshold, got, want)
+		}
+	}
+
+	result := runJSON(t, "-screenshots", "-screenshot-threshold", "2", dir)
+	if len(result.Screenshots) != 4 || result.TotalImages != 6 {
+		t.Errorf("-screenshots: got %d of %d images flagged, want 4 of 6", len(result.Screenshots), result.TotalImages)
+	}
+}
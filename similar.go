@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"math/bits"
+	"sync"
+)
+
+// dHash grid: each row of dHashWidth samples yields dHashWidth-1 bits, for a 64-bit hash
+const (
+	dHashWidth  = 9
+	dHashHeight = 8
+)
+
+// SimilarGroup is a set of images that look alike according to their perceptual hashes
+type SimilarGroup struct {
+	Files []ImageFile `json:"files"`
+}
+
+// dHash computes the difference hash of img: it shrinks the image to a 9x8 grayscale grid
+// by averaging, then sets one bit per pair of horizontally adjacent cells, depending on
+// whether the left one is brighter. Re-encoding, resizing and small crops barely change it.
+func dHash(img image.Image) uint64 {
+	bounds := img.Bounds()
+	var grid [dHashHeight][dHashWidth]float64
+	for y := 0; y < dHashHeight; y++ {
+		y0 := bounds.Min.Y + y*bounds.Dy()/dHashHeight
+		y1 := max(bounds.Min.Y+(y+1)*bounds.Dy()/dHashHeight, y0+1)
+		for x := 0; x < dHashWidth; x++ {
+			x0 := bounds.Min.X + x*bounds.Dx()/dHashWidth
+			x1 := max(bounds.Min.X+(x+1)*bounds.Dx()/dHashWidth, x0+1)
+
+			var sum float64
+			for py := y0; py < y1; py++ {
+				for px := x0; px < x1; px++ {
+					r, g, b, _ := img.At(px, py).RGBA()
+					sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+				}
+			}
+			grid[y][x] = sum / float64((y1-y0)*(x1-x0))
+		}
+	}
+
+	var hash uint64
+	for y := 0; y < dHashHeight; y++ {
+		for x := 0; x < dHashWidth-1; x++ {
+			hash <<= 1
+			if grid[y][x] > grid[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// findSimilar decodes every image with a Go decoder using the given number of worker
+// goroutines, hashes it with dHash, and groups images whose hashes differ in at most
+// threshold bits, transitively. Files that can't be decoded are skipped. Every pair of
+// images is compared, so this is quadratic in the number of images.
+func findSimilar(files []ImageFile, workers, threshold int) []SimilarGroup {
+	if workers < 1 {
+		workers = 1
+	}
+
+	// Each worker writes only the entries for the indexes it receives
+	hashes := make([]uint64, len(files))
+	hashed := make([]bool, len(files))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				img, err := loadImage(files[i].Path)
+				if err != nil {
+					continue
+				}
+				hashes[i], hashed[i] = dHash(img), true
+			}
+		}()
+	}
+
+	for i, file := range files {
+		if decodableExtensions[file.Ext] {
+			indexes <- i
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	// Union-find over the hashed images, so similarity chains end up in one group
+	parent := make([]int, len(files))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i := range files {
+		if !hashed[i] {
+			continue
+		}
+		for j := i + 1; j < len(files); j++ {
+			if hashed[j] && bits.OnesCount64(hashes[i]^hashes[j]) <= threshold {
+				parent[find(j)] = find(i)
+			}
+		}
+	}
+
+	// Groups come out in the order of their first file
+	var groups []SimilarGroup
+	groupOf := make(map[int]int)
+	for i, file := range files {
+		if !hashed[i] {
+			continue
+		}
+		root := find(i)
+		g, ok := groupOf[root]
+		if !ok {
+			g = len(groups)
+			groupOf[root] = g
+			groups = append(groups, SimilarGroup{})
+		}
+		groups[g].Files = append(groups[g].Files, file)
+	}
+
+	similar := groups[:0]
+	for _, group := range groups {
+		if len(group.Files) > 1 {
+			similar = append(similar, group)
+		}
+	}
+	return similar
+}
+
+// printSimilar prints each group of visually similar images
+func printSimilar(w io.Writer, groups []SimilarGroup) {
+	fmt.Fprintln(w, "\nSimilar images:")
+	for i, group := range groups {
+		fmt.Fprintf(w, "Group %d | Images: %d\n", i+1, len(group.Files))
+		for _, file := range group.Files {
+			fmt.Fprintf(w, "  %s\n", pathText(file.Path))
+		}
+	}
+	fmt.Fprintf(w, "%d groups of similar images\n", len(groups))
+}
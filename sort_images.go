@@ -0,0 +1,501 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kwdowicz/image_sorter/scanner"
+)
+
+// SortSummary counts what sortImages did with the files it was given
+type SortSummary struct {
+	Moved     int            // files copied or moved into dest (or that would have been, in a dry run)
+	Skipped   int            // files that could not be sorted
+	Identical int            // files skipped because an identical copy was already at the target
+	Renamed   int            // of the moved files, those given a new name to avoid a collision
+	Pruned    int            // source folders removed because moving left them empty
+	Buckets   map[string]int // files sorted into each bucket folder under dest
+	Planned   []PlannedMove  // with dryRun, the copies or moves that would have been made
+}
+
+// PlannedMove is a copy or move a dry run would have made
+type PlannedMove struct {
+	Source  string `json:"source"`
+	Target  string `json:"target"`
+	Renamed bool   `json:"renamed,omitempty"` // the target got a new name to avoid a collision
+}
+
+// SortPreview is what a -dry-run sort into Dest would have done
+type SortPreview struct {
+	Dest    string        `json:"dest"`
+	Op      string        `json:"op"` // "copy" or "move"
+	Moves   []PlannedMove `json:"moves"`
+	Folders []DirCount    `json:"folders"` // files per destination folder, most first
+}
+
+// newSortPreview collects the planned moves of a dry run into dest and counts them per
+// destination folder
+func newSortPreview(dest string, move bool, planned []PlannedMove) SortPreview {
+	preview := SortPreview{Dest: dest, Op: "copy", Moves: planned}
+	if move {
+		preview.Op = "move"
+	}
+	folderCount := make(map[string]int)
+	for _, pm := range planned {
+		folderCount[filepath.Dir(pm.Target)]++
+	}
+	preview.Folders = scanner.SortDirCounts(folderCount)
+	return preview
+}
+
+// printSortPreview prints the number of files a dry run would have put in each folder
+func printSortPreview(w io.Writer, preview SortPreview) {
+	fmt.Fprintf(w, "\nFiles that would be sorted into each folder:\n")
+	for _, dc := range preview.Folders {
+		fmt.Fprintf(w, "Folder: %s | Image Files: %s\n", pathText(dc.Path), formatInt(int64(dc.Count)))
+	}
+}
+
+// sortOptions controls how sortImages files images away
+type sortOptions struct {
+	bucket        func(file ImageFile) string // subfolder of dest a file belongs in, e.g. "jpg" or "2023/07"
+	move          bool                        // move files instead of copying them
+	dryRun        bool                        // only log the planned operations
+	skipIdentical bool                        // skip files whose content matches the file already at the target
+	confirm       func(plan sortPlan) error   // asked before anything is changed, unless dryRun; an error stops the sort
+	flatten       bool                        // put files directly in their bucket instead of recreating their folders
+	roots         []string                    // scan roots, whose subfolders are recreated under the bucket unless flatten is set
+	nameByDir     bool                        // on a collision, first try naming the file after its folder below the root
+	keepPairs     bool                        // keep files differing only in extension within a folder together, see pairKey
+	prune         bool                        // with move, remove the source folders below the roots left empty, see pruneEmptyDirs
+	live          []LiveGroup                 // stills of a Live Photo or burst share the bucket of the first of them planned
+}
+
+// sortPlan is what sortImages is about to do, for the confirmation prompt
+type sortPlan struct {
+	Files   int // files that will be copied or moved
+	Renamed int // of those, files that get a " (1)" style suffix to avoid a collision
+}
+
+// sortStep is the planned fate of a single file
+type sortStep struct {
+	file      ImageFile
+	bucket    string
+	target    string
+	identical bool // an identical copy is already at target, so the file is skipped
+}
+
+// extensionBucket sorts an image into a folder named after its lowercase extension, in its
+// canonical form so .JPG and .jpeg files share the jpg folder
+func extensionBucket(file ImageFile) string {
+	return strings.TrimPrefix(scanner.CanonicalExt(file.Ext), ".")
+}
+
+// pairKey returns what the files of a RAW+JPEG pair like IMG_100.CR2 and IMG_100.JPG have in
+// common: their folder and their name without the extension
+func pairKey(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path))
+}
+
+// pairLead returns the file of a pair whose bucket the others follow: the first with a
+// capture date in its metadata, as the modification times of RAW and JPEG files written
+// seconds apart can fall on either side of a month boundary
+func pairLead(pair []ImageFile) ImageFile {
+	for _, file := range pair {
+		if _, err := formatOf(file.Path).CaptureTime(file.Path); err == nil {
+			return file
+		}
+	}
+	return pair[0]
+}
+
+// flatBucket puts every image directly into dest
+func flatBucket(file ImageFile) string {
+	return ""
+}
+
+// resolvePath returns path made absolute, with symlinks resolved as far as it exists
+func resolvePath(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	// Resolve the deepest existing ancestor, as dest is often created by the sort itself
+	rest := ""
+	for dir := path; ; dir = filepath.Dir(dir) {
+		if real, err := filepath.EvalSymlinks(dir); err == nil {
+			return filepath.Join(real, rest), nil
+		}
+		if filepath.Dir(dir) == dir {
+			return path, nil
+		}
+		rest = filepath.Join(filepath.Base(dir), rest)
+	}
+}
+
+// isWithin reports whether path is dir or lies below it
+func isWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// checkDest returns an error if dest and one of the scan roots are the same directory or one
+// lies inside the other. Sorting into the scanned tree can overwrite sources and has the
+// sorted copies picked up again by the next scan, and a root inside dest can end up being
+// one of the bucket folders.
+func checkDest(dest string, roots []string) error {
+	dest, err := resolvePath(dest)
+	if err != nil {
+		return err
+	}
+	for _, root := range roots {
+		root, err := resolvePath(root)
+		if err != nil {
+			return err
+		}
+		switch {
+		case isWithin(dest, root):
+			return fmt.Errorf("%s is inside the scanned directory %s", dest, root)
+		case isWithin(root, dest):
+			return fmt.Errorf("the scanned directory %s is inside %s", root, dest)
+		}
+	}
+	return nil
+}
+
+// dateBucket sorts an image into a YYYY/MM folder by its capture date
+func dateBucket(file ImageFile) string {
+	t, ok := readCaptureDate(file.Path)
+	if !ok {
+		return "unknown"
+	}
+	return filepath.Join(t.Format("2006"), t.Format("01"))
+}
+
+// relativeDir returns the folder of path relative to the innermost root containing it, or ""
+// if no root does, like for paths read with -from-stdin
+func relativeDir(path string, roots []string) string {
+	best := ""
+	for _, root := range roots {
+		rel, err := filepath.Rel(root, filepath.Dir(path))
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if best == "" || len(rel) < len(best) {
+			best = rel
+		}
+	}
+	if best == "." {
+		return ""
+	}
+	return best
+}
+
+// planSort picks the target of each file under dest, resolving name collisions with files
+// already on disk and with the other files of this run. With opts.keepPairs set, the files
+// of a pair share the bucket of their pairLead and get the same suffix on a collision, so
+// IMG_100.CR2 and its IMG_100.JPG preview become IMG_100 (1).CR2 and IMG_100 (1).JPG side
+// by side. The stills of each of opts.live end up in the same bucket the same way, though
+// under their own names.
+func planSort(files []ImageFile, dest string, opts sortOptions) ([]sortStep, sortPlan) {
+	// Targets already claimed during this run and the source claiming them, so dry runs
+	// detect collisions too
+	taken := make(map[string]string)
+
+	// The files of each pair, planned together when the first of them comes up
+	var pairs map[string][]ImageFile
+	if opts.keepPairs {
+		pairs = make(map[string][]ImageFile)
+		for _, file := range files {
+			key := pairKey(file.Path)
+			pairs[key] = append(pairs[key], file)
+		}
+	}
+
+	// The Live Photo or burst of each still, and the bucket of each once planned
+	liveGroup := make(map[string]int)
+	for i, group := range opts.live {
+		for _, path := range group.Files {
+			liveGroup[path] = i
+		}
+	}
+	liveBuckets := make(map[int]string)
+
+	var plan sortPlan
+	steps := make([]sortStep, 0, len(files))
+	for _, file := range files {
+		group := []ImageFile{file}
+		if opts.keepPairs {
+			key := pairKey(file.Path)
+			if group = pairs[key]; group == nil {
+				continue // planned with the first file of its pair
+			}
+			delete(pairs, key)
+		}
+
+		bucket := opts.bucket(file)
+		if len(group) > 1 {
+			bucket = opts.bucket(pairLead(group))
+		}
+		if i, ok := liveGroup[file.Path]; ok {
+			if planned, ok := liveBuckets[i]; ok {
+				bucket = planned
+			} else {
+				liveBuckets[i] = bucket
+			}
+		}
+		targetDir := filepath.Join(dest, bucket)
+		if !opts.flatten {
+			targetDir = filepath.Join(targetDir, relativeDir(file.Path, opts.roots))
+		}
+		tag := ""
+		if opts.nameByDir {
+			tag = strings.ReplaceAll(relativeDir(file.Path, opts.roots), string(filepath.Separator), "-")
+		}
+
+		srcs := make([]string, len(group))
+		for i, member := range group {
+			srcs[i] = member.Path
+		}
+		targets, identical := resolveTargets(targetDir, srcs, tag, taken, opts.skipIdentical)
+		for i, member := range group {
+			steps = append(steps, sortStep{file: member, bucket: bucket, target: targets[i], identical: identical[i]})
+			if identical[i] {
+				continue
+			}
+			taken[targets[i]] = member.Path
+			plan.Files++
+			if filepath.Base(targets[i]) != filepath.Base(member.Path) {
+				plan.Renamed++
+			}
+		}
+	}
+	return steps, plan
+}
+
+// sortImages copies each image into the dest subfolder chosen by opts.bucket, or moves it
+// there when opts.move is set. Unless opts.flatten is set, the image's folder relative to its
+// scan root is recreated below the bucket. Name collisions get " (1)", " (2)", ... suffixes, or
+// first the name of that folder with opts.nameByDir, unless opts.skipIdentical is set and the
+// file at the target has the same content.
+// With opts.dryRun set the planned operations are only logged to out. Otherwise opts.confirm
+// is asked first, and its error returned if it declines. Failures on single files
+// are reported to stderr and counted as skipped rather than aborting the whole run.
+func sortImages(files []ImageFile, dest string, opts sortOptions, out io.Writer) (SortSummary, error) {
+	summary := SortSummary{Buckets: make(map[string]int)}
+	steps, plan := planSort(files, dest, opts)
+	if !opts.dryRun {
+		if opts.confirm != nil {
+			if err := opts.confirm(plan); err != nil {
+				return summary, err
+			}
+		}
+		if err := os.MkdirAll(dest, 0755); err != nil {
+			return summary, err
+		}
+	}
+
+	verb := "Copy"
+	if opts.move {
+		verb = "Move"
+	}
+
+	for _, step := range steps {
+		path, target, bucket := step.file.Path, step.target, step.bucket
+		if step.identical {
+			fmt.Fprintf(out, "Identical: %s already at %s\n", path, target)
+			summary.Identical++
+			continue
+		}
+
+		if opts.dryRun {
+			fmt.Fprintf(out, "Would %s: %s -> %s\n", strings.ToLower(verb), sanitizePath(path), sanitizePath(target))
+			renamed := filepath.Base(target) != filepath.Base(path)
+			summary.Moved++
+			summary.Buckets[bucket]++
+			summary.Planned = append(summary.Planned, PlannedMove{Source: path, Target: target, Renamed: renamed})
+			if renamed {
+				summary.Renamed++
+			}
+			continue
+		}
+
+		targetDir := filepath.Dir(target)
+		if err := os.MkdirAll(targetDir, 0755); err != nil {
+			logger.Error("creating folder failed", "path", targetDir, "err", err)
+			summary.Skipped++
+			continue
+		}
+
+		var err error
+		if opts.move {
+			err = moveFile(path, target)
+		} else {
+			err = copyFile(path, target)
+		}
+		if err != nil {
+			logger.Error("sorting failed", "path", path, "err", err)
+			summary.Skipped++
+			continue
+		}
+		fmt.Fprintf(out, "%s: %s -> %s\n", verb, sanitizePath(path), sanitizePath(target))
+		summary.Moved++
+		summary.Buckets[bucket]++
+		if filepath.Base(target) != filepath.Base(path) {
+			summary.Renamed++
+		}
+	}
+	if opts.move && opts.prune && !opts.dryRun {
+		summary.Pruned = pruneEmptyDirs(files, opts.roots, out)
+	}
+	return summary, nil
+}
+
+// pruneEmptyDirs removes the folders of files that are empty now, like after moving the
+// images out of them, then their parents that became empty in turn, logging each to out.
+// A root is never removed, nor is anything outside the roots. It returns how many folders
+// were removed.
+func pruneEmptyDirs(files []ImageFile, roots []string, out io.Writer) int {
+	// Deepest first, so a folder's empty subfolders are gone by the time it comes up
+	seen := make(map[string]bool)
+	var dirs []string
+	for _, file := range files {
+		if dir := filepath.Dir(file.Path); !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		return len(dirs[i]) > len(dirs[j])
+	})
+
+	pruned := 0
+	for _, dir := range dirs {
+		root := ""
+		for _, r := range roots {
+			if isWithin(dir, r) && len(r) > len(root) {
+				root = r
+			}
+		}
+		// os.Remove refuses folders with anything left in them, which ends the climb
+		for d := dir; root != "" && d != filepath.Clean(root) && isWithin(d, root); d = filepath.Dir(d) {
+			if err := os.Remove(d); err != nil {
+				break
+			}
+			fmt.Fprintf(out, "Pruned: %s\n", d)
+			pruned++
+		}
+	}
+	return pruned
+}
+
+// printPruned reports how many source folders a sort with opts pruned, if it was asked to
+func printPruned(out io.Writer, opts sortOptions, summary SortSummary) {
+	if opts.move && opts.prune && !opts.dryRun {
+		fmt.Fprintf(out, "Pruned %d empty directories\n", summary.Pruned)
+	}
+}
+
+// resolveTargets returns paths inside dir for the files at srcs that neither exist on disk
+// nor are in taken, appending the same suffix to each name before its extension: none at
+// first, then " (tag)" if tag isn't empty, then " (1)", " (2)", ... until all of them are
+// free. With skipIdentical set, an occupied candidate holding the same content as its
+// source is used instead, with identical set to true for it.
+func resolveTargets(dir string, srcs []string, tag string, taken map[string]string, skipIdentical bool) (targets []string, identical []bool) {
+	targets = make([]string, len(srcs))
+	identical = make([]bool, len(srcs))
+	for i := 0; ; i++ {
+		suffix := ""
+		switch {
+		case i == 0:
+		case tag != "" && i == 1:
+			suffix = fmt.Sprintf(" (%s)", tag)
+		case tag != "":
+			suffix = fmt.Sprintf(" (%d)", i-1) // numbered suffixes start over after the tagged name
+		default:
+			suffix = fmt.Sprintf(" (%d)", i)
+		}
+
+		free := true
+		for j, src := range srcs {
+			name := filepath.Base(src)
+			ext := filepath.Ext(name)
+			targets[j] = filepath.Join(dir, strings.TrimSuffix(name, ext)+suffix+ext)
+			identical[j] = false
+
+			claimedBy, claimed := taken[targets[j]]
+			onDisk := exists(targets[j])
+			if !claimed && !onDisk {
+				continue
+			}
+			if skipIdentical {
+				// In a dry run a claimed target isn't on disk yet, so compare with its source
+				occupant := targets[j]
+				if !onDisk {
+					occupant = claimedBy
+				}
+				if sameContent(src, occupant) {
+					identical[j] = true
+					continue
+				}
+			}
+			free = false
+			break
+		}
+		if free {
+			return targets, identical
+		}
+	}
+}
+
+// sameContent reports whether the files at a and b have the same size and SHA-256 hash
+func sameContent(a, b string) bool {
+	infoA, errA := os.Stat(a)
+	infoB, errB := os.Stat(b)
+	if errA != nil || errB != nil || infoA.Size() != infoB.Size() {
+		return false
+	}
+	hashA, errA := hashFile(a)
+	hashB, errB := hashFile(b)
+	return errA == nil && errB == nil && hashA == hashB
+}
+
+// exists reports whether anything is present at path
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// copyFile copies src to a new file at dst, keeping the permissions and modification time of src
+func copyFile(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	// O_EXCL so an existing file is never overwritten
+	outFile, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(outFile, in); err != nil {
+		outFile.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := outFile.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
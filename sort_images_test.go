@@ -0,0 +1,365 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// countFiles returns the number of regular files below dir
+func countFiles(t *testing.T, dir string) int {
+	t.Helper()
+	count := 0
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && d.Type().IsRegular() {
+			count++
+		}
+		return err
+	})
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestMoveWithSeveralSorts(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "a.jpg"), "a")
+	dest := t.TempDir()
+	_, _, code := runCLI(t, "-move", "-yes", "-sort-into", filepath.Join(dest, "ext"), "-sort-by-date", filepath.Join(dest, "date"), root)
+	if code != exitUsage {
+		t.Errorf("got exit code %d, want %d", code, exitUsage)
+	}
+	if countFiles(t, root) != 1 {
+		t.Error("the image was moved")
+	}
+}
+
+func TestSortAfterDeleteDupes(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "a.jpg"), "same")
+	writeFile(t, filepath.Join(root, "b.jpg"), "same")
+	os.Chtimes(filepath.Join(root, "b.jpg"), time.Now(), time.Now().Add(-time.Hour)) // b is kept
+	dest := filepath.Join(t.TempDir(), "sorted")
+
+	stdout, stderr, code := runCLI(t, "-q", "-delete-dupes", "-move", "-yes", "-sort-into", dest, root)
+	if code != exitOK {
+		t.Fatalf("exit code %d, stderr:\n%s", code, stderr)
+	}
+	if got := countFiles(t, dest); got != 1 {
+		t.Errorf("got %d files in %s, want 1\n%s", got, dest, stdout)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "jpg", "b.jpg")); err != nil {
+		t.Error("the kept copy wasn't sorted:", err)
+	}
+	if !strings.Contains(stdout, "Sorted 1 files into "+dest+" (0 skipped)") {
+		t.Errorf("the deleted copy was sorted too:\n%s", stdout)
+	}
+}
+
+func TestSortEveryFileFailing(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "a.jpg"), "a")
+	writeFile(t, filepath.Join(root, "b.jpg"), "b")
+	dest := t.TempDir()
+	writeFile(t, filepath.Join(dest, "jpg"), "a file where the bucket folder goes")
+
+	_, _, code := runCLI(t, "-q", "-move", "-yes", "-sort-into", dest, root)
+	if code != exitError {
+		t.Errorf("got exit code %d, want %d", code, exitError)
+	}
+	if countFiles(t, root) != 2 {
+		t.Error("images were moved")
+	}
+}
+
+func TestExtensionAliasesShareBucket(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a.JPG", "b.jpeg", "c.jpg", "d.TIF", "e.tiff"} {
+		writeFile(t, filepath.Join(root, name), name)
+	}
+	dest := filepath.Join(t.TempDir(), "sorted")
+	result := runJSON(t, "-yes", "-sort-into", dest, root)
+
+	if got := countFiles(t, filepath.Join(dest, "jpg")); got != 3 {
+		t.Errorf("got %d files in the jpg folder, want 3", got)
+	}
+	if got := countFiles(t, filepath.Join(dest, "tiff")); got != 2 {
+		t.Errorf("got %d files in the tiff folder, want 2", got)
+	}
+	counts := make(map[string]int)
+	for _, et := range result.Extensions {
+		counts[et.Ext] = et.Count
+	}
+	if counts[".jpg"] != 3 || counts[".tiff"] != 2 || len(counts) != 2 {
+		t.Errorf("got extension totals %v, want 3 .jpg and 2 .tiff", counts)
+	}
+}
+
+// listFiles returns the paths of the regular files below dir, relative to it with forward
+// slashes, in lexical order
+func listFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && d.Type().IsRegular() {
+			rel, _ := filepath.Rel(dir, path)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(files)
+	return files
+}
+
+func TestSortFlatten(t *testing.T) {
+	root := t.TempDir()
+	modTime := time.Date(2020, 5, 15, 12, 0, 0, 0, time.Local)
+	for _, name := range []string{"x.jpg", "a/x.jpg", "b/x.jpg"} {
+		writeFile(t, filepath.Join(root, name), name)
+		if err := os.Chtimes(filepath.Join(root, name), modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		// -sort-into flattens by default, renaming the colliding names
+		{[]string{"-sort-into"}, []string{"jpg/x (1).jpg", "jpg/x (2).jpg", "jpg/x.jpg"}},
+		{[]string{"-flatten=false", "-sort-into"}, []string{"jpg/a/x.jpg", "jpg/b/x.jpg", "jpg/x.jpg"}},
+		// -sort-by-date keeps the folders by default; these images go by their modification time
+		{[]string{"-sort-by-date"}, []string{"2020/05/a/x.jpg", "2020/05/b/x.jpg", "2020/05/x.jpg"}},
+		{[]string{"-flatten", "-sort-by-date"}, []string{"2020/05/x (1).jpg", "2020/05/x (2).jpg", "2020/05/x.jpg"}},
+	}
+	for _, tt := range tests {
+		dest := filepath.Join(t.TempDir(), "sorted")
+		args := append(append([]string{"-q", "-yes"}, tt.args...), dest, root)
+		if _, stderr, code := runCLI(t, args...); code != exitOK {
+			t.Fatalf("%q: exit code %d, stderr:\n%s", tt.args, code, stderr)
+		}
+		if got := listFiles(t, dest); !slices.Equal(got, tt.want) {
+			t.Errorf("%q: got %q, want %q", tt.args, got, tt.want)
+		}
+	}
+	if got := countFiles(t, root); got != 3 {
+		t.Errorf("got %d images left in the root, want all 3 copied rather than moved", got)
+	}
+}
+
+func TestCheckDest(t *testing.T) {
+	parent := t.TempDir()
+	root := filepath.Join(parent, "photos")
+	writeFile(t, filepath.Join(root, "a.jpg"), "a")
+	link := filepath.Join(parent, "link")
+	symlinked := os.Symlink(root, link) == nil
+
+	tests := []struct {
+		dest string
+		ok   bool
+	}{
+		{root, false},
+		{filepath.Join(root, "sorted"), false},
+		{filepath.Join(root, "sorted", "deeper"), false}, // not created yet
+		{filepath.Join(root, "..", "photos", "sorted"), false},
+		{parent, false}, // the root is inside dest
+		{filepath.Join(parent, "sorted"), true},
+		{filepath.Join(parent, "photos-sorted"), true}, // a sibling sharing the root's name as a prefix
+	}
+	for _, tt := range tests {
+		if err := checkDest(tt.dest, []string{root}); (err == nil) != tt.ok {
+			t.Errorf("checkDest(%s): got %v, want ok %v", tt.dest, err, tt.ok)
+		}
+	}
+
+	// A symlink to the root doesn't hide that dest is inside it
+	if symlinked && checkDest(filepath.Join(link, "sorted"), []string{root}) == nil {
+		t.Errorf("checkDest accepted %s through a symlink to the root", filepath.Join(link, "sorted"))
+	}
+}
+
+func TestSortIntoScannedTree(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "a.jpg"), "a")
+
+	for _, mode := range []string{"-sort-into", "-sort-by-date", "-consolidate"} {
+		_, stderr, code := runCLI(t, "-yes", "-move", mode, filepath.Join(root, "sorted"), root)
+		if code != exitUsage || !strings.Contains(stderr, "inside") {
+			t.Errorf("%s into the root: got exit code %d, stderr %q; want %d", mode, code, stderr, exitUsage)
+		}
+	}
+	if got := listFiles(t, root); !slices.Equal(got, []string{"a.jpg"}) {
+		t.Errorf("files in the root after the refused sorts: %q", got)
+	}
+}
+
+func TestSortKeepPairs(t *testing.T) {
+	root := t.TempDir()
+	// The RAW file and its JPEG preview were written on either side of a month boundary
+	for name, at := range map[string]time.Time{
+		"IMG_100.CR2":       time.Date(2020, 1, 31, 23, 59, 59, 0, time.Local),
+		"IMG_100.JPG":       time.Date(2020, 2, 1, 0, 0, 1, 0, time.Local),
+		"other/IMG_100.JPG": time.Date(2020, 2, 1, 0, 0, 1, 0, time.Local), // another folder, so no pair
+	} {
+		writeFile(t, filepath.Join(root, name), name)
+		if err := os.Chtimes(filepath.Join(root, name), at, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"-flatten", "-sort-by-date"}, []string{"2020/01/IMG_100.CR2", "2020/02/IMG_100 (1).JPG", "2020/02/IMG_100.JPG"}},
+		{[]string{"-flatten", "-keep-pairs", "-sort-by-date"}, []string{"2020/01/IMG_100.CR2", "2020/01/IMG_100.JPG", "2020/02/IMG_100.JPG"}},
+		// Extension folders split the pair regardless
+		{[]string{"-keep-pairs", "-sort-into"}, []string{"cr2/IMG_100.CR2", "jpg/IMG_100 (1).JPG", "jpg/IMG_100.JPG"}},
+	}
+	for _, tt := range tests {
+		dest := filepath.Join(t.TempDir(), "sorted")
+		args := append(append([]string{"-q", "-yes"}, tt.args...), dest, root)
+		if _, stderr, code := runCLI(t, args...); code != exitOK {
+			t.Fatalf("%q: exit code %d, stderr:\n%s", tt.args, code, stderr)
+		}
+		if got := listFiles(t, dest); !slices.Equal(got, tt.want) {
+			t.Errorf("%q: got %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestSortKeepPairsCollision(t *testing.T) {
+	root := t.TempDir()
+	at := time.Date(2020, 1, 15, 12, 0, 0, 0, time.Local)
+	for _, name := range []string{"IMG_100.CR2", "IMG_100.JPG"} {
+		writeFile(t, filepath.Join(root, name), name)
+		if err := os.Chtimes(filepath.Join(root, name), at, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dest := filepath.Join(t.TempDir(), "sorted")
+	writeFile(t, filepath.Join(dest, "2020", "01", "IMG_100.JPG"), "an earlier import")
+
+	// Only the JPEG's name is taken, but both files of the pair get the same suffix
+	if _, stderr, code := runCLI(t, "-q", "-yes", "-flatten", "-keep-pairs", "-sort-by-date", dest, root); code != exitOK {
+		t.Fatalf("exit code %d, stderr:\n%s", code, stderr)
+	}
+	want := []string{"2020/01/IMG_100 (1).CR2", "2020/01/IMG_100 (1).JPG", "2020/01/IMG_100.JPG"}
+	if got := listFiles(t, dest); !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// pruneTree writes a nested tree whose images all get moved out, leaving only notes.txt
+func pruneTree(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	for _, name := range []string{"top.jpg", "a/one.jpg", "a/b/c/two.jpg", "empty/deep/three.png", "keep/four.jpg", "keep/notes.txt"} {
+		writeFile(t, filepath.Join(root, name), name)
+	}
+	return root
+}
+
+func TestPruneEmpty(t *testing.T) {
+	root := pruneTree(t)
+	dest := filepath.Join(t.TempDir(), "sorted")
+	stdout, stderr, code := runCLI(t, "-yes", "-move", "-prune-empty", "-sort-into", dest, root)
+	if code != exitOK {
+		t.Fatalf("exit code %d, stderr:\n%s", code, stderr)
+	}
+	if !strings.Contains(stdout, "Pruned 5 empty directories") {
+		t.Errorf("output lacks the pruned count:\n%s", stdout)
+	}
+	// The root stays even though the move emptied it of images
+	if got, want := listFiles(t, root), []string{"keep/notes.txt"}; !slices.Equal(got, want) {
+		t.Errorf("source: got %q, want %q", got, want)
+	}
+	for _, dir := range []string{"a", "empty"} {
+		if _, err := os.Stat(filepath.Join(root, dir)); !os.IsNotExist(err) {
+			t.Errorf("%s: still there (%v), want it pruned", dir, err)
+		}
+	}
+	if got := countFiles(t, dest); got != 5 {
+		t.Errorf("got %d files sorted, want 5", got)
+	}
+}
+
+func TestPruneEmptyNotApplied(t *testing.T) {
+	for _, args := range [][]string{
+		{"-move", "-prune-empty", "-dry-run"},
+		{"-move"}, // without -prune-empty
+	} {
+		root := pruneTree(t)
+		dest := filepath.Join(t.TempDir(), "sorted")
+		if _, stderr, code := runCLI(t, append(append([]string{"-q", "-yes"}, args...), "-sort-into", dest, root)...); code != exitOK {
+			t.Fatalf("%q: exit code %d, stderr:\n%s", args, code, stderr)
+		}
+		for _, dir := range []string{"a/b/c", "empty/deep"} {
+			if _, err := os.Stat(filepath.Join(root, dir)); err != nil {
+				t.Errorf("%q: %s is gone: %v", args, dir, err)
+			}
+		}
+	}
+	if _, _, code := runCLI(t, "-prune-empty", "-sort-into", t.TempDir(), pruneTree(t)); code != exitUsage {
+		t.Errorf("-prune-empty without -move: got exit code %d, want %d", code, exitUsage)
+	}
+}
+
+func TestDryRunPreviewMatchesRun(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"x.jpg", "a/x.jpg", "b/x.jpg", "b/y.png", "c/d/z.gif"} {
+		writeFile(t, filepath.Join(root, name), name)
+	}
+	dest := filepath.Join(t.TempDir(), "sorted")
+	writeFile(t, filepath.Join(dest, "jpg", "x.jpg"), "an earlier copy")
+
+	result := runJSON(t, "-yes", "-move", "-dry-run", "-sort-into", dest, root)
+	if len(result.SortPreviews) != 1 {
+		t.Fatalf("got %d previews, want 1", len(result.SortPreviews))
+	}
+	preview := result.SortPreviews[0]
+	if preview.Dest != dest || preview.Op != "move" || len(preview.Moves) != 5 {
+		t.Fatalf("got %+v, want 5 moves into %s", preview, dest)
+	}
+	contents := make(map[string]string)
+	renamed := 0
+	for _, pm := range preview.Moves {
+		data, err := os.ReadFile(pm.Source)
+		if err != nil {
+			t.Fatal("the dry run touched a source:", err)
+		}
+		contents[pm.Target] = string(data)
+		if pm.Renamed {
+			renamed++
+		}
+	}
+	if renamed != 3 {
+		t.Errorf("got %d renamed targets, want 3 of the x.jpg", renamed)
+	}
+
+	if _, stderr, code := runCLI(t, "-q", "-yes", "-move", "-sort-into", dest, root); code != exitOK {
+		t.Fatalf("exit code %d, stderr:\n%s", code, stderr)
+	}
+	for target, want := range contents {
+		if data, err := os.ReadFile(target); err != nil || string(data) != want {
+			t.Errorf("%s: got %q, %v, want %q", target, data, err, want)
+		}
+	}
+	if got := countFiles(t, dest); got != len(contents)+1 {
+		t.Errorf("got %d files sorted, want the %d previewed and the earlier copy", got, len(contents))
+	}
+	total := 0
+	for _, dc := range preview.Folders {
+		total += dc.Count
+	}
+	if total != len(preview.Moves) {
+		t.Errorf("folders hold %d files, want %d", total, len(preview.Moves))
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// templateFile is what a -template is evaluated against for each image. Width, Height and
+// Date are methods, so the file is only read when the template uses them.
+type templateFile struct {
+	Path    string // honors -relative
+	Size    int64
+	Ext     string
+	Dir     string
+	ModTime time.Time
+
+	path      string
+	dimsRead  bool
+	dims      [2]int
+	dateRead  bool
+	date      time.Time
+	dateKnown bool
+}
+
+func newTemplateFile(file ImageFile) *templateFile {
+	return &templateFile{
+		Path:    pathText(file.Path),
+		Size:    file.Size,
+		Ext:     file.Ext,
+		Dir:     pathText(filepath.Dir(file.Path)),
+		ModTime: file.ModTime,
+		path:    file.Path,
+	}
+}
+
+// readDims reads the image's dimensions the first time they are needed; 0x0 if unknown
+func (f *templateFile) readDims() [2]int {
+	if !f.dimsRead {
+		f.dimsRead = true
+		if width, height, err := imageDimensions(f.path); err == nil {
+			f.dims = [2]int{width, height}
+		}
+	}
+	return f.dims
+}
+
+// Width returns the image width in pixels, or 0 if it can't be read
+func (f *templateFile) Width() int { return f.readDims()[0] }
+
+// Height returns the image height in pixels, or 0 if it can't be read
+func (f *templateFile) Height() int { return f.readDims()[1] }
+
+// readDate reads the image's capture date the first time it is needed
+func (f *templateFile) readDate() (time.Time, bool) {
+	if !f.dateRead {
+		f.dateRead = true
+		f.date, f.dateKnown = readCaptureDate(f.path)
+	}
+	return f.date, f.dateKnown
+}
+
+// Date returns the capture date as YYYY-MM-DD, or "unknown"
+func (f *templateFile) Date() string {
+	date, ok := f.readDate()
+	if !ok {
+		return "unknown"
+	}
+	return date.Format("2006-01-02")
+}
+
+// Taken returns the capture time, or the modification time if it is unknown
+func (f *templateFile) Taken() time.Time {
+	if date, ok := f.readDate(); ok {
+		return date
+	}
+	return f.ModTime
+}
+
+// parseFileTemplate parses the template text of the flag called name, like -template, first turning the escapes \t, \n and \\ into the
+// characters they stand for, since shells pass them on literally. It is test-run against
+// an empty file, so references to unknown fields are reported up front too.
+func parseFileTemplate(name, text string) (*template.Template, error) {
+	text = strings.NewReplacer(`\\`, `\`, `\t`, "\t", `\n`, "\n").Replace(text)
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if err := tmpl.Execute(io.Discard, &templateFile{dimsRead: true, dateRead: true}); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// printTemplateFiles prints one line per image using tmpl. Files the template fails on are
+// reported to stderr.
+func printTemplateFiles(out io.Writer, files []ImageFile, tmpl *template.Template) {
+	for _, file := range files {
+		var line strings.Builder
+		if err := tmpl.Execute(&line, newTemplateFile(file)); err != nil {
+			logger.Error("formatting failed", "path", file.Path, "err", err)
+			continue
+		}
+		fmt.Fprintln(out, line.String())
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// dirNode is a directory in the size tree printed by -tree
+type dirNode struct {
+	name     string
+	size     int64 // size of the images in this directory and all directories below it
+	children map[string]*dirNode
+}
+
+func newDirNode(name string) *dirNode {
+	return &dirNode{name: name, children: make(map[string]*dirNode)}
+}
+
+// buildDirTree arranges the per-directory image sizes under root into a tree, rolling the
+// size of every directory up into all of its parents. Directories outside root are ignored.
+func buildDirTree(root string, dirFileSize map[string]int64) *dirNode {
+	top := newDirNode(root)
+	for dir, size := range dirFileSize {
+		rel, err := filepath.Rel(root, dir)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+
+		top.size += size
+		if rel == "." {
+			continue
+		}
+		node := top
+		for _, name := range strings.Split(rel, string(filepath.Separator)) {
+			child, ok := node.children[name]
+			if !ok {
+				child = newDirNode(name)
+				node.children[name] = child
+			}
+			child.size += size
+			node = child
+		}
+	}
+	return top
+}
+
+// printDirTree prints the tree depth-first with the largest directories first, drawing
+// ├── / └── connectors. With maxDepth >= 0 only that many levels below the root are shown.
+func printDirTree(w io.Writer, root *dirNode, maxDepth int) {
+	fmt.Fprintf(w, "%s (%s)\n", root.name, sizeText(root.size))
+	printDirChildren(w, root, "", 1, maxDepth)
+}
+
+func printDirChildren(w io.Writer, node *dirNode, prefix string, depth, maxDepth int) {
+	if maxDepth >= 0 && depth > maxDepth {
+		return
+	}
+
+	children := make([]*dirNode, 0, len(node.children))
+	for _, child := range node.children {
+		children = append(children, child)
+	}
+	sort.Slice(children, func(i, j int) bool {
+		if children[i].size != children[j].size {
+			return children[i].size > children[j].size
+		}
+		return children[i].name < children[j].name
+	})
+
+	for i, child := range children {
+		connector, indent := "├── ", "│   "
+		if i == len(children)-1 {
+			connector, indent = "└── ", "    "
+		}
+		fmt.Fprintf(w, "%s%s%s (%s)\n", prefix, connector, child.name, sizeText(child.size))
+		printDirChildren(w, child, prefix+indent, depth+1, maxDepth)
+	}
+}
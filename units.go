@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Size unit suffixes accepted by parseSize. Units are binary (KB = 1024 bytes) to match the
+// KB/MB/GB totals printed in the summary.
+var sizeUnits = []struct {
+	suffix string
+	bytes  float64
+}{
+	// Longer suffixes first so "KB" is not mistaken for "B"
+	{"TB", 1 << 40},
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"T", 1 << 40},
+	{"G", 1 << 30},
+	{"M", 1 << 20},
+	{"K", 1 << 10},
+	{"B", 1},
+}
+
+// parseSize parses a human-readable size like "20MB", "500KB", "1.5GB" or "1024" into bytes.
+// Units are case-insensitive and binary, so "1KB" is 1024 bytes. A bare number is in bytes.
+func parseSize(s string) (int64, error) {
+	str := strings.ToUpper(strings.TrimSpace(s))
+	multiplier := 1.0
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(str, unit.suffix) {
+			str = strings.TrimSpace(strings.TrimSuffix(str, unit.suffix))
+			multiplier = unit.bytes
+			break
+		}
+	}
+
+	value, err := strconv.ParseFloat(str, 64)
+	if err != nil || value < 0 || math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, fmt.Errorf("invalid size %q: expected a non-negative number with an optional B, KB, MB, GB or TB unit", s)
+	}
+	// Converting a float beyond the int64 range gives an undefined result
+	bytes := value * multiplier
+	if bytes >= math.MaxInt64 {
+		return 0, fmt.Errorf("invalid size %q: too large", s)
+	}
+	return int64(bytes), nil
+}
+
+// noSeparators makes formatInt leave out the thousands separators, for scripts parsing the
+// output. It is set once from the -no-sep flag before anything is printed.
+var noSeparators bool
+
+// formatInt formats n with comma thousands separators, e.g. 1234567 as "1,234,567"
+func formatInt(n int64) string {
+	digits := strconv.FormatInt(n, 10)
+	if noSeparators {
+		return digits
+	}
+	sign := ""
+	if n < 0 {
+		sign, digits = "-", digits[1:]
+	}
+
+	var b strings.Builder
+	for i, digit := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(digit)
+	}
+	return sign + b.String()
+}
+
+// parseDuration parses a duration like "24h" or "90m" as time.ParseDuration does, and
+// additionally accepts whole or fractional days with a "d" suffix, like "7d" or "1.5d"
+func parseDuration(s string) (time.Duration, error) {
+	str := strings.TrimSpace(s)
+	if days, ok := strings.CutSuffix(str, "d"); ok {
+		value, err := strconv.ParseFloat(days, 64)
+		if err != nil || value < 0 || math.IsNaN(value) || math.IsInf(value, 0) {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(value * float64(24*time.Hour)), nil
+	}
+	return time.ParseDuration(str)
+}
+
+// parseTimeBound parses either an RFC3339 timestamp or a duration relative to now, so
+// "7d" means seven days before now
+func parseTimeBound(s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, strings.TrimSpace(s)); err == nil {
+		return t, nil
+	}
+	d, err := parseDuration(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q: expected an RFC3339 timestamp or a duration like 24h or 7d", s)
+	}
+	return now.Add(-d), nil
+}
+
+// rawSizes makes sizeText print exact byte counts instead, for scripts parsing the output. It is
+// set once from the -bytes flag before anything is printed.
+var rawSizes bool
+
+// sizeText formats a byte count for the text report: human-readable unless -bytes is set
+func sizeText(bytes int64) string {
+	if rawSizes {
+		return formatInt(bytes) + " bytes"
+	}
+	return humanSize(bytes)
+}
+
+// humanSize formats a byte count in the largest unit (B, KB, MB, GB or TB) that keeps the
+// value at least 1, with two decimals, e.g. 1536 as "1.50 KB". Units are binary, and counts
+// under 1 KB are printed as whole bytes, e.g. "1023 B".
+func humanSize(bytes int64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	value := float64(bytes)
+	unit := 0
+	// Compare the rounded value, so 1048575 bytes is "1.00 MB" rather than "1024.00 KB"
+	for unit < len(units)-1 && math.Round(math.Abs(value)*100)/100 >= 1024 {
+		value /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	return fmt.Sprintf("%.2f %s", value, units[unit])
+}
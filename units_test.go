@@ -0,0 +1,114 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHumanSize(t *testing.T) {
+	tests := []struct {
+		bytes int64
+		want  string
+	}{
+		{0, "0 B"},
+		{1, "1 B"},
+		{1023, "1023 B"},
+		{1024, "1.00 KB"},
+		{1536, "1.50 KB"},
+		{1048575, "1.00 MB"}, // rounds up to the next unit rather than to "1024.00 KB"
+		{1048576, "1.00 MB"},
+		{1<<30 - 1, "1.00 GB"},
+		{1 << 30, "1.00 GB"},
+		{5 << 40, "5.00 TB"},
+		{2048 << 40, "2048.00 TB"}, // no unit above TB
+		{-1, "-1 B"},
+		{-1024, "-1.00 KB"},
+		{-1536, "-1.50 KB"},
+	}
+	for _, tt := range tests {
+		if got := humanSize(tt.bytes); got != tt.want {
+			t.Errorf("humanSize(%d) = %q, want %q", tt.bytes, got, tt.want)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{"0", 0, false},
+		{"1024", 1024, false},
+		{"500KB", 500 << 10, false},
+		{"20mb", 20 << 20, false},
+		{"1.5G", 3 << 29, false},
+		{" 2 TB ", 2 << 40, false},
+		{"8388607T", 8388607 << 40, false}, // the largest whole number of TB that fits
+		{"", 0, true},
+		{"-1", 0, true},
+		{"12XB", 0, true},
+		{"NaN", 0, true},
+		{"Inf", 0, true},
+		{"8388608T", 0, true}, // exactly 2^63 bytes
+		{"9999999999T", 0, true},
+		{"1e30", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseSize(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseSize(%q) = %d, %v; want %d, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestSizeTextRaw(t *testing.T) {
+	defer func(saved bool) { rawSizes = saved }(rawSizes)
+	rawSizes = true
+	if got := sizeText(1048576); got != "1,048,576 bytes" {
+		t.Errorf("sizeText with -bytes = %q, want %q", got, "1,048,576 bytes")
+	}
+}
+
+func TestFormatInt(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{0, "0"},
+		{7, "7"},
+		{42, "42"},
+		{999, "999"},
+		{1000, "1,000"},
+		{12345, "12,345"},
+		{123456, "123,456"},
+		{1234567, "1,234,567"},
+		{12345678, "12,345,678"},
+		{123456789, "123,456,789"},
+		{1234567890, "1,234,567,890"},
+		{12345678901, "12,345,678,901"},
+		{123456789012, "123,456,789,012"},
+		{-1, "-1"},
+		{-999, "-999"},
+		{-1000, "-1,000"},
+		{-123456, "-123,456"},
+		{-123456789012, "-123,456,789,012"},
+		{math.MaxInt64, "9,223,372,036,854,775,807"},
+		{math.MinInt64, "-9,223,372,036,854,775,808"},
+	}
+	for _, tt := range tests {
+		if got := formatInt(tt.n); got != tt.want {
+			t.Errorf("formatInt(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestFormatIntNoSeparators(t *testing.T) {
+	defer func(saved bool) { noSeparators = saved }(noSeparators)
+	noSeparators = true
+	for n, want := range map[int64]string{0: "0", 1234567: "1234567", -1000: "-1000"} {
+		if got := formatInt(n); got != want {
+			t.Errorf("formatInt(%d) with -no-sep = %q, want %q", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"sync"
+)
+
+// Formats with a registered Go decoder, which -verify can fully decode and -find-similar
+// can hash; webp.go adds WebP in builds with -tags webp
+var decodableExtensions = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".gif":  true,
+}
+
+// BadImage is an image file that failed to decode
+type BadImage struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// loadImage fully decodes the image at path
+func loadImage(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	return img, err
+}
+
+// decodeImage fully decodes the image at path, returning any error from reading or decoding it
+func decodeImage(path string) error {
+	_, err := loadImage(path)
+	return err
+}
+
+// verifyImages fully decodes every image with a Go decoder using the given number of
+// worker goroutines, returning the files that failed to decode and the paths of files
+// whose format can't be verified (HEIC, RAW, ...). Both lists keep the order of files.
+func verifyImages(files []ImageFile, workers int) (bad []BadImage, unverifiable []string) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	// Each worker writes only the entries for the indexes it receives
+	errs := make([]error, len(files))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				errs[i] = decodeImage(files[i].Path)
+			}
+		}()
+	}
+
+	for i, file := range files {
+		if decodableExtensions[file.Ext] {
+			indexes <- i
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	for i, file := range files {
+		if !decodableExtensions[file.Ext] {
+			unverifiable = append(unverifiable, file.Path)
+		} else if errs[i] != nil {
+			bad = append(bad, BadImage{Path: file.Path, Error: errs[i].Error()})
+		}
+	}
+	return bad, unverifiable
+}
+
+// printVerifyResults prints the images that failed to decode and those that couldn't be checked
+func printVerifyResults(w io.Writer, bad []BadImage, unverifiable []string) {
+	fmt.Fprintln(w, "\nCorrupt or truncated images:")
+	for _, b := range bad {
+		fmt.Fprintf(w, "Bad: %s | Error: %s\n", pathText(b.Path), b.Error)
+	}
+	for _, path := range unverifiable {
+		fmt.Fprintf(w, "Unverifiable: %s\n", pathText(path))
+	}
+	fmt.Fprintf(w, "%d bad, %d unverifiable\n", len(bad), len(unverifiable))
+}
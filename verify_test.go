@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// jpegBytes encodes a w×h JPEG image with a gradient, so it has some entropy-coded data
+func jpegBytes(t testing.TB, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.Set(x, y, color.RGBA{uint8(x * 255 / w), uint8(y * 255 / h), 128, 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestVerifyImages(t *testing.T) {
+	root := t.TempDir()
+	data := jpegBytes(t, 64, 64)
+	good, truncated, heic := filepath.Join(root, "good.jpg"), filepath.Join(root, "truncated.jpg"), filepath.Join(root, "photo.heic")
+	writeFile(t, good, string(data))
+	writeFile(t, truncated, string(data[:len(data)/2]))
+	writeFile(t, heic, "not decodable by Go")
+
+	bad, unverifiable := verifyImages(scanFiles(t, root), 2)
+	if len(bad) != 1 || bad[0].Path != truncated || bad[0].Error == "" {
+		t.Errorf("bad: got %+v, want only %s with its error", bad, truncated)
+	}
+	if !slices.Equal(unverifiable, []string{heic}) {
+		t.Errorf("unverifiable: got %q, want %q", unverifiable, heic)
+	}
+
+	result := runJSON(t, "-verify", root)
+	if len(result.Corrupt) != 1 || result.Corrupt[0].Path != truncated {
+		t.Errorf("JSON corrupt: got %+v, want %s", result.Corrupt, truncated)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build webp
+
+package main
+
+// Built with -tags webp, WebP images get dimensions with -dims and are fully decoded by
+// -verify. This pulls in golang.org/x/image, so it is left out of the default build.
+
+import (
+	_ "golang.org/x/image/webp" // register the WebP decoder for image.DecodeConfig and image.Decode
+)
+
+func init() {
+	decodableExtensions[".webp"] = true
+}
@@ -0,0 +1,39 @@
+//go:build webp
+
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifyWebP(t *testing.T) {
+	if !decodableExtensions[".webp"] {
+		t.Fatal(".webp isn't decodable in a build with -tags webp")
+	}
+
+	// A RIFF header announcing a lossless WebP image, cut off before any image data
+	path := filepath.Join(t.TempDir(), "cut.webp")
+	writeFile(t, path, "RIFF\x1a\x00\x00\x00WEBPVP8L\x0d\x00\x00\x00\x2f")
+	bad, unverifiable := verifyImages([]ImageFile{{Path: path, Ext: ".webp"}}, 1)
+	if len(unverifiable) != 0 {
+		t.Errorf("WebP image reported unverifiable: %v", unverifiable)
+	}
+	if len(bad) != 1 || bad[0].Path != path {
+		t.Errorf("truncated WebP image: got bad %v, want %s", bad, path)
+	}
+}
+
+func TestWebPDimensions(t *testing.T) {
+	// A RIFF WebP file with only its extended-format header, giving a 640×480 canvas as
+	// 24-bit little-endian width and height minus one
+	vp8x := []byte{0, 0, 0, 0, 0x7f, 0x02, 0x00, 0xdf, 0x01, 0x00}
+	data := append([]byte("RIFF\x16\x00\x00\x00WEBPVP8X\x0a\x00\x00\x00"), vp8x...)
+	path := filepath.Join(t.TempDir(), "image.webp")
+	writeFile(t, path, string(data))
+
+	w, h, err := imageDimensions(path)
+	if err != nil || w != 640 || h != 480 {
+		t.Errorf("got %d×%d, %v; want 640×480", w, h, err)
+	}
+}